@@ -8,74 +8,380 @@
 package cache
 
 import (
-	"fmt"
-	"github.com/aws/aws-sdk-go/service/s3"
-	gocache "github.com/patrickmn/go-cache"
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
 	"github.com/pkg/errors"
-	"github.com/spatialcurrent/go-reader-writer/grw"
-	"github.com/spatialcurrent/go-simple-serializer/gss"
-	"reflect"
+	"golang.org/x/sync/singleflight"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 )
 
+const (
+	PolicyLRU = "lru" // evict the least recently used item
+	PolicyLFU = "lfu" // evict the least frequently used item, breaking ties by the least recently used
+)
+
+var Policies = []string{PolicyLRU, PolicyLFU}
+
+// Cache is an in-memory cache of deserialized objects shared by layers.
+// The cache is bounded by the estimated size of its items.
+// When adding an item would exceed the budget, unpinned items are evicted according to the policy.
+// Pinned items are never evicted to make room and are added even if they exceed the budget, but still expire.
+// Concurrent loads of the same item are collapsed into one, and items are revalidated against the version of their source
+// once they have been in the cache longer than the revalidate interval.
 type Cache struct {
-	cache *gocache.Cache
+	mutex                *sync.Mutex
+	items                map[string]*entry
+	recency              *list.List // the front is the most recently used entry
+	flights              *singleflight.Group
+	policy               string
+	maxBytes             int64
+	defaultExpiration    time.Duration
+	revalidateInterval   time.Duration
+	staleWhileRevalidate bool
+	bytes                int64
+	groups               map[string]*Stats
+	stop                 chan struct{}
+	Disk                 *Disk                             // the optional second-level cache, consulted before reading a data store
+	LoadTimeout          time.Duration                     // the timeout of loads and revalidations shared by concurrent requests, if 0 then none
+	ObserveRead          func(uri string, d time.Duration) // records the time to read and deserialize a data store, if not nil
+}
+
+type entry struct {
+	key     string
+	uri     string
+	group   string              // the group that added the entry
+	groups  map[string]struct{} // the groups that have read the entry
+	value   interface{}
+	size    int64
+	created time.Time
+	expires time.Time // zero if the entry never expires
+	version string    // the version of the source when the entry was loaded
+	checked time.Time // when the version of the source was last checked
+	pinned  bool
+	hits    int64
+	element *list.Element
+}
+
+func (e *entry) expired(now time.Time) bool {
+	return !e.expires.IsZero() && now.After(e.expires)
+}
+
+// NewCache returns a new cache with the given eviction policy and byte budget.
+// A maxBytes of zero disables the budget and a defaultExpiration of zero keeps items until they are evicted.
+// If cleanupInterval is greater than zero, expired items are deleted in the background at that interval until Stop is called.
+// A revalidateInterval of zero disables revalidation.
+// If staleWhileRevalidate is true, then items are returned while they are revalidated in the background.
+func NewCache(policy string, maxBytes int64, defaultExpiration time.Duration, cleanupInterval time.Duration, revalidateInterval time.Duration, staleWhileRevalidate bool) (*Cache, error) {
+	policy = strings.ToLower(policy)
+	valid := false
+	for _, p := range Policies {
+		if policy == p {
+			valid = true
+			break
+		}
+	}
+	if !valid {
+		return nil, errors.New("invalid cache policy " + policy + ", expecting one of " + strings.Join(Policies, ", "))
+	}
+	c := &Cache{
+		mutex:                &sync.Mutex{},
+		items:                map[string]*entry{},
+		recency:              list.New(),
+		flights:              &singleflight.Group{},
+		policy:               policy,
+		maxBytes:             maxBytes,
+		defaultExpiration:    defaultExpiration,
+		revalidateInterval:   revalidateInterval,
+		staleWhileRevalidate: staleWhileRevalidate,
+		groups:               map[string]*Stats{},
+		stop:                 make(chan struct{}),
+	}
+	if cleanupInterval > 0 {
+		go c.janitor(cleanupInterval)
+	}
+	return c, nil
+}
+
+// Group returns a view of the cache that counts its statistics under the given name.
+// Items added through the group expire after ttl, or the default expiration of the cache if ttl is zero.
+func (c *Cache) Group(name string, ttl time.Duration, pinned bool) *Group {
+	return &Group{cache: c, Name: name, Ttl: ttl, Pinned: pinned}
+}
+
+func (c *Cache) stats(group string) *Stats {
+	s, ok := c.groups[group]
+	if !ok {
+		s = &Stats{}
+		c.groups[group] = s
+	}
+	return s
+}
+
+// get returns the value and version for the key, pinning the entry if the group is pinned.
+// Returns true as the third value if the entry is due to be revalidated.
+func (c *Cache) get(group string, key string, pinned bool) (interface{}, string, bool, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	e, ok := c.items[key]
+	if ok && e.expired(time.Now()) {
+		c.remove(e)
+		c.stats(e.group).Expirations++
+		ok = false
+	}
+	if !ok {
+		c.stats(group).Misses++
+		return nil, "", false, false
+	}
+	c.stats(group).Hits++
+	e.groups[group] = struct{}{}
+	e.hits++
+	e.pinned = e.pinned || pinned
+	c.recency.MoveToFront(e.element)
+	stale := c.revalidateInterval > 0 && time.Since(e.checked) >= c.revalidateInterval
+	return e.value, e.version, stale, true
 }
 
-func (c *Cache) Get(uri string, format string, compression string, bufferSize int, passphrase string, salt string, s3_client *s3.S3, verbose bool) (bool, interface{}, error) {
+// touch marks the entry for the key as checked, so it is not revalidated until the next interval.
+func (c *Cache) touch(key string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if e, ok := c.items[key]; ok {
+		e.checked = time.Now()
+	}
+}
+
+// set adds the value to the cache, evicting unpinned entries if needed to stay within the byte budget.
+// Returns false if the value was not added, since it could not fit.
+func (c *Cache) set(group string, key string, uri string, value interface{}, version string, ttl time.Duration, pinned bool) bool {
+	size := EstimateSize(value)
+	if ttl == 0 {
+		ttl = c.defaultExpiration
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if e, ok := c.items[key]; ok {
+		c.remove(e)
+	}
 
-	item, found := c.cache.Get(uri)
-	if found {
-		if t := reflect.TypeOf(item); !(t.Kind() == reflect.Array || t.Kind() == reflect.Slice) {
-			return true, item, errors.New("object retrieved from cache was not an array or slice but " + fmt.Sprint(t))
+	if c.maxBytes > 0 {
+		if size > c.maxBytes && !pinned {
+			return false
+		}
+		for c.bytes+size > c.maxBytes {
+			victim := c.victim()
+			if victim == nil {
+				// pinned items are added even if the cache is over budget.
+				if !pinned {
+					return false
+				}
+				break
+			}
+			c.remove(victim)
+			c.stats(victim.group).Evictions++
 		}
-		return true, item, nil
 	}
 
-	inputReader, _, err := grw.ReadFromResource(
-		uri,
-		compression,
-		bufferSize,
-		false,
-		s3_client)
-	if err != nil {
-		return false, nil, errors.Wrap(err, "error opening resource at uri "+uri)
+	now := time.Now()
+	e := &entry{
+		key:     key,
+		uri:     uri,
+		group:   group,
+		groups:  map[string]struct{}{group: struct{}{}},
+		value:   value,
+		size:    size,
+		created: now,
+		pinned:  pinned,
+		version: version,
+		checked: now,
+	}
+	if ttl > 0 {
+		e.expires = now.Add(ttl)
 	}
+	e.element = c.recency.PushFront(e)
+	c.items[key] = e
+	c.bytes += size
+	s := c.stats(group)
+	s.Items++
+	s.Bytes += size
+	return true
+}
 
+// victim returns the unpinned entry to evict according to the policy, or nil if every entry is pinned.
+// Expired entries are always evicted first.
+func (c *Cache) victim() *entry {
+	now := time.Now()
+	var victim *entry
+	for element := c.recency.Back(); element != nil; element = element.Prev() {
+		e := element.Value.(*entry)
+		if e.expired(now) {
+			return e
+		}
+		if e.pinned {
+			continue
+		}
+		if victim == nil {
+			victim = e
+			if c.policy == PolicyLRU {
+				return victim
+			}
+		} else if e.hits < victim.hits {
+			victim = e
+		}
+	}
+	return victim
+}
 
-	inputByte, err := inputReader.ReadAllAndClose()
-	if err != nil {
-		return false, nil, errors.New("error reading from resource at uri " + uri)
+func (c *Cache) remove(e *entry) {
+	c.recency.Remove(e.element)
+	delete(c.items, e.key)
+	c.bytes -= e.size
+	s := c.stats(e.group)
+	s.Items--
+	s.Bytes -= e.size
+}
+
+// ItemKey returns the key of the item read from the data store at the uri with the format, compression, and key name,
+// since the same uri read another way is a different object.
+func ItemKey(uri string, format string, compression string, keyName string) string {
+	return strings.Join([]string{uri, format, compression, keyName}, "\x00")
+}
+
+// Key returns the key of the entry for the item key.
+// Keys are used instead of item keys in paths, since uris contain slashes.
+func Key(itemKey string) string {
+	sum := sha256.Sum256([]byte(itemKey))
+	return hex.EncodeToString(sum[:8])
+}
+
+// Entries returns the entries in the cache, sorted by uri.
+// If group is not blank, then only the entries read by the group are returned.
+func (c *Cache) Entries(group string) []*Entry {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	entries := make([]*Entry, 0, len(c.items))
+	for _, e := range c.items {
+		if len(group) > 0 {
+			if _, ok := e.groups[group]; !ok {
+				continue
+			}
+		}
+		entries = append(entries, &Entry{
+			Key:     Key(e.key),
+			Uri:     e.uri,
+			Layer:   e.group,
+			Bytes:   e.size,
+			Hits:    e.hits,
+			Pinned:  e.pinned,
+			Created: e.created,
+			Expires: e.expires,
+		})
 	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Uri == entries[j].Uri {
+			return entries[i].Key < entries[j].Key
+		}
+		return entries[i].Uri < entries[j].Uri
+	})
+	return entries
+}
 
-  /*
-	inputBytesEncrypted, err := inputReader.ReadAllAndClose()
-	if err != nil {
-		return false, nil, errors.New("error reading from resource at uri " + uri)
+// Delete deletes the entry with the given key from the cache, or every entry with the given uri,
+// including their files in the disk cache.
+// Returns false if no entry was in the cache.
+func (c *Cache) Delete(key string) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	deleted := false
+	for _, e := range c.items {
+		if e.key == key || e.uri == key || Key(e.key) == key {
+			c.remove(e)
+			if c.Disk != nil {
+				c.Disk.Delete(e.key)
+			}
+			deleted = true
+		}
 	}
+	return deleted
+}
 
-	inputBytesPlain, err := DecryptInput(inputBytesEncrypted, passphrase, salt)
-	if err != nil {
-		return false, nil, errors.Wrap(err, "error decoding input")
-	}*/
+// DeleteGroup deletes the entries read by the group from the cache, including their files in the disk cache,
+// and returns the number of entries deleted.
+// Entries shared with other groups are deleted too, since they hold the same data.
+func (c *Cache) DeleteGroup(group string) int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	deleted := 0
+	for _, e := range c.items {
+		if _, ok := e.groups[group]; ok {
+			c.remove(e)
+			if c.Disk != nil {
+				c.Disk.Delete(e.key)
+			}
+			deleted++
+		}
+	}
+	return deleted
+}
 
-	inputType, err := gss.GetType(inputByte, format)
-	if err != nil {
-		return false, nil, errors.Wrap(err, "error getting type for input")
+// DeleteExpired deletes the expired items from the cache.
+func (c *Cache) DeleteExpired() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	now := time.Now()
+	for _, e := range c.items {
+		if e.expired(now) {
+			c.remove(e)
+			c.stats(e.group).Expirations++
+		}
 	}
+}
 
-	obj, err := gss.DeserializeBytes(inputByte, format, []string{}, "", false, gss.NoSkip, gss.NoLimit, inputType, verbose)
-	if err != nil {
-		return false, nil, errors.Wrap(err, "error deserializing input using format "+format)
+func (c *Cache) janitor(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			c.DeleteExpired()
+		case <-c.stop:
+			return
+		}
 	}
+}
 
-	c.cache.Set(uri, obj, gocache.DefaultExpiration)
+// Stop stops deleting expired items in the background.
+func (c *Cache) Stop() {
+	close(c.stop)
+}
 
-	return false, obj, nil
+// Stats returns the statistics of the whole cache.
+func (c *Cache) Stats() Stats {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	total := Stats{}
+	for _, s := range c.groups {
+		total.add(s)
+	}
+	return total
 }
 
-func NewCache() *Cache {
-	return &Cache{
-		cache: gocache.New(5*time.Minute, 10*time.Minute),
+// GroupStats returns the statistics of the group with the given name.
+func (c *Cache) GroupStats(name string) Stats {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if s, ok := c.groups[name]; ok {
+		return *s
 	}
+	return Stats{}
+}
+
+// MaxBytes returns the byte budget of the cache, or zero if the cache is unbounded.
+func (c *Cache) MaxBytes() int64 {
+	return c.maxBytes
 }
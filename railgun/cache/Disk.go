@@ -0,0 +1,276 @@
+// =================================================================
+//
+// Copyright (C) 2018 Spatial Current, Inc. - All Rights Reserved
+// Released as open source under the MIT License.  See LICENSE file.
+//
+// =================================================================
+
+package cache
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/mitchellh/go-homedir"
+	"github.com/pkg/errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// diskMagic starts every file in the disk cache, followed by the SHA-256 checksum of the data.
+var diskMagic = []byte("RGC1")
+
+const diskExtension = ".bin"
+
+// Disk is a second-level cache that stores the raw bytes read from data stores as files in a directory,
+// so data does not need to be downloaded again after a restart.
+// Files are named by the item key and version of the data, so a new version of the data is stored as a new file
+// and the file of the previous version is deleted.
+// When the files exceed the byte budget, the least recently used files are deleted.
+// Files that fail their checksum are deleted and treated as missing.
+type Disk struct {
+	mutex    *sync.Mutex
+	dir      string
+	maxBytes int64
+	files    map[string]*diskFile // by name
+	bytes    int64
+	stats    DiskStats
+}
+
+type diskFile struct {
+	name     string
+	size     int64
+	accessed time.Time
+}
+
+// DiskStats are the statistics of a disk cache.
+type DiskStats struct {
+	Items       int   `json:"items" yaml:"items"`
+	Bytes       int64 `json:"bytes" yaml:"bytes"`
+	Hits        int64 `json:"hits" yaml:"hits"`
+	Misses      int64 `json:"misses" yaml:"misses"`
+	Evictions   int64 `json:"evictions" yaml:"evictions"`
+	Corruptions int64 `json:"corruptions" yaml:"corruptions"`
+	Errors      int64 `json:"errors" yaml:"errors"`
+}
+
+func (s DiskStats) Map() map[string]interface{} {
+	return map[string]interface{}{
+		"items":       s.Items,
+		"bytes":       s.Bytes,
+		"hits":        s.Hits,
+		"misses":      s.Misses,
+		"evictions":   s.Evictions,
+		"corruptions": s.Corruptions,
+		"errors":      s.Errors,
+	}
+}
+
+// NewDisk returns a disk cache in the directory, creating the directory if needed.
+// Files left by a previous run are kept, and the cache is trimmed to maxBytes.
+// A maxBytes of zero disables the budget.
+func NewDisk(dir string, maxBytes int64) (*Disk, error) {
+	dirExpanded, err := homedir.Expand(dir)
+	if err != nil {
+		return nil, errors.Wrap(err, "error expanding path "+dir)
+	}
+	if err := os.MkdirAll(dirExpanded, 0700); err != nil {
+		return nil, errors.Wrap(err, "error creating cache directory "+dir)
+	}
+	infos, err := ioutil.ReadDir(dirExpanded)
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading cache directory "+dir)
+	}
+	d := &Disk{
+		mutex:    &sync.Mutex{},
+		dir:      dirExpanded,
+		maxBytes: maxBytes,
+		files:    map[string]*diskFile{},
+	}
+	for _, info := range infos {
+		if info.IsDir() {
+			continue
+		}
+		switch filepath.Ext(info.Name()) {
+		case diskExtension:
+			d.files[info.Name()] = &diskFile{name: info.Name(), size: info.Size(), accessed: info.ModTime()}
+			d.bytes += info.Size()
+		case ".tmp":
+			// a write was interrupted
+			os.Remove(filepath.Join(dirExpanded, info.Name()))
+		}
+	}
+	d.mutex.Lock()
+	d.trim()
+	d.mutex.Unlock()
+	return d, nil
+}
+
+func diskPrefix(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:16])
+}
+
+func diskName(key string, version string) string {
+	sum := sha256.Sum256([]byte(version))
+	return diskPrefix(key) + "-" + hex.EncodeToString(sum[:8]) + diskExtension
+}
+
+// Get returns the bytes stored for the version of the item with the key.
+func (d *Disk) Get(key string, version string) ([]byte, bool) {
+	name := diskName(key, version)
+
+	d.mutex.Lock()
+	f, ok := d.files[name]
+	if !ok {
+		d.stats.Misses++
+		d.mutex.Unlock()
+		return nil, false
+	}
+	d.mutex.Unlock()
+
+	path := filepath.Join(d.dir, name)
+	b, err := ioutil.ReadFile(path)
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if err != nil {
+		d.stats.Errors++
+		d.stats.Misses++
+		d.remove(f)
+		return nil, false
+	}
+	data, ok := verify(b)
+	if !ok {
+		d.stats.Corruptions++
+		d.stats.Misses++
+		d.remove(f)
+		return nil, false
+	}
+	d.stats.Hits++
+	f.accessed = time.Now()
+	// the modification time is the access time, so the order of eviction survives a restart.
+	os.Chtimes(path, f.accessed, f.accessed)
+	return data, true
+}
+
+// Put stores the bytes for the version of the item with the key, replacing the files of other versions.
+// The file is written to a temporary file and then renamed, so readers never see a partial file.
+func (d *Disk) Put(key string, version string, data []byte) error {
+	name := diskName(key, version)
+	sum := sha256.Sum256(data)
+
+	tmp, err := ioutil.TempFile(d.dir, name+".*.tmp")
+	if err != nil {
+		d.countError()
+		return errors.Wrap(err, "error creating file in cache directory")
+	}
+	_, err = tmp.Write(diskMagic)
+	if err == nil {
+		_, err = tmp.Write(sum[:])
+	}
+	if err == nil {
+		_, err = tmp.Write(data)
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), filepath.Join(d.dir, name))
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		d.countError()
+		return errors.Wrap(err, "error writing "+name+" to cache directory")
+	}
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	prefix := diskPrefix(key)
+	for _, f := range d.files {
+		if strings.HasPrefix(f.name, prefix) && f.name != name {
+			d.remove(f)
+		}
+	}
+	if f, ok := d.files[name]; ok {
+		d.bytes -= f.size
+	}
+	size := int64(len(diskMagic) + len(sum) + len(data))
+	d.files[name] = &diskFile{name: name, size: size, accessed: time.Now()}
+	d.bytes += size
+	d.trim()
+	return nil
+}
+
+// Delete deletes the files stored for the item with the key.
+func (d *Disk) Delete(key string) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	prefix := diskPrefix(key)
+	for _, f := range d.files {
+		if strings.HasPrefix(f.name, prefix) {
+			d.remove(f)
+		}
+	}
+}
+
+// Stats returns the statistics of the disk cache.
+func (d *Disk) Stats() DiskStats {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	s := d.stats
+	s.Items = len(d.files)
+	s.Bytes = d.bytes
+	return s
+}
+
+func (d *Disk) countError() {
+	d.mutex.Lock()
+	d.stats.Errors++
+	d.mutex.Unlock()
+}
+
+// trim deletes the least recently used files until the files fit in the budget.
+func (d *Disk) trim() {
+	if d.maxBytes <= 0 || d.bytes <= d.maxBytes {
+		return
+	}
+	files := make([]*diskFile, 0, len(d.files))
+	for _, f := range d.files {
+		files = append(files, f)
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].accessed.Before(files[j].accessed)
+	})
+	for _, f := range files {
+		if d.bytes <= d.maxBytes {
+			break
+		}
+		d.remove(f)
+		d.stats.Evictions++
+	}
+}
+
+func (d *Disk) remove(f *diskFile) {
+	if _, ok := d.files[f.name]; !ok {
+		return
+	}
+	os.Remove(filepath.Join(d.dir, f.name))
+	delete(d.files, f.name)
+	d.bytes -= f.size
+}
+
+// verify returns the data in the contents of a file if the checksum matches.
+func verify(b []byte) ([]byte, bool) {
+	if len(b) < len(diskMagic)+sha256.Size || !bytes.Equal(b[:len(diskMagic)], diskMagic) {
+		return nil, false
+	}
+	data := b[len(diskMagic)+sha256.Size:]
+	sum := sha256.Sum256(data)
+	return data, bytes.Equal(sum[:], b[len(diskMagic):len(diskMagic)+sha256.Size])
+}
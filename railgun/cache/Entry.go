@@ -0,0 +1,40 @@
+// =================================================================
+//
+// Copyright (C) 2018 Spatial Current, Inc. - All Rights Reserved
+// Released as open source under the MIT License.  See LICENSE file.
+//
+// =================================================================
+
+package cache
+
+import (
+	"time"
+)
+
+// Entry describes an item in the cache.
+type Entry struct {
+	Key     string    // the key of the entry, derived from the uri, format, compression, and key name
+	Uri     string    // the uri of the data store
+	Layer   string    // the layer that added the entry
+	Bytes   int64     // the estimated size of the deserialized object
+	Hits    int64     // the number of times the entry was read
+	Pinned  bool      // the entry is never evicted to make room
+	Created time.Time // when the entry was added
+	Expires time.Time // when the entry expires, or zero if never
+}
+
+func (e *Entry) Map() map[string]interface{} {
+	m := map[string]interface{}{
+		"key":    e.Key,
+		"uri":    e.Uri,
+		"layer":  e.Layer,
+		"size":   e.Bytes,
+		"age":    time.Since(e.Created).Seconds(),
+		"hits":   e.Hits,
+		"pinned": e.Pinned,
+	}
+	if !e.Expires.IsZero() {
+		m["expires"] = e.Expires.Format(time.RFC3339)
+	}
+	return m
+}
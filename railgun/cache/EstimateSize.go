@@ -0,0 +1,80 @@
+// =================================================================
+//
+// Copyright (C) 2018 Spatial Current, Inc. - All Rights Reserved
+// Released as open source under the MIT License.  See LICENSE file.
+//
+// =================================================================
+
+package cache
+
+import (
+	"reflect"
+)
+
+// EstimateSize returns the approximate number of bytes of memory used by a deserialized object,
+// including the headers of strings, slices, maps, and interfaces.
+// The object must not contain cycles.
+func EstimateSize(obj interface{}) int64 {
+	if obj == nil {
+		return 0
+	}
+	return estimateSize(reflect.ValueOf(obj))
+}
+
+func estimateSize(v reflect.Value) int64 {
+	switch v.Kind() {
+	case reflect.Interface:
+		if v.IsNil() {
+			return 16
+		}
+		return 16 + estimateSize(v.Elem())
+	case reflect.Ptr:
+		if v.IsNil() {
+			return 8
+		}
+		return 8 + estimateSize(v.Elem())
+	case reflect.String:
+		return 16 + int64(v.Len())
+	case reflect.Slice:
+		if v.IsNil() {
+			return 24
+		}
+		return 24 + estimateElements(v)
+	case reflect.Array:
+		return estimateElements(v)
+	case reflect.Map:
+		if v.IsNil() {
+			return 8
+		}
+		// the buckets of a map have roughly 8 bytes of overhead per entry.
+		n := int64(48)
+		for _, k := range v.MapKeys() {
+			n += 8 + estimateSize(k) + estimateSize(v.MapIndex(k))
+		}
+		return n
+	case reflect.Struct:
+		n := int64(0)
+		for i := 0; i < v.NumField(); i++ {
+			n += estimateSize(v.Field(i))
+		}
+		return n
+	}
+	return int64(v.Type().Size())
+}
+
+func estimateElements(v reflect.Value) int64 {
+	switch v.Type().Elem().Kind() {
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		if v.Kind() == reflect.Slice {
+			return int64(v.Cap()) * int64(v.Type().Elem().Size())
+		}
+		return int64(v.Len()) * int64(v.Type().Elem().Size())
+	}
+	n := int64(0)
+	for i := 0; i < v.Len(); i++ {
+		n += estimateSize(v.Index(i))
+	}
+	return n
+}
@@ -0,0 +1,256 @@
+// =================================================================
+//
+// Copyright (C) 2018 Spatial Current, Inc. - All Rights Reserved
+// Released as open source under the MIT License.  See LICENSE file.
+//
+// =================================================================
+
+package cache
+
+import (
+	"context"
+	"fmt"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/pkg/errors"
+	"github.com/spatialcurrent/go-reader-writer/grw"
+	"github.com/spatialcurrent/go-simple-serializer/gss"
+	"github.com/spatialcurrent/railgun/railgun/tracing"
+	"github.com/spatialcurrent/railgun/railgun/util"
+	"go.opentelemetry.io/otel/trace"
+	"reflect"
+	"time"
+)
+
+// Group is the view of a shared cache used by a layer.
+// Items are keyed by the uri, format, compression, and key name of the data store,
+// so layers that read the same data store the same way share the deserialized object.
+type Group struct {
+	cache  *Cache
+	Name   string
+	Ttl    time.Duration
+	Pinned bool
+}
+
+// Get returns the object deserialized from the data store at the uri, reading the data store if the object is not in the cache.
+// Concurrent misses for the same uri share one read.
+// If the key name is not blank, then the data store declares a key, so data that is not encrypted is rejected.
+// Returns true if the object was in the cache and did not need to be reloaded.
+func (g *Group) Get(ctx context.Context, uri string, format string, compression string, bufferSize int, keyName string, passphrase string, salt string, keys util.KeyResolver, s3_client *s3.S3, verbose bool) (hit bool, obj interface{}, err error) {
+
+	ctx, span := tracing.Start(ctx, "cache.Get", tracing.AttributeDataStoreUri.String(uri))
+	defer func() {
+		span.SetAttributes(tracing.AttributeCacheHit.Bool(hit))
+		tracing.End(span, err)
+	}()
+
+	key := ItemKey(uri, format, compression, keyName)
+
+	item, version, stale, found := g.cache.get(g.Name, key, g.Pinned)
+	if found && stale {
+		// the revalidation is shared by concurrent requests, so it runs on a context that is not canceled with the request that started it.
+		revalidating := g.cache.flights.DoChan("revalidate "+key, func() (interface{}, error) {
+			loadCtx, cancel := g.cache.detach(ctx)
+			defer cancel()
+			return g.revalidate(loadCtx, key, uri, version, item, format, compression, bufferSize, keyName, passphrase, salt, keys, s3_client, verbose)
+		})
+		if !g.cache.staleWhileRevalidate {
+			select {
+			case res := <-revalidating:
+				r := res.Val.(*revalidation)
+				item, found = r.obj, !r.reloaded
+				if r.reloaded {
+					if err := checkSlice(item); err != nil {
+						return false, item, err
+					}
+					return false, item, nil
+				}
+			case <-ctx.Done():
+				return false, nil, ctx.Err()
+			}
+		}
+	}
+	if found {
+		if err := checkSlice(item); err != nil {
+			return true, item, err
+		}
+		return true, item, nil
+	}
+
+	// the load is shared by concurrent requests, so it runs on a context that is not canceled with the request that started it.
+	// A request that is canceled stops waiting, while the load continues for the other requests.
+	load := g.cache.flights.DoChan(key, func() (interface{}, error) {
+		loadCtx, cancel := g.cache.detach(ctx)
+		defer cancel()
+		return g.load(loadCtx, key, uri, format, compression, bufferSize, keyName, passphrase, salt, keys, s3_client, verbose)
+	})
+	select {
+	case res := <-load:
+		if res.Err != nil {
+			return false, nil, res.Err
+		}
+		if err := checkSlice(res.Val); err != nil {
+			return false, res.Val, err
+		}
+		return false, res.Val, nil
+	case <-ctx.Done():
+		return false, nil, ctx.Err()
+	}
+}
+
+// checkSlice returns an error if the object is not an array or slice, so hits and misses return the same types.
+func checkSlice(obj interface{}) error {
+	if t := reflect.TypeOf(obj); t == nil || !(t.Kind() == reflect.Array || t.Kind() == reflect.Slice) {
+		return errors.New("object retrieved from cache was not an array or slice but " + fmt.Sprint(t))
+	}
+	return nil
+}
+
+// detach returns a context for a load shared by concurrent requests, which keeps the span of the request that started it,
+// but is only canceled once the load timeout of the cache has elapsed.
+func (c *Cache) detach(ctx context.Context) (context.Context, context.CancelFunc) {
+	detached := trace.ContextWithSpan(context.Background(), trace.SpanFromContext(ctx))
+	if c.LoadTimeout > 0 {
+		return context.WithTimeout(detached, c.LoadTimeout)
+	}
+	return context.WithCancel(detached)
+}
+
+type revalidation struct {
+	obj      interface{}
+	reloaded bool
+}
+
+// revalidate reloads the object if the version of the data store has changed since the object was cached.
+// If the version cannot be checked or the object cannot be reloaded, then the cached object is kept until the next interval.
+func (g *Group) revalidate(ctx context.Context, key string, uri string, version string, item interface{}, format string, compression string, bufferSize int, keyName string, passphrase string, salt string, keys util.KeyResolver, s3_client *s3.S3, verbose bool) (*revalidation, error) {
+
+	ctx, span := tracing.Start(ctx, "cache.Revalidate", tracing.AttributeDataStoreUri.String(uri))
+	var err error
+	defer func() {
+		tracing.End(span, err)
+	}()
+
+	current, err := Version(ctx, uri, s3_client)
+	if err != nil || current == version {
+		g.cache.touch(key)
+		return &revalidation{obj: item}, nil
+	}
+
+	obj, err := g.load(ctx, key, uri, format, compression, bufferSize, keyName, passphrase, salt, keys, s3_client, verbose)
+	if err != nil {
+		g.cache.touch(key)
+		return &revalidation{obj: item}, nil
+	}
+
+	return &revalidation{obj: obj, reloaded: true}, nil
+}
+
+// load reads and deserializes the object from the data store and adds it to the cache.
+func (g *Group) load(ctx context.Context, key string, uri string, format string, compression string, bufferSize int, keyName string, passphrase string, salt string, keys util.KeyResolver, s3_client *s3.S3, verbose bool) (interface{}, error) {
+
+	version := ""
+	if g.cache.revalidateInterval > 0 || g.cache.Disk != nil {
+		// the version is read before the data, so a change while reading is caught by the next revalidation.
+		if v, err := Version(ctx, uri, s3_client); err == nil {
+			version = v
+		}
+	}
+
+	// data without a version is never read from disk, since it could be out of date.
+	var inputByte []byte
+	if g.cache.Disk != nil && len(version) > 0 {
+		_, span := tracing.Start(ctx, "cache.ReadDisk", tracing.AttributeDataStoreUri.String(uri))
+		b, ok := g.cache.Disk.Get(key, version)
+		span.SetAttributes(tracing.AttributeCacheHit.Bool(ok))
+		tracing.End(span, nil)
+		if ok {
+			inputByte = b
+		}
+	}
+
+	// only reads of the data store are observed, so reads from disk do not count.
+	start := time.Now()
+	fromDataStore := inputByte == nil
+
+	if inputByte == nil {
+		b, err := read(ctx, uri, compression, bufferSize, s3_client)
+		if err != nil {
+			return nil, err
+		}
+		inputByte = b
+		if g.cache.Disk != nil && len(version) > 0 {
+			// the disk cache is best effort, so errors are only counted in its statistics.
+			g.cache.Disk.Put(key, version, inputByte)
+		}
+	}
+
+	// the data is decrypted after the disk cache, so the disk cache only holds encrypted data.
+	inputBytePlain, err := util.DecryptBytes(inputByte, passphrase, salt, len(keyName) > 0, keys)
+	if err != nil {
+		return nil, errors.Wrap(err, "error decrypting data from uri "+uri)
+	}
+
+	obj, err := deserialize(ctx, inputBytePlain, format, verbose)
+	if err != nil {
+		return nil, err
+	}
+
+	if fromDataStore && g.cache.ObserveRead != nil {
+		g.cache.ObserveRead(uri, time.Since(start))
+	}
+
+	g.cache.set(g.Name, key, uri, obj, version, g.Ttl, g.Pinned)
+
+	return obj, nil
+}
+
+// Stats returns the statistics of the group.
+func (g *Group) Stats() Stats {
+	return g.cache.GroupStats(g.Name)
+}
+
+func read(ctx context.Context, uri string, compression string, bufferSize int, s3_client *s3.S3) (b []byte, err error) {
+
+	_, span := tracing.Start(ctx, "datastore.Read", tracing.AttributeDataStoreUri.String(uri))
+	defer func() {
+		span.SetAttributes(tracing.AttributeBytes.Int(len(b)))
+		tracing.End(span, err)
+	}()
+
+	inputReader, _, err := grw.ReadFromResource(
+		uri,
+		compression,
+		bufferSize,
+		false,
+		s3_client)
+	if err != nil {
+		return nil, errors.Wrap(err, "error opening resource at uri "+uri)
+	}
+
+	b, err = inputReader.ReadAllAndClose()
+	if err != nil {
+		return nil, errors.New("error reading from resource at uri " + uri)
+	}
+
+	return b, nil
+}
+
+func deserialize(ctx context.Context, b []byte, format string, verbose bool) (obj interface{}, err error) {
+
+	_, span := tracing.Start(ctx, "gss.Deserialize", tracing.AttributeFormat.String(format), tracing.AttributeBytes.Int(len(b)))
+	defer func() {
+		tracing.End(span, err)
+	}()
+
+	inputType, err := gss.GetType(b, format)
+	if err != nil {
+		return nil, errors.Wrap(err, "error getting type for input")
+	}
+
+	obj, err = gss.DeserializeBytes(b, format, []string{}, "", false, gss.NoSkip, gss.NoLimit, inputType, verbose)
+	if err != nil {
+		return nil, errors.Wrap(err, "error deserializing input using format "+format)
+	}
+
+	return obj, nil
+}
@@ -0,0 +1,39 @@
+// =================================================================
+//
+// Copyright (C) 2018 Spatial Current, Inc. - All Rights Reserved
+// Released as open source under the MIT License.  See LICENSE file.
+//
+// =================================================================
+
+package cache
+
+// Stats are the statistics of a cache or of a group within a cache.
+// Bytes is the estimated size in memory of the deserialized items.
+type Stats struct {
+	Items       int   `json:"items" yaml:"items"`
+	Bytes       int64 `json:"bytes" yaml:"bytes"`
+	Hits        int64 `json:"hits" yaml:"hits"`
+	Misses      int64 `json:"misses" yaml:"misses"`
+	Evictions   int64 `json:"evictions" yaml:"evictions"`
+	Expirations int64 `json:"expirations" yaml:"expirations"`
+}
+
+func (s *Stats) add(o *Stats) {
+	s.Items += o.Items
+	s.Bytes += o.Bytes
+	s.Hits += o.Hits
+	s.Misses += o.Misses
+	s.Evictions += o.Evictions
+	s.Expirations += o.Expirations
+}
+
+func (s Stats) Map() map[string]interface{} {
+	return map[string]interface{}{
+		"items":       s.Items,
+		"bytes":       s.Bytes,
+		"hits":        s.Hits,
+		"misses":      s.Misses,
+		"evictions":   s.Evictions,
+		"expirations": s.Expirations,
+	}
+}
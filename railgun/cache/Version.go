@@ -0,0 +1,88 @@
+// =================================================================
+//
+// Copyright (C) 2018 Spatial Current, Inc. - All Rights Reserved
+// Released as open source under the MIT License.  See LICENSE file.
+//
+// =================================================================
+
+package cache
+
+import (
+	"context"
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/mitchellh/go-homedir"
+	"github.com/pkg/errors"
+	"github.com/spatialcurrent/go-reader-writer/grw"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// CanVersion returns true if the version of resources with the given scheme can be read.
+func CanVersion(scheme string) bool {
+	switch scheme {
+	case "s3", "http", "https", "", "file":
+		return true
+	}
+	return false
+}
+
+// Version returns a string that changes when the resource at the uri changes.
+// The version is the ETag of S3 objects and HTTP resources, falling back to the last modified time,
+// and the modification time and size of files.
+// Returns an empty string if the version of the resource cannot be known.
+// Returns an error if the resource cannot be reached, so it is also used to check that a resource is available.
+func Version(ctx context.Context, uri string, s3_client *s3.S3) (string, error) {
+	scheme, path := grw.SplitUri(uri)
+	switch scheme {
+	case "s3":
+		if s3_client == nil {
+			return "", nil
+		}
+		parts := strings.SplitN(path, "/", 2)
+		if len(parts) != 2 {
+			return "", errors.New("path missing bucket")
+		}
+		output, err := s3_client.HeadObjectWithContext(ctx, &s3.HeadObjectInput{Bucket: aws.String(parts[0]), Key: aws.String(parts[1])})
+		if err != nil {
+			return "", errors.Wrap(err, "error heading "+uri)
+		}
+		if etag := aws.StringValue(output.ETag); len(etag) > 0 {
+			return etag, nil
+		}
+		if output.LastModified != nil {
+			return fmt.Sprint(output.LastModified.UnixNano()), nil
+		}
+		return "", nil
+	case "http", "https":
+		req, err := http.NewRequest("HEAD", uri, nil)
+		if err != nil {
+			return "", errors.Wrap(err, "error creating request for "+uri)
+		}
+		resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+		if err != nil {
+			return "", errors.Wrap(err, "error requesting "+uri)
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= http.StatusBadRequest {
+			return "", errors.New(fmt.Sprintf("%s returned %d", uri, resp.StatusCode))
+		}
+		if etag := resp.Header.Get("ETag"); len(etag) > 0 {
+			return etag, nil
+		}
+		return resp.Header.Get("Last-Modified"), nil
+	case "", "file":
+		pathExpanded, err := homedir.Expand(path)
+		if err != nil {
+			return "", errors.Wrap(err, "error expanding path "+path)
+		}
+		info, err := os.Stat(pathExpanded)
+		if err != nil {
+			return "", errors.Wrap(err, "error stating "+path)
+		}
+		return fmt.Sprintf("%d-%d", info.ModTime().UnixNano(), info.Size()), nil
+	}
+	return "", nil
+}
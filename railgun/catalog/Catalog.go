@@ -119,12 +119,13 @@ func (c *Catalog) List(t reflect.Type) interface{} {
 		if list, ok := c.objects[t.Elem().Name()]; ok {
 			return list
 		}
-	} else {
-		if list, ok := c.objects[t.Name()]; ok {
-			return list
-		}
+		return reflect.MakeSlice(reflect.SliceOf(t), 0, 0).Interface()
+	}
+	if list, ok := c.objects[t.Name()]; ok {
+		return list
 	}
-	return reflect.MakeSlice(reflect.SliceOf(t), 0, 0).Interface()
+	// objects are stored as pointers
+	return reflect.MakeSlice(reflect.SliceOf(reflect.PtrTo(t)), 0, 0).Interface()
 }
 
 func (c *Catalog) Dump() map[string]interface{} {
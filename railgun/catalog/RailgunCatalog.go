@@ -27,13 +27,16 @@ import (
 	"reflect"
 	"strings"
 	"sync"
+	"time"
 )
 
 type RailgunCatalog struct {
 	*Catalog
+	Cache  *cache.Cache // the cache shared by the layers
+	Loaded time.Time    // when the catalog was last loaded from a uri
 }
 
-func NewRailgunCatalog() *RailgunCatalog {
+func NewRailgunCatalog(layerCache *cache.Cache) *RailgunCatalog {
 
 	catalog := &RailgunCatalog{
 		Catalog: &Catalog{
@@ -41,6 +44,7 @@ func NewRailgunCatalog() *RailgunCatalog {
 			objects: map[string]interface{}{},
 			indices: map[string]map[string]int{},
 		},
+		Cache: layerCache,
 	}
 
 	return catalog
@@ -97,6 +101,11 @@ func (c *RailgunCatalog) ParseDataStore(obj interface{}) (*core.DataStore, error
 	if err != nil {
 		return &core.DataStore{}, err
 	}
+	critical, err := parser.ParseBool(obj, "critical")
+	if err != nil {
+		return &core.DataStore{}, err
+	}
+	key := gtg.TryGetString(obj, "key", "")
 	workspaceName := gtg.TryGetString(obj, "workspace", "")
 	if len(workspaceName) == 0 {
 		return &core.DataStore{}, &rerrors.ErrMissingRequiredParameter{Name: "workspace"}
@@ -114,6 +123,8 @@ func (c *RailgunCatalog) ParseDataStore(obj interface{}) (*core.DataStore, error
 		Format:      format,
 		Compression: compression,
 		Extent:      extent,
+		Critical:    critical,
+		Key:         key,
 	}
 	return ds, nil
 }
@@ -145,6 +156,14 @@ func (c *RailgunCatalog) ParseLayer(obj interface{}) (*core.Layer, error) {
 	if err != nil {
 		return &core.Layer{}, err
 	}
+	ttl, err := parser.ParseDuration(obj, "ttl")
+	if err != nil {
+		return &core.Layer{}, err
+	}
+	pinned, err := parser.ParseBool(obj, "pinned")
+	if err != nil {
+		return &core.Layer{}, err
+	}
 	lyr := &core.Layer{
 		Name:        name,
 		Title:       coalesce(title, name),
@@ -154,7 +173,9 @@ func (c *RailgunCatalog) ParseLayer(obj interface{}) (*core.Layer, error) {
 		Defaults:    defaults,
 		Extent:      extent,
 		Tags:        tags,
-		Cache:       cache.NewCache(),
+		Ttl:         ttl,
+		Pinned:      pinned,
+		Cache:       c.Cache.Group(name, ttl, pinned),
 	}
 	expression := gtg.TryGetString(obj, "expression", "")
 	if len(expression) > 0 {
@@ -186,12 +207,17 @@ func (c *RailgunCatalog) ParseProcess(obj interface{}) (*core.Process, error) {
 	if err != nil {
 		return &core.Process{}, err
 	}
+	parameters, err := parseParameters(obj)
+	if err != nil {
+		return &core.Process{}, err
+	}
 	p := &core.Process{
 		Name:        name,
 		Title:       coalesce(title, name),
 		Description: coalesce(description, title, name),
 		Node:        node,
 		Tags:        tags,
+		Parameters:  parameters,
 	}
 	return p, nil
 }
@@ -262,6 +288,37 @@ func (c *RailgunCatalog) ParseJob(obj interface{}) (*core.Job, error) {
 		Service:     service,
 		Variables:   variables,
 	}
+	input := gtg.TryGetString(obj, "input", "")
+	if len(input) > 0 {
+		node, err := dfl.ParseCompile(input)
+		if err != nil {
+			return &core.Job{}, errors.Wrap(err, "error parsing job input")
+		}
+		j.Input = node
+	}
+	outputName := gtg.TryGetString(obj, "output", "")
+	if len(outputName) > 0 {
+		output, found := c.GetDataStore(outputName)
+		if !found {
+			return &core.Job{}, &rerrors.ErrMissingObject{Type: "datastore", Name: outputName}
+		}
+		j.Output = output
+	}
+	retry, err := parseRetry(obj)
+	if err != nil {
+		return &core.Job{}, errors.Wrap(err, "error parsing job retry")
+	}
+	j.Retry = retry
+	timeout, err := parseTimeout(obj)
+	if err != nil {
+		return &core.Job{}, errors.Wrap(err, "error parsing job timeout")
+	}
+	j.Timeout = timeout
+	schedule, err := parseSchedule(obj)
+	if err != nil {
+		return &core.Job{}, errors.Wrap(err, "error parsing job schedule")
+	}
+	j.Schedule = schedule
 	return j, nil
 }
 
@@ -284,12 +341,27 @@ func (c *RailgunCatalog) ParseWorkflow(obj interface{}) (*core.Workflow, error)
 	if err != nil {
 		return &core.Workflow{}, err
 	}
+	retry, err := parseRetry(obj)
+	if err != nil {
+		return &core.Workflow{}, errors.Wrap(err, "error parsing workflow retry")
+	}
+	timeout, err := parseTimeout(obj)
+	if err != nil {
+		return &core.Workflow{}, errors.Wrap(err, "error parsing workflow timeout")
+	}
+	schedule, err := parseSchedule(obj)
+	if err != nil {
+		return &core.Workflow{}, errors.Wrap(err, "error parsing workflow schedule")
+	}
 	wf := &core.Workflow{
 		Name:        name,
 		Title:       coalesce(title, name),
 		Description: coalesce(description, title, name),
 		Variables:   variables,
 		Jobs:        jobs,
+		Retry:       retry,
+		Timeout:     timeout,
+		Schedule:    schedule,
 	}
 	return wf, nil
 }
@@ -428,6 +500,11 @@ func (c *RailgunCatalog) DeleteDataStore(name string) error {
 			return &rerrors.ErrDependent{DependentType: "service", DependentName: s.Name, Type: "data store", Name: name}
 		}
 	}
+	for _, j := range c.ListJobs() {
+		if j.Output != nil && j.Output.Name == name {
+			return &rerrors.ErrDependent{DependentType: "job", DependentName: j.Name, Type: "data store", Name: name}
+		}
+	}
 	return c.Delete(name, core.DataStoreType)
 }
 
@@ -578,6 +655,8 @@ func (c *RailgunCatalog) LoadFromUri(uri string, logWriter grw.ByteWriteCloser,
 		return errors.Wrap(err, "error loading catalog")
 	}
 
+	c.Loaded = time.Now()
+
 	if raw == nil {
 		logWriter.WriteLine(fmt.Sprint("* catalog was empty"))
 		return nil
@@ -0,0 +1,133 @@
+// =================================================================
+//
+// Copyright (C) 2018 Spatial Current, Inc. - All Rights Reserved
+// Released as open source under the MIT License.  See LICENSE file.
+//
+// =================================================================
+
+package catalog
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/spatialcurrent/go-adaptive-functions/af"
+	"github.com/spatialcurrent/go-try-get/gtg"
+	"github.com/spatialcurrent/railgun/railgun/core"
+	rerrors "github.com/spatialcurrent/railgun/railgun/errors"
+	"github.com/spatialcurrent/railgun/railgun/parser"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// parseParameters parses the optional parameters of a process,
+// which is a dictionary of variable name to declaration, e.g., {zoom: {type: int, minimum: 0, maximum: 18, required: true}}.
+// The parameters are returned sorted by name.
+func parseParameters(obj interface{}) ([]*core.Parameter, error) {
+	m, err := parser.ParseMap(obj, "parameters")
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	parameters := make([]*core.Parameter, 0, len(names))
+	for _, name := range names {
+		p, err := parseParameter(name, m[name])
+		if err != nil {
+			return nil, err
+		}
+		parameters = append(parameters, p)
+	}
+	return parameters, nil
+}
+
+func parseParameter(name string, obj interface{}) (*core.Parameter, error) {
+	prefix := "parameters." + name + "."
+
+	// a declaration can be just the type, e.g., {name: string}
+	if t, ok := obj.(string); ok {
+		obj = map[string]interface{}{"type": t}
+	}
+
+	p := &core.Parameter{
+		Name:        name,
+		Type:        gtg.TryGetString(obj, "type", ""),
+		Description: gtg.TryGetString(obj, "description", ""),
+		Default:     gtg.TryGet(obj, "default", nil),
+	}
+
+	if len(p.Type) == 0 {
+		return nil, &rerrors.ErrMissingRequiredParameter{Name: prefix + "type"}
+	}
+	valid := false
+	for _, t := range core.ParameterTypes {
+		if p.Type == t {
+			valid = true
+			break
+		}
+	}
+	if !valid {
+		return nil, &rerrors.ErrInvalidParameter{Name: prefix + "type", Value: p.Type}
+	}
+
+	if v := gtg.TryGet(obj, "required", nil); v != nil {
+		required, err := strconv.ParseBool(fmt.Sprint(v))
+		if err != nil {
+			return nil, &rerrors.ErrInvalidParameter{Name: prefix + "required", Value: v}
+		}
+		p.Required = required
+	}
+
+	for _, bound := range []string{"minimum", "maximum"} {
+		v := gtg.TryGet(obj, bound, nil)
+		if v == nil {
+			continue
+		}
+		f, err := strconv.ParseFloat(fmt.Sprint(v), 64)
+		if err != nil {
+			return nil, &rerrors.ErrInvalidParameter{Name: prefix + bound, Value: v}
+		}
+		if bound == "minimum" {
+			p.Minimum = &f
+		} else {
+			p.Maximum = &f
+		}
+	}
+	if p.Minimum != nil && p.Maximum != nil && *p.Minimum > *p.Maximum {
+		return nil, &rerrors.ErrInvalidParameter{Name: prefix + "minimum", Value: *p.Minimum}
+	}
+
+	if v := gtg.TryGet(obj, "values", nil); v != nil {
+		if str, ok := v.(string); ok {
+			for _, value := range strings.Split(str, ",") {
+				if value = strings.TrimSpace(value); len(value) > 0 {
+					p.Values = append(p.Values, value)
+				}
+			}
+		} else {
+			strs, err := af.ToStringArray.ValidateRun([]interface{}{v})
+			if err != nil {
+				return nil, errors.Wrap(err, (&rerrors.ErrInvalidParameter{Name: prefix + "values", Value: v}).Error())
+			}
+			p.Values = strs.([]string)
+		}
+	}
+	if p.Type == core.ParameterTypeEnum && len(p.Values) == 0 {
+		return nil, &rerrors.ErrMissingRequiredParameter{Name: prefix + "values"}
+	}
+
+	if p.Default != nil {
+		d, err := p.Convert(p.Default)
+		if err != nil {
+			return nil, errors.Wrap(err, (&rerrors.ErrInvalidParameter{Name: prefix + "default", Value: p.Default}).Error())
+		}
+		p.Default = d
+	}
+
+	return p, nil
+}
@@ -0,0 +1,110 @@
+// =================================================================
+//
+// Copyright (C) 2018 Spatial Current, Inc. - All Rights Reserved
+// Released as open source under the MIT License.  See LICENSE file.
+//
+// =================================================================
+
+package catalog
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/spatialcurrent/go-adaptive-functions/af"
+	"github.com/spatialcurrent/go-try-get/gtg"
+	"github.com/spatialcurrent/railgun/railgun/core"
+	rerrors "github.com/spatialcurrent/railgun/railgun/errors"
+	"github.com/spatialcurrent/railgun/railgun/parser"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// parseRetry parses the optional retry policy of a job or workflow.
+// Returns nil if no retry policy is defined.
+func parseRetry(obj interface{}) (*core.Retry, error) {
+	if len(gtg.TryGetString(obj, "retry", "")) == 0 {
+		return nil, nil
+	}
+	m, err := parser.ParseMap(obj, "retry")
+	if err != nil {
+		return nil, err
+	}
+
+	r := &core.Retry{
+		Attempts:   gtg.TryGetInt(m, "attempts", 1),
+		Backoff:    time.Second,
+		Multiplier: 2.0,
+		RetryOn:    core.DefaultRetryOn,
+	}
+	if r.Attempts < 1 {
+		return nil, &rerrors.ErrInvalidParameter{Name: "retry.attempts", Value: r.Attempts}
+	}
+
+	if str := gtg.TryGetString(m, "backoff", ""); len(str) > 0 {
+		r.Backoff, err = time.ParseDuration(str)
+		if err != nil {
+			return nil, errors.Wrap(err, (&rerrors.ErrInvalidParameter{Name: "retry.backoff", Value: str}).Error())
+		}
+	}
+
+	if str := gtg.TryGetString(m, "maxBackoff", ""); len(str) > 0 {
+		r.MaxBackoff, err = time.ParseDuration(str)
+		if err != nil {
+			return nil, errors.Wrap(err, (&rerrors.ErrInvalidParameter{Name: "retry.maxBackoff", Value: str}).Error())
+		}
+	}
+
+	if v := gtg.TryGet(m, "multiplier", nil); v != nil {
+		r.Multiplier, err = strconv.ParseFloat(fmt.Sprint(v), 64)
+		if err != nil || r.Multiplier < 1.0 {
+			return nil, &rerrors.ErrInvalidParameter{Name: "retry.multiplier", Value: v}
+		}
+	}
+
+	if v := gtg.TryGet(m, "retryOn", nil); v != nil {
+		retryOn := make([]string, 0)
+		if str, ok := v.(string); ok {
+			for _, c := range strings.Split(str, ",") {
+				if c = strings.TrimSpace(c); len(c) > 0 {
+					retryOn = append(retryOn, c)
+				}
+			}
+		} else {
+			strs, err := af.ToStringArray.ValidateRun([]interface{}{v})
+			if err != nil {
+				return nil, errors.Wrap(err, (&rerrors.ErrInvalidParameter{Name: "retry.retryOn", Value: v}).Error())
+			}
+			retryOn = strs.([]string)
+		}
+		for _, c := range retryOn {
+			valid := false
+			for _, class := range core.ErrorClasses {
+				if c == class {
+					valid = true
+					break
+				}
+			}
+			if !valid {
+				return nil, &rerrors.ErrInvalidParameter{Name: "retry.retryOn", Value: c}
+			}
+		}
+		r.RetryOn = retryOn
+	}
+
+	return r, nil
+}
+
+// parseTimeout parses the optional timeout of a job or workflow.
+// Returns zero if no timeout is defined.
+func parseTimeout(obj interface{}) (time.Duration, error) {
+	str := gtg.TryGetString(obj, "timeout", "")
+	if len(str) == 0 {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(str)
+	if err != nil {
+		return 0, errors.Wrap(err, (&rerrors.ErrInvalidParameter{Name: "timeout", Value: str}).Error())
+	}
+	return d, nil
+}
@@ -0,0 +1,45 @@
+// =================================================================
+//
+// Copyright (C) 2018 Spatial Current, Inc. - All Rights Reserved
+// Released as open source under the MIT License.  See LICENSE file.
+//
+// =================================================================
+
+package catalog
+
+import (
+	"github.com/pkg/errors"
+	"github.com/spatialcurrent/go-try-get/gtg"
+	"github.com/spatialcurrent/railgun/railgun/core"
+	rerrors "github.com/spatialcurrent/railgun/railgun/errors"
+	"github.com/spatialcurrent/railgun/railgun/parser"
+	"time"
+)
+
+// parseSchedule parses the optional schedule of a job or workflow.
+// Returns nil if no schedule is defined.
+func parseSchedule(obj interface{}) (*core.Schedule, error) {
+	if len(gtg.TryGetString(obj, "schedule", "")) == 0 {
+		return nil, nil
+	}
+	m, err := parser.ParseMap(obj, "schedule")
+	if err != nil {
+		return nil, err
+	}
+	expression := gtg.TryGetString(m, "cron", "")
+	if len(expression) == 0 {
+		return nil, &rerrors.ErrMissingRequiredParameter{Name: "schedule.cron"}
+	}
+	jitter := time.Duration(0)
+	if str := gtg.TryGetString(m, "jitter", ""); len(str) > 0 {
+		jitter, err = time.ParseDuration(str)
+		if err != nil {
+			return nil, errors.Wrap(err, (&rerrors.ErrInvalidParameter{Name: "schedule.jitter", Value: str}).Error())
+		}
+	}
+	s, err := core.NewSchedule(expression, gtg.TryGetString(m, "timezone", ""), gtg.TryGetString(m, "overlap", ""), jitter)
+	if err != nil {
+		return nil, errors.Wrap(err, (&rerrors.ErrInvalidParameter{Name: "schedule", Value: m}).Error())
+	}
+	return s, nil
+}
@@ -0,0 +1,471 @@
+// =================================================================
+//
+// Copyright (C) 2018 Spatial Current, Inc. - All Rights Reserved
+// Released as open source under the MIT License.  See LICENSE file.
+//
+// =================================================================
+
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/mitchellh/go-homedir"
+	"github.com/pkg/errors"
+	"github.com/spatialcurrent/go-reader-writer/grw"
+	"github.com/spatialcurrent/railgun/railgun/cache"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// checkpointInput is the progress of an input.
+// Lines is the number of input lines that have been completely processed and Offset is the number of bytes of input they span.
+type checkpointInput struct {
+	Lines  int   `json:"lines"`
+	Offset int64 `json:"offset"`
+}
+
+// checkpointSource is an input of a streaming run and its version, e.g., the ETag of an object or the modification time and size of a file.
+// The version is blank if it cannot be known, e.g., for stdin.
+type checkpointSource struct {
+	Uri     string `json:"uri"`
+	Version string `json:"version"`
+}
+
+// checkpoint is the state of a streaming run, which is saved periodically so the run can be resumed after a crash.
+// Sources are the expanded inputs of the run, which must match when resuming.
+// Inputs is the progress of each input and Outputs is the size of each local output file when the checkpoint was saved.
+// Renames are the finished output files to rename to their final path once the checkpoint is saved.
+type checkpoint struct {
+	Sources   []*checkpointSource         `json:"sources"`
+	Inputs    map[string]*checkpointInput `json:"inputs"`
+	Outputs   map[string]int64            `json:"outputs"`
+	Renames   map[string]string           `json:"renames"`
+	Processed int64                       `json:"processed"`
+	Emitted   int64                       `json:"emitted"`
+	Filtered  int64                       `json:"filtered"`
+	Failed    int64                       `json:"failed"`
+	Complete  bool                        `json:"complete"`
+	Updated   time.Time                   `json:"updated"`
+}
+
+// checkpointer saves checkpoints of a streaming run.
+// Every line read is added to the lines in flight and is done once it is filtered out, or once its output or error is written.
+// A checkpoint is only saved when reading is paused and no lines are in flight,
+// so the output files hold exactly the output of the lines in the checkpoint.
+// Local output files are synced before the checkpoint is saved and truncated to their size in the checkpoint on resume,
+// so output is written exactly once.  Other outputs are written at least once.
+type checkpointer struct {
+	uri       string
+	interval  time.Duration
+	s3_client *s3.S3
+	mutex     *sync.Mutex
+	pause     *sync.RWMutex
+	inflight  *sync.WaitGroup
+	state     *checkpoint
+	persisted *checkpoint // the checkpoint as last written
+	saved     time.Time
+	flushers  []func() (map[string]string, error)
+}
+
+// clone returns a copy of the checkpoint, so it can be written while the state changes.
+func (c *checkpoint) clone() *checkpoint {
+	n := *c
+	n.Inputs = make(map[string]*checkpointInput, len(c.Inputs))
+	for uri, i := range c.Inputs {
+		n.Inputs[uri] = &checkpointInput{Lines: i.Lines, Offset: i.Offset}
+	}
+	n.Outputs = make(map[string]int64, len(c.Outputs))
+	for path, size := range c.Outputs {
+		n.Outputs[path] = size
+	}
+	n.Renames = make(map[string]string, len(c.Renames))
+	for partial, final := range c.Renames {
+		n.Renames[partial] = final
+	}
+	return &n
+}
+
+// syncFile commits the file at the path to stable storage.
+func syncFile(path string) error {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// syncDir commits the entries of the directory to stable storage, so renames survive a crash.
+func syncDir(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return f.Sync()
+}
+
+// checkpointSources returns the sources for the expanded input uris with their current versions.
+func checkpointSources(uris []string, s3_client *s3.S3) ([]*checkpointSource, error) {
+	sources := make([]*checkpointSource, 0, len(uris))
+	for _, uri := range uris {
+		source := &checkpointSource{Uri: uri}
+		if uri != "stdin" && !strings.HasPrefix(uri, "athena://") {
+			version, err := cache.Version(context.Background(), uri, s3_client)
+			if err != nil {
+				return nil, errors.Wrap(err, "error getting version of input "+uri)
+			}
+			source.Version = version
+		}
+		sources = append(sources, source)
+	}
+	return sources, nil
+}
+
+func newCheckpointer(uri string, interval time.Duration, sources []*checkpointSource, s3_client *s3.S3) *checkpointer {
+	c := &checkpointer{
+		uri:       uri,
+		interval:  interval,
+		s3_client: s3_client,
+		mutex:     &sync.Mutex{},
+		pause:     &sync.RWMutex{},
+		inflight:  &sync.WaitGroup{},
+		state:     &checkpoint{Sources: sources, Inputs: map[string]*checkpointInput{}, Outputs: map[string]int64{}, Renames: map[string]string{}},
+		saved:     time.Now(),
+	}
+	c.persisted = c.state.clone()
+	return c
+}
+
+// loadCheckpoint returns the checkpoint at the uri, or nil if there is no checkpoint.
+func loadCheckpoint(uri string, s3_client *s3.S3) (*checkpoint, error) {
+	scheme, path := grw.SplitUri(uri)
+	var b []byte
+	if scheme == "" || scheme == "file" {
+		pathExpanded, err := homedir.Expand(path)
+		if err != nil {
+			return nil, errors.Wrap(err, "error expanding path "+path)
+		}
+		b, err = ioutil.ReadFile(pathExpanded)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil, nil
+			}
+			return nil, errors.Wrap(err, "error reading checkpoint at "+uri)
+		}
+	} else {
+		if scheme == "s3" && s3_client != nil {
+			parts := strings.SplitN(path, "/", 2)
+			if len(parts) != 2 {
+				return nil, errors.New("path missing bucket")
+			}
+			_, err := s3_client.HeadObject(&s3.HeadObjectInput{Bucket: aws.String(parts[0]), Key: aws.String(parts[1])})
+			if err != nil {
+				if aerr, ok := err.(awserr.Error); ok && aerr.Code() == "NotFound" {
+					return nil, nil
+				}
+				return nil, errors.Wrap(err, "error heading checkpoint at "+uri)
+			}
+		}
+		reader, _, err := grw.ReadFromResource(uri, "", 4096, false, s3_client)
+		if err != nil {
+			return nil, errors.Wrap(err, "error opening checkpoint at "+uri)
+		}
+		b, err = reader.ReadAllAndClose()
+		if err != nil {
+			return nil, errors.Wrap(err, "error reading checkpoint at "+uri)
+		}
+	}
+	c := &checkpoint{}
+	if err := json.Unmarshal(b, c); err != nil {
+		return nil, errors.Wrap(err, "error parsing checkpoint at "+uri)
+	}
+	if c.Inputs == nil {
+		c.Inputs = map[string]*checkpointInput{}
+	}
+	if c.Outputs == nil {
+		c.Outputs = map[string]int64{}
+	}
+	if c.Renames == nil {
+		c.Renames = map[string]string{}
+	}
+	return c, nil
+}
+
+// Resume continues from the checkpoint by finishing the renames of the checkpoint
+// and truncating the local output files to their size in the checkpoint,
+// which removes the output of lines that were processed after the checkpoint was saved, and restoring the summary.
+// Returns an error if the inputs or their versions do not match the checkpoint, since lines would be skipped that were never processed,
+// or if an output file is shorter than its size in the checkpoint, since output would be lost.
+func (c *checkpointer) Resume(state *checkpoint, summary *streamSummary) error {
+	if len(state.Sources) != len(c.state.Sources) {
+		return errors.New(fmt.Sprintf("cannot resume because the checkpoint is for %d inputs, but %d inputs were given", len(state.Sources), len(c.state.Sources)))
+	}
+	for i, source := range c.state.Sources {
+		if state.Sources[i].Uri != source.Uri {
+			return errors.New("cannot resume because input " + source.Uri + " does not match input " + state.Sources[i].Uri + " in the checkpoint")
+		}
+		if state.Sources[i].Version != source.Version {
+			return errors.New("cannot resume because input " + source.Uri + " has changed since the checkpoint was saved")
+		}
+	}
+	c.persisted = state.clone()
+	c.state = state
+	if err := c.rename(); err != nil {
+		return err
+	}
+	for path, size := range state.Outputs {
+		info, err := os.Stat(path)
+		if err != nil {
+			if os.IsNotExist(err) && size == 0 {
+				continue
+			}
+			return errors.Wrap(err, "cannot resume with output file "+path)
+		}
+		if info.Size() < size {
+			return errors.New("cannot resume because output file " + path + " is shorter than its size in the checkpoint")
+		}
+		if err := os.Truncate(path, size); err != nil {
+			return errors.Wrap(err, "error truncating output file "+path)
+		}
+	}
+	summary.Processed = state.Processed
+	summary.Emitted = state.Emitted
+	summary.Filtered = state.Filtered
+	summary.Failed = state.Failed
+	return nil
+}
+
+// Lines returns the number of lines of the input processed before the checkpoint, which are skipped when resuming.
+func (c *checkpointer) Lines(uri string) int {
+	if c == nil {
+		return 0
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if i, ok := c.state.Inputs[uri]; ok {
+		return i.Lines
+	}
+	return 0
+}
+
+// Begin adds a line to the lines in flight.  Begin blocks while a checkpoint is being saved.
+func (c *checkpointer) Begin() {
+	if c != nil {
+		c.pause.RLock()
+		c.inflight.Add(1)
+	}
+}
+
+// End records the progress of the input once the line has been sent for processing.
+func (c *checkpointer) End(uri string, lines int, offset int64) {
+	if c != nil {
+		c.mutex.Lock()
+		c.state.Inputs[uri] = &checkpointInput{Lines: lines, Offset: offset}
+		c.mutex.Unlock()
+		c.pause.RUnlock()
+	}
+}
+
+// Cancel removes a line from the lines in flight without recording the progress of the input, since the line was never sent.
+func (c *checkpointer) Cancel() {
+	if c != nil {
+		c.inflight.Done()
+		c.pause.RUnlock()
+	}
+}
+
+// Done marks a line in flight as done.
+func (c *checkpointer) Done() {
+	if c != nil {
+		c.inflight.Done()
+	}
+}
+
+// Track records the size of a local output file before it is first written to since the last checkpoint.
+// The size is added to the checkpoint as last written, without the progress of the inputs since,
+// so the file can be truncated on resume even if it was created after the last checkpoint.
+func (c *checkpointer) Track(uri string) error {
+	if c == nil {
+		return nil
+	}
+	scheme, path := grw.SplitUri(uri)
+	if scheme != "" && scheme != "file" {
+		return nil
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if _, ok := c.state.Outputs[path]; ok {
+		return nil
+	}
+	size := int64(0)
+	if info, err := os.Stat(path); err == nil {
+		size = info.Size()
+	}
+	c.state.Outputs[path] = size
+	c.persisted.Outputs[path] = size
+	return c.write(c.persisted)
+}
+
+// OnSave adds a function that is called before each checkpoint is saved, once no lines are in flight.
+// The function flushes output and returns the finished output files to rename to their final path once the checkpoint is saved.
+func (c *checkpointer) OnSave(f func() (map[string]string, error)) {
+	if c != nil {
+		c.mutex.Lock()
+		c.flushers = append(c.flushers, f)
+		c.mutex.Unlock()
+	}
+}
+
+// Due returns true if the interval since the last checkpoint has passed.
+func (c *checkpointer) Due() bool {
+	if c == nil {
+		return false
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return time.Since(c.saved) >= c.interval
+}
+
+// SaveIfDue saves the checkpoint if the interval since the last checkpoint has passed.
+// The checkpoint is not saved if another input saved it while waiting to pause.
+func (c *checkpointer) SaveIfDue(summary *streamSummary) error {
+	if !c.Due() {
+		return nil
+	}
+	return c.save(summary, false, true)
+}
+
+// Save pauses reading, waits for the lines in flight, and then saves the checkpoint with the sizes of the local output files.
+// Once saved, the finished output files are renamed to their final path.
+// The checkpoint is not saved once the stream is aborted.
+func (c *checkpointer) Save(summary *streamSummary, complete bool) error {
+	if c == nil {
+		return nil
+	}
+	return c.save(summary, complete, false)
+}
+
+func (c *checkpointer) save(summary *streamSummary, complete bool, ifDue bool) error {
+	c.pause.Lock()
+	defer c.pause.Unlock()
+	c.inflight.Wait()
+	if ifDue && !c.Due() {
+		return nil
+	}
+	// once aborted, lines are dropped without being processed, so the progress of the inputs is no longer accurate.
+	if summary.Aborted() {
+		return nil
+	}
+	c.mutex.Lock()
+	flushers := c.flushers
+	c.mutex.Unlock()
+	renames := map[string]string{}
+	for _, f := range flushers {
+		r, err := f()
+		if err != nil {
+			return errors.Wrap(err, "error flushing output")
+		}
+		for partial, final := range r {
+			renames[partial] = final
+		}
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for partial, final := range renames {
+		c.state.Renames[partial] = final
+	}
+	c.state.Processed = atomic.LoadInt64(&summary.Processed)
+	c.state.Emitted = atomic.LoadInt64(&summary.Emitted)
+	c.state.Filtered = atomic.LoadInt64(&summary.Filtered)
+	c.state.Failed = atomic.LoadInt64(&summary.Failed)
+	c.state.Complete = complete
+	for path := range c.state.Outputs {
+		if err := syncFile(path); err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return errors.Wrap(err, "error syncing output file "+path)
+		}
+		if info, err := os.Stat(path); err == nil {
+			c.state.Outputs[path] = info.Size()
+		}
+	}
+	if err := c.write(c.state); err != nil {
+		return err
+	}
+	c.persisted = c.state.clone()
+	c.saved = time.Now()
+	return c.rename()
+}
+
+// rename renames the finished output files of the checkpoint to their final path.
+// Files already renamed are skipped, so renames interrupted by a crash are finished on resume.
+func (c *checkpointer) rename() error {
+	for partial, final := range c.state.Renames {
+		if err := os.Rename(partial, final); err != nil && !os.IsNotExist(err) {
+			return errors.Wrap(err, "error renaming output file "+partial+" to "+final)
+		}
+		if err := syncDir(filepath.Dir(final)); err != nil {
+			return errors.Wrap(err, "error syncing directory of output file "+final)
+		}
+		delete(c.state.Renames, partial)
+		delete(c.state.Outputs, partial)
+	}
+	return nil
+}
+
+func (c *checkpointer) write(state *checkpoint) error {
+	state.Updated = time.Now().UTC()
+	b, err := json.Marshal(state)
+	if err != nil {
+		return errors.Wrap(err, "error marshaling checkpoint")
+	}
+	scheme, path := grw.SplitUri(c.uri)
+	if scheme == "" || scheme == "file" {
+		// the checkpoint is written to a temporary file first, so a crash never leaves a partial checkpoint.
+		pathExpanded, err := homedir.Expand(path)
+		if err != nil {
+			return errors.Wrap(err, "error expanding path "+path)
+		}
+		if err := os.MkdirAll(filepath.Dir(pathExpanded), 0755); err != nil {
+			return errors.Wrap(err, "error creating directory for checkpoint")
+		}
+		tmp := pathExpanded + ".tmp"
+		if err := ioutil.WriteFile(tmp, b, 0644); err != nil {
+			return errors.Wrap(err, "error writing checkpoint")
+		}
+		if err := syncFile(tmp); err != nil {
+			return errors.Wrap(err, "error syncing checkpoint")
+		}
+		if err := os.Rename(tmp, pathExpanded); err != nil {
+			return errors.Wrap(err, "error writing checkpoint")
+		}
+		if err := syncDir(filepath.Dir(pathExpanded)); err != nil {
+			return errors.Wrap(err, "error syncing directory of checkpoint")
+		}
+		return nil
+	}
+	writer, err := grw.WriteToResource(c.uri, "", false, c.s3_client)
+	if err != nil {
+		return errors.Wrap(err, "error opening checkpoint at "+c.uri)
+	}
+	if _, err := writer.Write(b); err != nil {
+		writer.Close()
+		return errors.Wrap(err, "error writing checkpoint at "+c.uri)
+	}
+	return writer.Close()
+}
@@ -0,0 +1,218 @@
+// =================================================================
+//
+// Copyright (C) 2018 Spatial Current, Inc. - All Rights Reserved
+// Released as open source under the MIT License.  See LICENSE file.
+//
+// =================================================================
+
+package cli
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+import (
+	"github.com/spatialcurrent/railgun/railgun/config"
+)
+
+const checkpointTestLines = 2000
+
+// runCheckpointTestChild streams lines to rotated outputs with a checkpoint, as railgun process does.
+// Lines are sent to a writer goroutine, so the progress of the input is recorded before the output is written.
+func runCheckpointTestChild(dir string, resume bool) error {
+	cp := newCheckpointer(filepath.Join(dir, "checkpoint.json"), 10*time.Millisecond, []*checkpointSource{&checkpointSource{Uri: "input", Version: "1"}}, nil)
+	summary := &streamSummary{}
+	if resume {
+		state, err := loadCheckpoint(filepath.Join(dir, "checkpoint.json"), nil)
+		if err != nil {
+			return err
+		}
+		if state != nil {
+			if err := cp.Resume(state, summary); err != nil {
+				return err
+			}
+		}
+	}
+
+	// a limit of 1 open writer and a small maximum of records evicts and rotates writers throughout the stream
+	writers := newPartitionWriters(&config.Output{MaxRecords: 7}, 1, cp, nil)
+
+	lines := make(chan int, 100)
+	errs := make(chan error, 1)
+	go func() {
+		for i := range lines {
+			if i%5 != 0 {
+				path := filepath.Join(dir, "odd-{part}.jsonl")
+				if i%2 == 0 {
+					path = filepath.Join(dir, "even-{part}.jsonl")
+				}
+				if err := writers.WriteLine(path, strconv.Itoa(i)); err != nil {
+					errs <- err
+					return
+				}
+			}
+			cp.Done()
+		}
+		errs <- writers.Close()
+	}()
+
+	for i := cp.Lines("input") + 1; i <= checkpointTestLines; i++ {
+		cp.Begin()
+		lines <- i
+		cp.End("input", i, int64(i))
+		if err := cp.SaveIfDue(summary); err != nil {
+			return err
+		}
+		if !resume {
+			// the first run never finishes, so it is always killed mid-stream however slowly the test runs.
+			if i == checkpointTestLines/2 {
+				select {}
+			}
+			time.Sleep(100 * time.Microsecond)
+		}
+	}
+	close(lines)
+	if err := <-errs; err != nil {
+		return err
+	}
+	return cp.Save(summary, true)
+}
+
+func readCheckpointTestOutput(t *testing.T, dir string, name string) []int {
+	matches, err := filepath.Glob(filepath.Join(dir, name+"-*.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(matches)
+	values := make([]int, 0)
+	for _, match := range matches {
+		f, err := os.Open(match)
+		if err != nil {
+			t.Fatal(err)
+		}
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			i, err := strconv.Atoi(scanner.Text())
+			if err != nil {
+				t.Fatalf("invalid line %q in %s", scanner.Text(), match)
+			}
+			values = append(values, i)
+		}
+		f.Close()
+	}
+	return values
+}
+
+func TestCheckpointResume(t *testing.T) {
+	if dir := os.Getenv("RAILGUN_CHECKPOINT_TEST_DIR"); len(dir) > 0 {
+		if err := runCheckpointTestChild(dir, os.Getenv("RAILGUN_CHECKPOINT_TEST_RESUME") == "true"); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	dir, err := ioutil.TempDir("", "railgun-checkpoint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	child := func(resume bool) *exec.Cmd {
+		cmd := exec.Command(os.Args[0], "-test.run=^TestCheckpointResume$")
+		cmd.Env = append(os.Environ(), "RAILGUN_CHECKPOINT_TEST_DIR="+dir, "RAILGUN_CHECKPOINT_TEST_RESUME="+strconv.FormatBool(resume))
+		cmd.Stderr = os.Stderr
+		return cmd
+	}
+
+	// the first run is killed mid-stream, once it has saved a checkpoint after a quarter of the lines
+	cmd := child(false)
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	for deadline := time.Now().Add(time.Minute); ; time.Sleep(time.Millisecond) {
+		state, err := loadCheckpoint(filepath.Join(dir, "checkpoint.json"), nil)
+		if err == nil && state != nil && state.Inputs["input"] != nil && state.Inputs["input"].Lines >= checkpointTestLines/4 {
+			break
+		}
+		if time.Now().After(deadline) {
+			cmd.Process.Kill()
+			t.Fatal("the first run did not save a checkpoint")
+		}
+	}
+	if err := cmd.Process.Kill(); err != nil {
+		t.Fatal(err)
+	}
+	cmd.Wait()
+
+	if err := child(true).Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	partials, _ := filepath.Glob(filepath.Join(dir, ".*.partial"))
+	if len(partials) > 0 {
+		t.Fatalf("partial files remain after resuming: %s", strings.Join(partials, ", "))
+	}
+
+	even := make([]int, 0)
+	odd := make([]int, 0)
+	for i := 1; i <= checkpointTestLines; i++ {
+		if i%5 == 0 {
+			continue
+		}
+		if i%2 == 0 {
+			even = append(even, i)
+		} else {
+			odd = append(odd, i)
+		}
+	}
+	for name, expected := range map[string][]int{"even": even, "odd": odd} {
+		values := readCheckpointTestOutput(t, dir, name)
+		if len(values) != len(expected) {
+			t.Fatalf("expected %d lines in %s output, but found %d", len(expected), name, len(values))
+		}
+		for i := range expected {
+			if values[i] != expected[i] {
+				t.Fatalf("expected line %d of %s output to be %d, but found %d", i+1, name, expected[i], values[i])
+			}
+		}
+	}
+}
+
+func TestCheckpointResumeChangedInputs(t *testing.T) {
+	state := &checkpoint{
+		Sources: []*checkpointSource{&checkpointSource{Uri: "a.jsonl", Version: "1"}, &checkpointSource{Uri: "b.jsonl", Version: "1"}},
+		Inputs:  map[string]*checkpointInput{"a.jsonl": &checkpointInput{Lines: 10, Offset: 100}},
+		Outputs: map[string]int64{},
+		Renames: map[string]string{},
+	}
+	inputs := map[string][]*checkpointSource{
+		"an input was added":   {&checkpointSource{Uri: "a.jsonl", Version: "1"}, &checkpointSource{Uri: "b.jsonl", Version: "1"}, &checkpointSource{Uri: "c.jsonl", Version: "1"}},
+		"the inputs reordered": {&checkpointSource{Uri: "b.jsonl", Version: "1"}, &checkpointSource{Uri: "a.jsonl", Version: "1"}},
+		"an input changed":     {&checkpointSource{Uri: "a.jsonl", Version: "2"}, &checkpointSource{Uri: "b.jsonl", Version: "1"}},
+	}
+	for name, sources := range inputs {
+		cp := newCheckpointer(filepath.Join(os.TempDir(), "railgun-checkpoint-unused.json"), time.Minute, sources, nil)
+		if err := cp.Resume(state.clone(), &streamSummary{}); err == nil {
+			t.Fatalf("expected an error resuming when %s", name)
+		}
+	}
+
+	cp := newCheckpointer(filepath.Join(os.TempDir(), "railgun-checkpoint-unused.json"), time.Minute, state.clone().Sources, nil)
+	if err := cp.Resume(state.clone(), &streamSummary{}); err != nil {
+		t.Fatal(err)
+	}
+	if cp.Lines("a.jsonl") != 10 {
+		t.Fatalf("expected 10 lines of a.jsonl to be skipped, but found %d", cp.Lines("a.jsonl"))
+	}
+}
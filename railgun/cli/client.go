@@ -50,6 +50,32 @@ func printConfig(v *viper.Viper) {
 	fmt.Println("=================================================")
 }
 
+// serviceExecInput is the input for executing a service.
+type serviceExecInput struct {
+	Name      string `rest:"name, the name of the service"`
+	Variables string `rest:"variables, the input variables for the service"`
+	Session   string `rest:"session, the id of the session, if not set then the service is executed without state"`
+}
+
+var serviceExecInputType = reflect.TypeOf(serviceExecInput{})
+
+// serviceSessionInput is the input for creating a session for a service.
+type serviceSessionInput struct {
+	Name      string `rest:"name, the name of the service"`
+	TTL       string `rest:"ttl, how long the session is kept while idle, e.g., 30m"`
+	Variables string `rest:"variables, the initial variables for the session"`
+}
+
+var serviceSessionInputType = reflect.TypeOf(serviceSessionInput{})
+
+// cacheWarmInput is the input for loading the data of a layer into the cache.
+type cacheWarmInput struct {
+	Name      string `rest:"name, the name of the layer"`
+	Variables string `rest:"variables, the variables used to evaluate the uri of the data store, e.g., {z: 4, x: 2, y: 5}"`
+}
+
+var cacheWarmInputType = reflect.TypeOf(cacheWarmInput{})
+
 type RequestInput struct {
 	Url           string
 	Method        string
@@ -88,6 +114,9 @@ func MakeRequest(input *RequestInput, outputWriter grw.ByteWriteCloser, errorWri
 		if err != nil {
 			return err
 		}
+		if len(input.Authorization) > 0 {
+			r.Header.Set("Authorization", "bearer "+input.Authorization)
+		}
 		req = r
 	}
 
@@ -247,6 +276,11 @@ func newPostCommand(use string, short string, long string, path string, params [
 }
 
 func newRestCommand(use string, short string, long string, path string, method string, params []string) *cobra.Command {
+	return newRestQueryCommand(use, short, long, path, method, params, []string{})
+}
+
+// newRestQueryCommand returns a command that makes a request with the given path parameters and optional query string parameters.
+func newRestQueryCommand(use string, short string, long string, path string, method string, params []string, query []string) *cobra.Command {
 	return &cobra.Command{
 		Use:   use,
 		Short: short,
@@ -285,6 +319,16 @@ func newRestCommand(use string, short string, long string, path string, method s
 					u = strings.Replace(u, "{"+name+"}", value, 1)
 				}
 
+				qs := url.Values{}
+				for _, name := range query {
+					if value := v.GetString(name); len(value) > 0 {
+						qs.Set(name, value)
+					}
+				}
+				if len(qs) > 0 {
+					u += "?" + qs.Encode()
+				}
+
 				u2, err := url.Parse(u)
 				if err != nil {
 					return err
@@ -460,9 +504,36 @@ func init() {
 		"execute a service on the Railgun Server with the given input",
 		"/services/{name}/exec.{ext}",
 		[]string{"name"},
-		core.JobType)
+		serviceExecInputType)
 	servicesCmd.AddCommand(serviceExecCmd)
-	initFlags(serviceExecCmd, core.JobType)
+	initFlags(serviceExecCmd, serviceExecInputType)
+	serviceSessionsCmd := &cobra.Command{
+		Use:   "sessions",
+		Short: "interact with service sessions on Railgun Server",
+		Long:  "interact with service sessions on Railgun Server",
+		Run: func(cmd *cobra.Command, args []string) {
+			cmd.Usage()
+		},
+	}
+	servicesCmd.AddCommand(serviceSessionsCmd)
+	serviceSessionsCreateCmd := newPostCommand(
+		"create",
+		"create a session for a service on Railgun Server",
+		"create a session for a service on Railgun Server",
+		"/services/{name}/sessions.{ext}",
+		[]string{"name"},
+		serviceSessionInputType)
+	initFlags(serviceSessionsCreateCmd, serviceSessionInputType)
+	serviceSessionsDeleteCmd := newRestCommand(
+		"delete",
+		"delete a session for a service on Railgun Server",
+		"delete a session for a service on Railgun Server",
+		"/services/{name}/sessions/{id}.{ext}",
+		"DELETE",
+		[]string{"name", "id"})
+	serviceSessionsDeleteCmd.Flags().String("name", "", "name of service on Railgun Server")
+	serviceSessionsDeleteCmd.Flags().String("id", "", "id of session")
+	serviceSessionsCmd.AddCommand(serviceSessionsCreateCmd, serviceSessionsDeleteCmd)
 
 	// Jobs
 	jobsCmd := &cobra.Command{
@@ -506,6 +577,95 @@ func init() {
 	workflowExecCmd.Flags().String("name", "", fmt.Sprintf("name of %s on Railgun Server", "workflow"))
 	workflowsCmd.AddCommand(workflowExecCmd)
 
+	// Runs
+	runsCmd := &cobra.Command{
+		Use:   "runs",
+		Short: "interact with runs of services, jobs, and workflows on Railgun Server",
+		Long:  "interact with runs of services, jobs, and workflows on Railgun Server",
+		Run: func(cmd *cobra.Command, args []string) {
+			cmd.Usage()
+		},
+	}
+	clientCmd.AddCommand(runsCmd)
+	runsListCmd := newRestQueryCommand(
+		"list",
+		"list runs on Railgun Server",
+		"list runs on Railgun Server",
+		"/runs.{ext}",
+		"GET",
+		[]string{},
+		[]string{"type", "name", "service", "job", "workflow", "status", "since", "limit"})
+	runsListCmd.Flags().String("type", "", "only runs of this type: service, job, or workflow")
+	runsListCmd.Flags().String("name", "", "only runs with this name")
+	runsListCmd.Flags().String("service", "", "only runs of this service")
+	runsListCmd.Flags().String("job", "", "only runs of this job")
+	runsListCmd.Flags().String("workflow", "", "only runs of this workflow")
+	runsListCmd.Flags().String("status", "", "only runs with this status: queued, running, succeeded, failed, skipped, or canceled")
+	runsListCmd.Flags().String("since", "", "only runs created since this RFC 3339 timestamp or duration ago, e.g., 24h")
+	runsListCmd.Flags().String("limit", "", "the maximum number of runs returned")
+	runsGetCmd := newRestCommand(
+		"get",
+		"get run on Railgun Server",
+		"get run on Railgun Server",
+		"/runs/{id}.{ext}",
+		"GET",
+		[]string{"id"})
+	runsGetCmd.Flags().String("id", "", "id of run on Railgun Server")
+	runsLogsCmd := newRestCommand(
+		"logs",
+		"get logs of run on Railgun Server",
+		"get logs of run on Railgun Server",
+		"/runs/{id}/logs.{ext}",
+		"GET",
+		[]string{"id"})
+	runsLogsCmd.Flags().String("id", "", "id of run on Railgun Server")
+	runsCmd.AddCommand(runsListCmd, runsGetCmd, runsLogsCmd)
+
+	// Cache
+	cacheCmd := &cobra.Command{
+		Use:   "cache",
+		Short: "interact with the cache of layers on Railgun Server",
+		Long:  "interact with the cache of layers on Railgun Server.  Requires an admin user.",
+		Run: func(cmd *cobra.Command, args []string) {
+			cmd.Usage()
+		},
+	}
+	clientCmd.AddCommand(cacheCmd)
+	cacheListCmd := newRestQueryCommand(
+		"list",
+		"list entries in the cache on Railgun Server",
+		"list entries in the cache on Railgun Server",
+		"/cache.{ext}",
+		"GET",
+		[]string{},
+		[]string{"layer"})
+	cacheListCmd.Flags().String("layer", "", "only entries read by this layer")
+	cacheDeleteCmd := newRestCommand(
+		"delete",
+		"delete an entry from the cache on Railgun Server",
+		"delete an entry from the cache on Railgun Server",
+		"/cache/{key}.{ext}",
+		"DELETE",
+		[]string{"key"})
+	cacheDeleteCmd.Flags().String("key", "", "key of cache entry")
+	cachePurgeCmd := newRestCommand(
+		"purge",
+		"delete the cached data of a layer on Railgun Server",
+		"delete the cached data of a layer on Railgun Server",
+		"/layers/{name}/cache.{ext}",
+		"DELETE",
+		[]string{"name"})
+	cachePurgeCmd.Flags().String("name", "", "name of layer on Railgun Server")
+	cacheWarmCmd := newPostCommand(
+		"warm",
+		"load the data of a layer into the cache on Railgun Server",
+		"load the data of a layer into the cache on Railgun Server",
+		"/layers/{name}/cache/warm.{ext}",
+		[]string{"name"},
+		cacheWarmInputType)
+	initFlags(cacheWarmCmd, cacheWarmInputType)
+	cacheCmd.AddCommand(cacheListCmd, cacheDeleteCmd, cachePurgeCmd, cacheWarmCmd)
+
 }
 
 func initRestCommands(parentCmd *cobra.Command, baseurl string, singular string, plural string, inputType reflect.Type) {
@@ -0,0 +1,25 @@
+// =================================================================
+//
+// Copyright (C) 2018 Spatial Current, Inc. - All Rights Reserved
+// Released as open source under the MIT License.  See LICENSE file.
+//
+// =================================================================
+
+package cli
+
+import (
+	"github.com/spatialcurrent/cobra"
+)
+
+var cryptoCmd = &cobra.Command{
+	Use:   "crypto",
+	Short: "commands for encrypted files",
+	Long:  "commands for encrypted files",
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Usage()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(cryptoCmd)
+}
@@ -0,0 +1,140 @@
+// =================================================================
+//
+// Copyright (C) 2018 Spatial Current, Inc. - All Rights Reserved
+// Released as open source under the MIT License.  See LICENSE file.
+//
+// =================================================================
+
+package cli
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/spatialcurrent/cobra"
+	"github.com/spatialcurrent/go-simple-serializer/gss"
+	"github.com/spatialcurrent/railgun/railgun/keyring"
+	"github.com/spatialcurrent/viper"
+	"os"
+)
+
+var GO_RAILGUN_DEFAULT_KEYRING = "~/.railgun/keyring.yml"
+
+func openKeyring(v *viper.Viper) *keyring.Keyring {
+	kr, err := keyring.Open(v.GetString("keyring-uri"))
+	if err != nil {
+		fmt.Println(errors.Wrap(err, "error opening keyring"))
+		os.Exit(1)
+	}
+	return kr
+}
+
+func saveKeyring(v *viper.Viper, kr *keyring.Keyring) {
+	err := kr.Save(v.GetString("keyring-uri"))
+	if err != nil {
+		fmt.Println(errors.Wrap(err, "error saving keyring"))
+		os.Exit(1)
+	}
+}
+
+func printKeys(v *viper.Viper, obj interface{}) {
+	str, err := gss.SerializeString(obj, v.GetString("format"), gss.NoHeader, gss.NoLimit)
+	if err != nil {
+		fmt.Println(errors.Wrap(err, "error serializing keys"))
+		os.Exit(1)
+	}
+	fmt.Println(str)
+}
+
+var keysCmd = &cobra.Command{
+	Use:   "keys",
+	Short: "manage the master keys and data keys in a local keyring",
+	Long:  "Manage the master keys and data keys in a local keyring.  Data keys encrypt files and are stored wrapped by master keys.",
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Usage()
+	},
+}
+
+var keysCreateCmd = &cobra.Command{
+	Use:   "create",
+	Short: "create a master key and its first data key",
+	Long:  "create a master key and its first data key",
+	Run: func(cmd *cobra.Command, args []string) {
+		v := initViper(cmd)
+		kr := openKeyring(v)
+		mk, dk, err := kr.CreateMasterKey(v.GetString("name"))
+		if err != nil {
+			fmt.Println(errors.Wrap(err, "error creating master key"))
+			os.Exit(1)
+		}
+		saveKeyring(v, kr)
+		printKeys(v, map[string]interface{}{
+			"master_key": mk.Map(),
+			"data_key":   dk.Map(),
+		})
+	},
+}
+
+var keysListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "list the master keys and data keys without their material",
+	Long:  "list the master keys and data keys without their material",
+	Run: func(cmd *cobra.Command, args []string) {
+		v := initViper(cmd)
+		kr := openKeyring(v)
+		masterKeys := make([]interface{}, 0)
+		for _, mk := range kr.MasterKeys() {
+			masterKeys = append(masterKeys, mk.Map())
+		}
+		dataKeys := make([]interface{}, 0)
+		for _, dk := range kr.DataKeys() {
+			dataKeys = append(dataKeys, dk.Map())
+		}
+		printKeys(v, map[string]interface{}{
+			"master_keys": masterKeys,
+			"data_keys":   dataKeys,
+		})
+	},
+}
+
+var keysRotateCmd = &cobra.Command{
+	Use:   "rotate",
+	Short: "rotate a master key and wrap its data keys with the new version",
+	Long:  "Rotate a master key and wrap its data keys with the new version.  Files are not re-encrypted.  With --data-key, a new primary data key is also created for new files.",
+	Run: func(cmd *cobra.Command, args []string) {
+		v := initViper(cmd)
+		kr := openKeyring(v)
+		name := v.GetString("name")
+		mk, count, err := kr.RotateMasterKey(name)
+		if err != nil {
+			fmt.Println(errors.Wrap(err, "error rotating master key "+name))
+			os.Exit(1)
+		}
+		obj := map[string]interface{}{
+			"master_key": mk.Map(),
+			"rewrapped":  count,
+		}
+		if v.GetBool("data-key") {
+			dk, err := kr.CreateDataKey(name)
+			if err != nil {
+				fmt.Println(errors.Wrap(err, "error creating data key"))
+				os.Exit(1)
+			}
+			obj["data_key"] = dk.Map()
+		}
+		saveKeyring(v, kr)
+		printKeys(v, obj)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(keysCmd)
+	keysCmd.AddCommand(keysCreateCmd, keysListCmd, keysRotateCmd)
+
+	for _, cmd := range []*cobra.Command{keysCreateCmd, keysListCmd, keysRotateCmd} {
+		cmd.Flags().String("keyring-uri", GO_RAILGUN_DEFAULT_KEYRING, "the path to the keyring file")
+		cmd.Flags().String("format", "yaml", "the output format: json or yaml")
+	}
+	keysCreateCmd.Flags().String("name", "", "the name of the master key")
+	keysRotateCmd.Flags().String("name", "", "the name of the master key")
+	keysRotateCmd.Flags().Bool("data-key", false, "also create a new primary data key for new files")
+}
@@ -0,0 +1,291 @@
+// =================================================================
+//
+// Copyright (C) 2018 Spatial Current, Inc. - All Rights Reserved
+// Released as open source under the MIT License.  See LICENSE file.
+//
+// =================================================================
+
+package cli
+
+import (
+	"container/list"
+	"fmt"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/pkg/errors"
+	"github.com/spatialcurrent/go-reader-writer/grw"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+import (
+	"github.com/spatialcurrent/railgun/railgun/config"
+)
+
+// partitionPlaceholder is replaced in output paths by the number of the part, so partitions can be rotated into parts.
+const partitionPlaceholder = "{part}"
+
+// partition is an output path, which is written to in parts when rotated.
+// Local parts are written to a hidden partial file, which is renamed to the path of the part once finished,
+// so readers never see a partially written file.
+type partition struct {
+	Template string // the output path, which may include {part}
+	Local    bool
+	Part     int
+	Path     string // the final path of the current part
+	Partial  string // the path written to until the part is finished
+	Writer   grw.ByteWriteCloser
+	Opened   bool // true if the current part has been opened during this run
+	Records  int
+	Bytes    int64
+	Created  time.Time
+	element  *list.Element
+}
+
+// partitionWriters writes lines to output partitions, keeping the most recently used writers open.
+// Once the number of open writers reaches the limit, the least recently used writer is closed.
+// Parts are finished once they reach the maximum bytes or records of the output, or once they are older than the maximum age.
+// If checkpointing, finished parts are renamed when the checkpoint is saved, so the checkpoint and the output files agree.
+type partitionWriters struct {
+	output     *config.Output
+	limit      int
+	s3_client  *s3.S3
+	cp         *checkpointer
+	mutex      *sync.Mutex
+	partitions map[string]*partition
+	open       *list.List // the partitions with open writers, most recently used first
+	renames    map[string]string
+}
+
+func newPartitionWriters(output *config.Output, limit int, cp *checkpointer, s3_client *s3.S3) *partitionWriters {
+	if limit < 1 {
+		limit = 1
+	}
+	w := &partitionWriters{
+		output:     output,
+		limit:      limit,
+		s3_client:  s3_client,
+		cp:         cp,
+		mutex:      &sync.Mutex{},
+		partitions: map[string]*partition{},
+		open:       list.New(),
+		renames:    map[string]string{},
+	}
+	cp.OnSave(w.flush)
+	return w
+}
+
+func (w *partitionWriters) paths(p *partition) {
+	p.Path = strings.Replace(p.Template, partitionPlaceholder, fmt.Sprintf("%05d", p.Part), -1)
+	p.Partial = p.Path
+	if p.Local {
+		p.Partial = filepath.Join(filepath.Dir(p.Path), "."+filepath.Base(p.Path)+".partial")
+	}
+}
+
+// nextPart moves the partition to the next part, skipping local parts that were finished by a previous run.
+func (w *partitionWriters) nextPart(p *partition) {
+	p.Part++
+	p.Opened = false
+	p.Records = 0
+	p.Bytes = 0
+	w.paths(p)
+	if p.Local && strings.Contains(p.Template, partitionPlaceholder) {
+		for {
+			if _, err := os.Stat(p.Path); err != nil {
+				break
+			}
+			p.Part++
+			w.paths(p)
+		}
+	}
+}
+
+func (w *partitionWriters) partition(template string) *partition {
+	if p, ok := w.partitions[template]; ok {
+		return p
+	}
+	scheme, path := grw.SplitUri(template)
+	p := &partition{Template: template, Local: scheme == "" || scheme == "file", Part: -1}
+	if p.Local {
+		p.Template = path
+	}
+	w.nextPart(p)
+	w.partitions[template] = p
+	return p
+}
+
+// openWriter opens the writer of the partition, closing the least recently used writer if at the limit.
+// Partial files left by an evicted writer or a previous run are appended to.
+func (w *partitionWriters) openWriter(p *partition) error {
+	if p.Writer != nil {
+		w.open.MoveToFront(p.element)
+		return nil
+	}
+
+	for w.open.Len() >= w.limit {
+		if err := w.evict(w.open.Back().Value.(*partition)); err != nil {
+			return err
+		}
+	}
+
+	appendToFile := false
+	if p.Local {
+		if w.output.Mkdirs {
+			os.MkdirAll(filepath.Dir(p.Path), 0755)
+		}
+		if _, err := os.Stat(p.Partial); err == nil {
+			appendToFile = true
+		} else if !p.Opened && w.output.Append && !strings.Contains(p.Template, partitionPlaceholder) {
+			// an existing file is moved to the partial file, so it is appended to and then renamed back.
+			if _, err := os.Stat(p.Path); err == nil {
+				if err := os.Rename(p.Path, p.Partial); err != nil {
+					return errors.Wrap(err, "error moving output file "+p.Path)
+				}
+				appendToFile = true
+			}
+		}
+		if err := w.cp.Track(p.Partial); err != nil {
+			return errors.Wrap(err, "error saving checkpoint")
+		}
+	}
+
+	writer, err := grw.WriteToResource(p.Partial, w.output.Compression, appendToFile, w.s3_client)
+	if err != nil {
+		return errors.Wrap(err, "error opening file at path "+p.Partial)
+	}
+	if !p.Opened {
+		p.Created = time.Now()
+	}
+	p.Writer = writer
+	p.Opened = true
+	p.element = w.open.PushFront(p)
+	return nil
+}
+
+func (w *partitionWriters) closeWriter(p *partition) error {
+	if p.Writer == nil {
+		return nil
+	}
+	w.open.Remove(p.element)
+	p.element = nil
+	err := p.Writer.Close()
+	p.Writer = nil
+	if err != nil {
+		return errors.Wrap(err, "error closing output file "+p.Partial)
+	}
+	return nil
+}
+
+// evict closes the writer of the partition.  Local partial files are reopened later,
+// while other parts cannot be appended to and so are finished.
+func (w *partitionWriters) evict(p *partition) error {
+	if p.Local {
+		return w.closeWriter(p)
+	}
+	if !strings.Contains(p.Template, partitionPlaceholder) {
+		return errors.New("cannot close output file " + p.Path + " before it is finished, include " + partitionPlaceholder + " in the output uri or increase the file descriptor limit")
+	}
+	return w.finish(p)
+}
+
+// finish closes the current part of the partition, renames it to its final path, and moves the partition to the next part.
+func (w *partitionWriters) finish(p *partition) error {
+	if !p.Opened {
+		return nil
+	}
+	if err := w.closeWriter(p); err != nil {
+		return err
+	}
+	if p.Partial != p.Path {
+		if w.cp != nil {
+			w.renames[p.Partial] = p.Path
+		} else {
+			// the part is synced before it is renamed, so a crash never leaves a renamed part that is incomplete.
+			if err := syncFile(p.Partial); err != nil {
+				return errors.Wrap(err, "error syncing output file "+p.Partial)
+			}
+			if err := os.Rename(p.Partial, p.Path); err != nil {
+				return errors.Wrap(err, "error renaming output file "+p.Partial+" to "+p.Path)
+			}
+		}
+	}
+	w.nextPart(p)
+	return nil
+}
+
+func (w *partitionWriters) due(p *partition) bool {
+	return p.Opened && ((w.output.MaxRecords > 0 && p.Records >= w.output.MaxRecords) ||
+		(w.output.MaxBytes > 0 && p.Bytes >= int64(w.output.MaxBytes)) ||
+		(w.output.MaxAge > 0 && time.Since(p.Created) >= w.output.MaxAge))
+}
+
+// WriteLine writes the line to the current part of the partition at the path, finishing the part if it is due.
+func (w *partitionWriters) WriteLine(path string, line string) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	p := w.partition(path)
+	if w.due(p) {
+		if err := w.finish(p); err != nil {
+			return err
+		}
+	}
+	if err := w.openWriter(p); err != nil {
+		return err
+	}
+	n, err := p.Writer.WriteLine(line)
+	if err != nil {
+		return errors.Wrap(err, "error writing string to output file "+p.Partial)
+	}
+	p.Records++
+	p.Bytes += int64(n)
+	if w.due(p) {
+		return w.finish(p)
+	}
+	return nil
+}
+
+// Expire finishes the parts older than the maximum age, so they are available even if no more lines are written to them.
+func (w *partitionWriters) Expire() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	for _, p := range w.partitions {
+		if w.due(p) {
+			if err := w.finish(p); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// flush closes the writers of local partial files and returns the finished parts to rename,
+// which is called before a checkpoint is saved, so the sizes of the partial files include every line written.
+func (w *partitionWriters) flush() (map[string]string, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	for _, p := range w.partitions {
+		if p.Local {
+			if err := w.closeWriter(p); err != nil {
+				return nil, err
+			}
+		}
+	}
+	renames := w.renames
+	w.renames = map[string]string{}
+	return renames, nil
+}
+
+// Close finishes every partition.  If checkpointing, the parts are renamed when the final checkpoint is saved.
+func (w *partitionWriters) Close() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	for _, p := range w.partitions {
+		if err := w.finish(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
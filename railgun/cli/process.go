@@ -8,10 +8,8 @@
 package cli
 
 import (
+	"bufio"
 	"bytes"
-	"crypto/aes"
-	"crypto/cipher"
-	"crypto/rand"
 	"encoding/csv"
 	"encoding/json"
 	"fmt"
@@ -22,6 +20,7 @@ import (
 	"reflect"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 	"unicode"
 )
@@ -46,6 +45,7 @@ import (
 	//rerrors "github.com/spatialcurrent/railgun/railgun/errors"
 	"github.com/spatialcurrent/railgun/railgun/athenaiterator"
 	"github.com/spatialcurrent/railgun/railgun/config"
+	"github.com/spatialcurrent/railgun/railgun/keyring"
 	"github.com/spatialcurrent/railgun/railgun/logger"
 	"github.com/spatialcurrent/railgun/railgun/util"
 )
@@ -56,7 +56,7 @@ var GO_RAILGUN_DEFAULT_SALT = "4F56C8C88B38CD8CD96BF8A9724F4BFE"
 var processViper = viper.New()
 
 //outputUri string, outputCompression string, outputAppend bool, outputPassphrase string, outputSalt string,
-func processOutput(content string, output *config.Output, s3_client *s3.S3) error {
+func processOutput(content string, output *config.Output, kr *keyring.Keyring, s3_client *s3.S3) error {
 	if output.Uri == "stdout" {
 		if output.IsEncrypted() {
 			return errors.New("encryption only works with file output")
@@ -69,6 +69,10 @@ func processOutput(content string, output *config.Output, s3_client *s3.S3) erro
 		fmt.Fprintf(os.Stderr, content)
 	} else {
 
+		if output.IsEncrypted() && output.Append {
+			return errors.New("encryption is not compatible with appending to output files")
+		}
+
 		outputWriter, err := grw.WriteToResource(output.Uri, output.Compression, output.Append, s3_client)
 		if err != nil {
 			return errors.Wrap(err, "error opening output file")
@@ -76,21 +80,26 @@ func processOutput(content string, output *config.Output, s3_client *s3.S3) erro
 
 		if output.IsEncrypted() {
 
-			outputBlock, err := util.CreateCipher(output.Salt, output.Passphrase)
-			if err != nil {
-				return errors.New("error creating cipher for output passphrase")
+			var envelopeWriter *util.EnvelopeWriter
+			if len(output.Key) > 0 {
+				keyId, key, err := kr.PrimaryDataKey(output.Key)
+				if err != nil {
+					return errors.Wrap(err, "error resolving output key "+output.Key)
+				}
+				envelopeWriter, err = util.NewKeyEnvelopeWriter(outputWriter, keyId, key)
+			} else {
+				envelopeWriter, err = util.NewEnvelopeWriter(outputWriter, output.Passphrase, output.Salt, util.DefaultKdfParams)
 			}
-			outputPlainText := []byte(content + "\n")
-			outputCipherText := make([]byte, aes.BlockSize+len(outputPlainText))
-			iv := outputCipherText[:aes.BlockSize]
-			if _, err := io.ReadFull(rand.Reader, iv); err != nil {
-				return errors.Wrap(err, "error generating iv")
+			if err != nil {
+				return errors.Wrap(err, "error creating encrypted writer for output file")
 			}
 
-			outputStream := cipher.NewCFBEncrypter(outputBlock, iv)
-			outputStream.XORKeyStream(outputCipherText[aes.BlockSize:], outputPlainText)
+			_, err = envelopeWriter.Write([]byte(content + "\n"))
+			if err != nil {
+				return errors.Wrap(err, "error writing encrypted data to output file")
+			}
 
-			_, err = outputWriter.Write(outputCipherText)
+			err = envelopeWriter.Close()
 			if err != nil {
 				return errors.Wrap(err, "error writing encrypted data to output file")
 			}
@@ -153,47 +162,358 @@ func buildOptions(inputLine []byte, inputFormat string, inputHeader []string, in
 	return options, errors.New("invalid input format for handleInput " + inputFormat)
 }
 
-func handleInput(inputLines chan []byte, input *config.Input, node dfl.Node, vars map[string]interface{}, outputObjects chan interface{}, outputFormat string, errorsChannel chan error, verbose bool) error {
+// inputLine is a line of streamed input, its line number, and its input, so errors can be attributed to the line.
+type inputLine struct {
+	Number int
+	Bytes  []byte
+	Input  *config.Input
+}
 
-	go func() {
+// processLine deserializes and evaluates a line of input.
+// Returns false if the line does not produce an output object, e.g., when the line is empty or is filtered out.
+// Returns an error with the line number if the line fails to parse or evaluate.
+// The uri of the input is available to the expression as $input.uri.
+func processLine(line inputLine, node dfl.Node, vars map[string]interface{}, verbose bool) (interface{}, bool, error) {
+	input := line.Input
+	options, err := buildOptions(
+		line.Bytes,
+		input.Format,
+		input.Header,
+		input.Comment,
+		input.LazyQuotes)
+	if err != nil {
+		return nil, false, errors.Wrap(err, "invalid options for input line "+fmt.Sprint(line.Number))
+	}
+	inputObject, err := options.DeserializeBytes(line.Bytes, verbose)
+	if err != nil {
+		return nil, false, errors.Wrap(err, "error deserializing input line "+fmt.Sprint(line.Number)+" using options "+fmt.Sprint(options))
+	}
+	vars["input"] = map[string]interface{}{"uri": input.Uri}
+	outputObject, err := processObject(inputObject, node, vars)
+	if err != nil {
+		switch err.(type) {
+		case *gss.ErrEmptyRow:
+			return nil, false, nil
+		}
+		return nil, false, errors.Wrap(err, "error processing input line "+fmt.Sprint(line.Number))
+	}
+	switch outputObject.(type) {
+	case dfl.Null:
+		return nil, false, nil
+	}
+	return outputObject, true, nil
+}
 
-		for inputLine := range inputLines {
-			options, err := buildOptions(
-				inputLine,
-				input.Format,
-				input.Header,
-				input.Comment,
-				input.LazyQuotes)
-			if err != nil {
-				errorsChannel <- errors.Wrap(err, "invalid options for input line "+string(inputLine))
-				continue
+// recordFailure is a line of input that failed to parse or evaluate.
+type recordFailure struct {
+	Line  inputLine
+	Error error
+}
+
+// Map returns the failure as written to the error output, with the record verbatim.
+func (f *recordFailure) Map() map[string]interface{} {
+	return map[string]interface{}{
+		"line":   f.Line.Number,
+		"uri":    f.Line.Input.Uri,
+		"error":  f.Error.Error(),
+		"record": strings.TrimRight(string(f.Line.Bytes), "\r\n"),
+	}
+}
+
+// streamSummary counts the records of a stream.  Records are processed and then either emitted, filtered, or failed.
+type streamSummary struct {
+	Processed int64
+	Emitted   int64
+	Filtered  int64
+	Failed    int64
+	MaxErrors int64 // if greater than 0, then processing is aborted once this many records have failed
+	aborted   chan struct{}
+	abort     *sync.Once
+}
+
+func newStreamSummary(maxErrors int64) *streamSummary {
+	return &streamSummary{
+		MaxErrors: maxErrors,
+		aborted:   make(chan struct{}),
+		abort:     &sync.Once{},
+	}
+}
+
+// record counts the result of processing a line and returns true if the output object should be emitted.
+func (s *streamSummary) record(line inputLine, ok bool, err error, failures chan *recordFailure) bool {
+	atomic.AddInt64(&s.Processed, 1)
+	if err != nil {
+		if failed := atomic.AddInt64(&s.Failed, 1); s.MaxErrors > 0 && failed == s.MaxErrors {
+			s.abort.Do(func() { close(s.aborted) })
+		}
+		failures <- &recordFailure{Line: line, Error: err}
+		return false
+	}
+	if !ok {
+		atomic.AddInt64(&s.Filtered, 1)
+		return false
+	}
+	atomic.AddInt64(&s.Emitted, 1)
+	return true
+}
+
+// Aborted returns true if the number of failed records has reached the maximum.
+func (s *streamSummary) Aborted() bool {
+	return s.MaxErrors > 0 && atomic.LoadInt64(&s.Failed) >= s.MaxErrors
+}
+
+// Done returns a channel that is closed once the number of failed records has reached the maximum.
+// The channel is created by newStreamSummary, so it is never nil, but it is never closed if the summary has no maximum.
+func (s *streamSummary) Done() <-chan struct{} {
+	return s.aborted
+}
+
+func (s *streamSummary) String() string {
+	return fmt.Sprintf(
+		"processed %d records: %d emitted, %d filtered, %d failed",
+		atomic.LoadInt64(&s.Processed),
+		atomic.LoadInt64(&s.Emitted),
+		atomic.LoadInt64(&s.Filtered),
+		atomic.LoadInt64(&s.Failed))
+}
+
+// copyVariables returns a shallow copy of the variables, so each worker evaluates with its own variables.
+func copyVariables(vars map[string]interface{}) map[string]interface{} {
+	c := make(map[string]interface{}, len(vars))
+	for k, v := range vars {
+		c[k] = v
+	}
+	return c
+}
+
+// handleInput processes the input lines and sends the output objects to the outputObjects channel, which is closed when done.
+// If workers is greater than 1, then the lines are processed in parallel.
+// If ordered, then the output objects are sent in the order of the input lines using a reorder buffer,
+// otherwise they are sent as soon as they are processed.
+// Sends block when the outputObjects channel is full, so the input is not read faster than the output is written.
+// Lines that fail are sent to the failures channel, which is closed with the outputObjects channel.
+// Lines that are filtered out are marked as done with the checkpointer, if any.
+// Once the stream is aborted, the remaining lines are dropped without being processed.
+func handleInput(inputLines chan inputLine, node dfl.Node, vars map[string]interface{}, outputObjects chan interface{}, outputFormat string, failures chan *recordFailure, summary *streamSummary, cp *checkpointer, workers int, ordered bool, verbose bool) error {
+
+	if workers <= 1 {
+		go func(vars map[string]interface{}) {
+			for line := range inputLines {
+				if summary.Aborted() {
+					cp.Done()
+					continue
+				}
+				outputObject, ok, err := processLine(line, node, vars, verbose)
+				if summary.record(line, ok, err, failures) {
+					outputObjects <- outputObject
+				} else if err == nil {
+					cp.Done()
+				}
 			}
-			inputObject, err := options.DeserializeBytes(inputLine, verbose)
-			if err != nil {
-				errorsChannel <- errors.Wrap(err, "error deserializing input using options "+fmt.Sprint(options))
-				continue
+			close(outputObjects)
+			close(failures)
+		}(copyVariables(vars))
+		return nil
+	}
+
+	if !ordered {
+		var wgWorkers sync.WaitGroup
+		for i := 0; i < workers; i++ {
+			wgWorkers.Add(1)
+			go func(vars map[string]interface{}) {
+				defer wgWorkers.Done()
+				for line := range inputLines {
+					if summary.Aborted() {
+						cp.Done()
+						continue
+					}
+					outputObject, ok, err := processLine(line, node, vars, verbose)
+					if summary.record(line, ok, err, failures) {
+						outputObjects <- outputObject
+					} else if err == nil {
+						cp.Done()
+					}
+				}
+			}(copyVariables(vars))
+		}
+		go func() {
+			wgWorkers.Wait()
+			close(outputObjects)
+			close(failures)
+		}()
+		return nil
+	}
+
+	type task struct {
+		Index int
+		Line  inputLine
+	}
+
+	type result struct {
+		Index  int
+		Object interface{}
+		Ok     bool
+		Failed bool
+	}
+
+	tasks := make(chan task, workers)
+	results := make(chan result, workers)
+
+	// window bounds the number of lines in flight, so the reorder buffer cannot grow without limit behind a slow line.
+	window := make(chan struct{}, workers*64)
+
+	go func() {
+		index := 0
+		for line := range inputLines {
+			window <- struct{}{}
+			tasks <- task{Index: index, Line: line}
+			index++
+		}
+		close(tasks)
+	}()
+
+	var wgWorkers sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wgWorkers.Add(1)
+		go func(vars map[string]interface{}) {
+			defer wgWorkers.Done()
+			for t := range tasks {
+				if summary.Aborted() {
+					// the result is still sent, so the reorder buffer moves past the line and marks it as done.
+					results <- result{Index: t.Index}
+					continue
+				}
+				outputObject, ok, err := processLine(t.Line, node, vars, verbose)
+				results <- result{Index: t.Index, Object: outputObject, Ok: summary.record(t.Line, ok, err, failures), Failed: err != nil}
 			}
-			outputObject, err := processObject(inputObject, node, vars)
-			if err != nil {
-				switch err.(type) {
-				case *gss.ErrEmptyRow:
-				default:
-					errorsChannel <- errors.Wrap(err, "error processing object")
+		}(copyVariables(vars))
+	}
+	go func() {
+		wgWorkers.Wait()
+		close(results)
+	}()
+
+	go func() {
+		pending := map[int]result{}
+		next := 0
+		for r := range results {
+			pending[r.Index] = r
+			for {
+				p, ok := pending[next]
+				if !ok {
+					break
 				}
-			} else {
-				switch outputObject.(type) {
-				case dfl.Null:
-				default:
-					outputObjects <- outputObject
+				delete(pending, next)
+				if p.Ok {
+					outputObjects <- p.Object
+				} else if !p.Failed {
+					cp.Done()
 				}
+				<-window
+				next++
 			}
 		}
 		close(outputObjects)
+		close(failures)
 	}()
+
 	return nil
 }
 
-func handleOutput(output *config.Output, outputVars map[string]interface{}, objects chan interface{}, errorsChannel chan error, messages chan interface{}, fileDescriptorLimit int, wg *sync.WaitGroup, s3_client *s3.S3, verbose bool) error {
+// streamInput reads the lines of the input and sends them to the inputLines channel until the input is exhausted or the stream is aborted.
+// A send blocked on a full channel stops as soon as the stream is aborted.
+// If reader is nil, then the input is opened from its uri.  Encrypted input is decrypted while it is read.
+// Lines of the input processed before the checkpoint are skipped.
+func streamInput(input *config.Input, reader grw.ByteReadCloser, kr *keyring.Keyring, inputLines chan inputLine, cp *checkpointer, summary *streamSummary, s3_client *s3.S3) error {
+
+	if reader == nil {
+		r, _, err := grw.ReadFromResource(input.Uri, input.Compression, input.ReaderBufferSize, false, s3_client)
+		if err != nil {
+			return errors.Wrap(err, "error opening resource from uri "+input.Uri)
+		}
+		reader = r
+	}
+	defer reader.Close()
+
+	plain, err := util.DecryptStream(reader, input.Passphrase, input.Salt, false, kr)
+	if err != nil {
+		return errors.Wrap(err, "error decrypting input")
+	}
+	lineReader := bufio.NewReader(plain)
+
+	// the number of lines before the first record, so line numbers in errors match the input
+	headerLines := 0
+	// the number of bytes of input read, which is saved with checkpoints
+	inputOffset := int64(0)
+	if len(input.Header) == 0 && (input.Format == "csv" || input.Format == "tsv") {
+		headerLines = 1
+		inputBytes, err := lineReader.ReadBytes('\n')
+		if err != nil {
+			if err == io.EOF && len(inputBytes) == 0 {
+				return nil
+			}
+			return errors.Wrap(err, "error reading header from resource")
+		}
+		inputOffset += int64(len(inputBytes))
+		csvReader := csv.NewReader(bytes.NewReader(inputBytes))
+		if input.Format == "tsv" {
+			csvReader.Comma = '\t'
+		}
+		csvReader.LazyQuotes = input.LazyQuotes
+		if len(input.Comment) > 1 {
+			return errors.New("go's encoding/csv package only supports single character comment characters")
+		} else if len(input.Comment) == 1 {
+			csvReader.Comment = []rune(input.Comment)[0]
+		}
+		h, err := csvReader.Read()
+		if err != nil {
+			if err != io.EOF {
+				return errors.Wrap(err, "Error reading header from input with format csv")
+			}
+		}
+		input.Header = h
+	}
+
+	skip := cp.Lines(input.Uri)
+	inputCount := 0
+	for !summary.Aborted() {
+		inputBytes, err := lineReader.ReadBytes('\n')
+		if err != nil {
+			if err == io.EOF {
+				break
+			} else {
+				return errors.Wrap(err, "error reading line from resource")
+			}
+		}
+		inputCount += 1
+		inputOffset += int64(len(inputBytes))
+		// lines processed before the checkpoint are skipped when resuming
+		if inputCount <= skip {
+			continue
+		}
+		cp.Begin()
+		select {
+		case inputLines <- inputLine{Number: inputCount + headerLines, Bytes: inputBytes, Input: input}:
+		case <-summary.Done():
+			// the line was never sent, so the progress of the input is not recorded.
+			cp.Cancel()
+			return nil
+		}
+		cp.End(input.Uri, inputCount, inputOffset)
+		if input.Limit > 0 && inputCount >= input.Limit {
+			break
+		}
+		err = cp.SaveIfDue(summary)
+		if err != nil {
+			return errors.Wrap(err, "error saving checkpoint")
+		}
+	}
+
+	return nil
+}
+
+// handleOutput writes the objects to the output.  Each object written is marked as done with the checkpointer, if any.
+func handleOutput(output *config.Output, outputVars map[string]interface{}, objects chan interface{}, errorsChannel chan error, messages chan interface{}, fileDescriptorLimit int, wg *sync.WaitGroup, s3_client *s3.S3, cp *checkpointer, verbose bool) error {
 
 	if output.Uri == "stdout" {
 		go func() {
@@ -204,6 +524,7 @@ func handleOutput(output *config.Output, outputVars map[string]interface{}, obje
 					break
 				}
 				messages <- line
+				cp.Done()
 			}
 			close(messages)
 			wg.Done()
@@ -221,6 +542,7 @@ func handleOutput(output *config.Output, outputVars map[string]interface{}, obje
 				}
 				//fmt.Fprintf(os.Stderr, line)
 				messages <- line
+				cp.Done()
 			}
 			close(messages)
 			wg.Done()
@@ -282,49 +604,59 @@ func handleOutput(output *config.Output, outputVars map[string]interface{}, obje
 
 	go func() {
 		var wgLines sync.WaitGroup
-		for line := range outputLines {
-			wgLines.Add(1)
-			go func(w *sync.WaitGroup, line struct {
-				Path string
-				Line string
-			}) {
-				defer w.Done()
+		if output.BufferMemory {
+			for line := range outputLines {
+				wgLines.Add(1)
+				go func(w *sync.WaitGroup, line struct {
+					Path string
+					Line string
+				}) {
+					defer w.Done()
 
-				outputPathSemaphore := getOutputPathSemaphore(line.Path)
+					outputPathSemaphore := getOutputPathSemaphore(line.Path)
 
-				outputFileDescriptorSemaphore <- struct{}{}
-				outputPathSemaphore <- struct{}{}
+					outputFileDescriptorSemaphore <- struct{}{}
+					outputPathSemaphore <- struct{}{}
 
-				if output.BufferMemory {
 					writeToOutputMemoryBuffer(line.Path, line.Line)
-				} else {
-					if output.Mkdirs {
-						os.MkdirAll(filepath.Dir(line.Path), 0755)
-					}
 
-					outputWriter, err := grw.WriteToResource(line.Path, output.Compression, true, s3_client)
-					if err != nil {
-						<-outputPathSemaphore
-						<-outputFileDescriptorSemaphore
-						errorsChannel <- errors.Wrap(err, "error opening file at path "+line.Path)
-						return
-					}
+					<-outputPathSemaphore
+					<-outputFileDescriptorSemaphore
 
-					_, err = outputWriter.WriteLine(line.Line)
+				}(&wgLines, line)
+			}
+		} else {
+			// writers are kept open up to the file descriptor limit and finished parts are renamed to their final path
+			writers := newPartitionWriters(output, fileDescriptorLimit, cp, s3_client)
+			var expire <-chan time.Time
+			if output.MaxAge > 0 {
+				ticker := time.NewTicker(time.Second)
+				defer ticker.Stop()
+				expire = ticker.C
+			}
+			for done := false; !done; {
+				select {
+				case line, ok := <-outputLines:
+					if !ok {
+						done = true
+						break
+					}
+					err := writers.WriteLine(line.Path, line.Line)
 					if err != nil {
-						errorsChannel <- errors.Wrap(err, "Error writing string to output file")
+						errorsChannel <- err
 					}
-
-					err = outputWriter.Close()
+					cp.Done()
+				case <-expire:
+					err := writers.Expire()
 					if err != nil {
-						errorsChannel <- errors.Wrap(err, "Error closing output file.")
+						errorsChannel <- err
 					}
 				}
-
-				<-outputPathSemaphore
-				<-outputFileDescriptorSemaphore
-
-			}(&wgLines, line)
+			}
+			err := writers.Close()
+			if err != nil {
+				errorsChannel <- errors.Wrap(err, "error finishing output files")
+			}
 		}
 		messages <- "* waiting for wgLines to be done"
 		wgLines.Wait()
@@ -556,6 +888,18 @@ func processFunction(cmd *cobra.Command, args []string) {
 		LogCompression:   "",
 	}
 	config.LoadConfigFromViper(processConfig, v)
+	// input uris are not split on commas, which are valid in object keys and query strings.
+	processConfig.Input.Uris = v.GetStringArray("input-uri")
+
+	kr := keyring.NewKeyring()
+	if keyringUri := v.GetString("keyring-uri"); len(keyringUri) > 0 {
+		k, err := keyring.Open(keyringUri)
+		if err != nil {
+			fmt.Println(errors.Wrap(err, "error loading keyring"))
+			os.Exit(1)
+		}
+		kr = k
+	}
 
 	if verbose {
 		fmt.Println("=================================================")
@@ -610,10 +954,35 @@ func processFunction(cmd *cobra.Command, args []string) {
 		logger.ListenError(errorsChannel)
 	}
 
-	processConfig.Input.Init()
+	inputUris, err := util.ExpandUris(processConfig.Input.Uris, s3_client)
+	if err != nil {
+		logger.Fatal(errors.Wrap(err, "error expanding input uris"))
+	}
+	if len(inputUris) == 0 {
+		logger.Fatal("missing input uri")
+	}
+
+	// each input infers its format and compression from its own uri
+	inputs := make([]*config.Input, 0, len(inputUris))
+	for _, inputUri := range inputUris {
+		inputs = append(inputs, processConfig.Input.WithUri(inputUri))
+	}
+	processConfig.Input = inputs[0]
 
 	var inputReader grw.ByteReadCloser
-	if !processConfig.Input.IsAthenaStoredQuery() {
+	if len(inputs) > 1 {
+		// multiple inputs are opened when they are read
+		processConfig.Output.Init()
+
+		if !stream {
+			logger.Fatal("multiple inputs require --stream")
+		}
+		for _, input := range inputs {
+			if input.IsAthenaStoredQuery() || !input.CanStream() {
+				logger.Fatal("input " + input.Uri + " with format " + input.Format + " cannot be streamed line by line, which is required for multiple inputs")
+			}
+		}
+	} else if !processConfig.Input.IsAthenaStoredQuery() {
 		r, inputMetadata, err := grw.ReadFromResource(
 			processConfig.Input.Uri,
 			processConfig.Input.Compression,
@@ -660,11 +1029,24 @@ func processFunction(cmd *cobra.Command, args []string) {
 		}
 
 		if processConfig.Output.IsEncrypted() {
-			logger.Fatal("output passphrase is not compatible with streaming because it uses a block cipher")
+			logger.Fatal("output passphrase is not compatible with streaming because output files are appended to one line at a time")
+		}
+
+		if processConfig.Output.IsRotated() {
+			if processConfig.Output.BufferMemory {
+				logger.Fatal("rotating output files is not compatible with buffering output in memory")
+			}
+			if !strings.Contains(processConfig.Output.Uri, partitionPlaceholder) {
+				logger.Fatal("rotating output files requires " + partitionPlaceholder + " in the output uri")
+			}
 		}
 
 		// Stream Processing with Batch Input
-		if processConfig.Input.IsAthenaStoredQuery() || processConfig.Input.IsEncrypted() || !(processConfig.Input.CanStream()) {
+		if processConfig.Input.IsAthenaStoredQuery() || !(processConfig.Input.CanStream()) {
+
+			if len(processConfig.CheckpointUri) > 0 {
+				logger.Fatal("checkpoints require input that can be streamed line by line")
+			}
 
 			var inputObjects interface{}
 			if processConfig.Input.IsAthenaStoredQuery() {
@@ -682,7 +1064,7 @@ func processFunction(cmd *cobra.Command, args []string) {
 				inputObjects = objects
 			} else {
 
-				inputBytes, err := util.DecryptReader(inputReader, processConfig.Input.Passphrase, processConfig.Input.Salt)
+				inputBytes, err := util.DecryptReader(inputReader, processConfig.Input.Passphrase, processConfig.Input.Salt, false, kr)
 				if err != nil {
 					logger.Fatal(errors.Wrap(err, "error decoding input"))
 				}
@@ -735,6 +1117,7 @@ func processFunction(cmd *cobra.Command, args []string) {
 				fileDescriptorLimit,
 				&wgObjects,
 				s3_client,
+				nil,
 				verbose)
 
 			inputObjectsValue := reflect.ValueOf(inputObjects)
@@ -768,49 +1151,105 @@ func processFunction(cmd *cobra.Command, args []string) {
 			logger.Fatal(err)
 		}
 
-		if len(processConfig.Input.Header) == 0 && (processConfig.Input.Format == "csv" || processConfig.Input.Format == "tsv") {
-			inputBytes, err := inputReader.ReadBytes('\n')
-			if err != nil {
-				logger.Fatal(errors.Wrap(err, "error reading header from resource"))
-			}
-			csvReader := csv.NewReader(bytes.NewReader(inputBytes))
-			if processConfig.Input.Format == "tsv" {
-				csvReader.Comma = '\t'
-			}
-			csvReader.LazyQuotes = processConfig.Input.LazyQuotes
-			if len(processConfig.Input.Comment) > 1 {
-				logger.Fatal("go's encoding/csv package only supports single character comment characters")
-			} else if len(processConfig.Input.Comment) == 1 {
-				csvReader.Comment = []rune(processConfig.Input.Comment)[0]
-			}
-			h, err := csvReader.Read()
-			if err != nil {
-				if err != io.EOF {
-					logger.Fatal(errors.Wrap(err, "Error reading header from input with format csv"))
-				}
-			}
-			processConfig.Input.Header = h
-		}
-
 		var wgObjects sync.WaitGroup
 		var wgMessages sync.WaitGroup
 		errorsChannel := make(chan error, 1000)
 		messages := make(chan interface{}, 1000)
-		inputLines := make(chan []byte, 1000)
+		inputLines := make(chan inputLine, 1000)
 		outputObjects := make(chan interface{}, 1000)
+		failures := make(chan *recordFailure, 1000)
+		summary := newStreamSummary(int64(v.GetInt("max-errors")))
+
+		var cp *checkpointer
+		if len(processConfig.CheckpointUri) > 0 {
+			if processConfig.Output.BufferMemory {
+				logger.Fatal("checkpoints are not compatible with buffering output in memory")
+			}
+			sources, err := checkpointSources(inputUris, s3_client)
+			if err != nil {
+				logger.Fatal(errors.Wrap(err, "error creating checkpoint"))
+			}
+			cp = newCheckpointer(processConfig.CheckpointUri, v.GetDuration("checkpoint-interval"), sources, s3_client)
+			if v.GetBool("resume") {
+				state, err := loadCheckpoint(processConfig.CheckpointUri, s3_client)
+				if err != nil {
+					logger.Fatal(errors.Wrap(err, "error loading checkpoint"))
+				}
+				if state != nil {
+					err = cp.Resume(state, summary)
+					if err != nil {
+						logger.Fatal(errors.Wrap(err, "error resuming from checkpoint"))
+					}
+					logger.Info("* resuming after " + fmt.Sprint(state.Processed) + " records")
+				}
+			}
+		} else if v.GetBool("resume") {
+			logger.Fatal("resume requires a checkpoint uri")
+		}
 
 		wgObjects.Add(1)
 		wgMessages.Add(1)
 		logger.ListenFatal(errorsChannel)
 		logger.ListenInfo(messages, &wgMessages)
+
+		// failed records are written verbatim to the error output if given, otherwise they are logged.
+		var wgFailures sync.WaitGroup
+		wgFailures.Add(1)
+		if errorOutputUri := v.GetString("error-output-uri"); len(errorOutputUri) > 0 {
+			errorOutput := &config.Output{
+				Uri:    errorOutputUri,
+				Format: v.GetString("error-output-format"),
+				Append: true,
+				Mkdirs: processConfig.Output.Mkdirs,
+			}
+			if !errorOutput.CanStream() {
+				logger.Fatal("error output format " + errorOutput.Format + " is not compatible with streaming")
+			}
+			errorObjects := make(chan interface{}, 1000)
+			errorMessages := make(chan interface{}, 1000)
+			wgMessages.Add(1)
+			logger.ListenInfo(errorMessages, &wgMessages)
+			err := handleOutput(
+				errorOutput,
+				dflVars,
+				errorObjects,
+				errorsChannel,
+				errorMessages,
+				fileDescriptorLimit,
+				&wgFailures,
+				s3_client,
+				cp,
+				verbose)
+			if err != nil {
+				logger.Fatal(errors.Wrap(err, "error creating error output"))
+			}
+			go func() {
+				for f := range failures {
+					errorObjects <- f.Map()
+				}
+				close(errorObjects)
+			}()
+		} else {
+			go func() {
+				for f := range failures {
+					logger.Error(f.Error)
+					cp.Done()
+				}
+				wgFailures.Done()
+			}()
+		}
+
 		handleInput(
 			inputLines,
-			processConfig.Input,
 			dflNode,
 			dflVars,
 			outputObjects,
 			processConfig.Output.Format,
-			errorsChannel,
+			failures,
+			summary,
+			cp,
+			v.GetInt("workers"),
+			v.GetBool("ordered"),
 			verbose)
 		handleOutput(
 			processConfig.Output,
@@ -821,31 +1260,46 @@ func processFunction(cmd *cobra.Command, args []string) {
 			fileDescriptorLimit,
 			&wgObjects,
 			s3_client,
+			cp,
 			verbose)
 
-		inputCount := 0
-		for {
-			inputBytes, err := inputReader.ReadBytes('\n')
-			if err != nil {
-				if err == io.EOF {
-					break
-				} else {
-					logger.Fatal("error reading line from resource")
-				}
-			}
-			inputLines <- inputBytes
-			inputCount += 1
-			if processConfig.Input.Limit > 0 && inputCount >= processConfig.Input.Limit {
-				break
-			}
+		// the inputs are read concurrently, up to the input parallelism at a time
+		parallelism := processConfig.Input.Parallelism
+		if parallelism < 1 {
+			parallelism = 1
 		}
-		err = inputReader.Close()
-		if err != nil {
-			errorsChannel <- errors.Wrap(err, "error closing input")
+		pendingInputs := make(chan *config.Input, len(inputs))
+		for _, input := range inputs {
+			pendingInputs <- input
 		}
+		close(pendingInputs)
+		var wgInputs sync.WaitGroup
+		for i := 0; i < parallelism && i < len(inputs); i++ {
+			wgInputs.Add(1)
+			go func() {
+				defer wgInputs.Done()
+				for input := range pendingInputs {
+					err := streamInput(input, inputReader, kr, inputLines, cp, summary, s3_client)
+					if err != nil {
+						errorsChannel <- errors.Wrap(err, "error reading input "+input.Uri)
+					}
+				}
+			}()
+		}
+		wgInputs.Wait()
 		close(inputLines)
 		wgObjects.Wait()
+		wgFailures.Wait()
+		// lines are dropped once aborted, so the checkpoint is not saved and resuming starts from the last checkpoint.
+		err = cp.Save(summary, true)
+		if err != nil {
+			logger.Fatal(errors.Wrap(err, "error saving checkpoint"))
+		}
 		wgMessages.Wait()
+		if summary.Aborted() {
+			logger.Fatal("aborted after " + fmt.Sprint(summary.MaxErrors) + " failed records, " + summary.String())
+		}
+		logger.Info(summary.String())
 		logger.Close()
 		return
 
@@ -889,7 +1343,7 @@ func processFunction(cmd *cobra.Command, args []string) {
 			logger.Fatal(err)
 		}
 
-		inputBytes, err := util.DecryptReader(inputReader, processConfig.Input.Passphrase, processConfig.Input.Salt)
+		inputBytes, err := util.DecryptReader(inputReader, processConfig.Input.Passphrase, processConfig.Input.Salt, false, kr)
 		if err != nil {
 			logger.Fatal(errors.Wrap(err, "error decrypting input"))
 		}
@@ -936,7 +1390,7 @@ func processFunction(cmd *cobra.Command, args []string) {
 		}
 	}
 
-	err := processOutput(outputString, processConfig.Output, s3_client)
+	err = processOutput(outputString, processConfig.Output, kr, s3_client)
 	if err != nil {
 		logger.Fatal(errors.Wrap(err, "error processing output"))
 	}
@@ -958,33 +1412,51 @@ func init() {
 	processCmd.Flags().BoolP("dry-run", "", false, "parse and compile expression, but do not evaluate against context")
 	processCmd.Flags().BoolP("pretty", "p", false, "print pretty output")
 	processCmd.Flags().BoolP("stream", "s", false, "stream process (context == row rather than encompassing array)")
+	processCmd.Flags().Int("workers", 1, "the number of lines evaluated in parallel when streaming")
+	processCmd.Flags().Bool("ordered", true, "preserve the order of the input when streaming with more than 1 worker, otherwise objects are written as soon as they are evaluated")
 
 	// Input Flags
-	processCmd.Flags().StringP("input-uri", "i", "stdin", "the input uri")
+	processCmd.Flags().StringArrayP("input-uri", "i", []string{"stdin"}, "the input uris, which may be local globs, e.g., data/*.jsonl.gz, or s3 prefixes, e.g., s3://bucket/prefix/")
+	processCmd.Flags().Int("input-parallelism", 1, "the number of inputs read concurrently when streaming multiple inputs")
 	processCmd.Flags().StringP("input-compression", "", "", "the input compression: "+strings.Join(GO_RAILGUN_COMPRESSION_ALGORITHMS, ", "))
 	processCmd.Flags().String("input-format", "", "the input format: "+strings.Join(gss.Formats, ", "))
 	processCmd.Flags().StringSlice("input-header", []string{}, "the input header, if the stdin input has no header.")
 	processCmd.Flags().StringP("input-comment", "c", "", "the comment character for the input, e.g, #")
 	processCmd.Flags().Bool("input-lazy-quotes", false, "allows lazy quotes for CSV and TSV")
 	processCmd.Flags().String("input-passphrase", "", "input passphrase for AES-256 encryption")
-	processCmd.Flags().String("input-salt", GO_RAILGUN_DEFAULT_SALT, "input salt for AES-256 encryption")
+	processCmd.Flags().String("input-salt", GO_RAILGUN_DEFAULT_SALT, "input salt for legacy AES-256-CFB encryption, ignored for AES-256-GCM since the salt is stored in the file")
 	processCmd.Flags().Int("input-reader-buffer-size", 4096, "the buffer size for the input reader")
 	processCmd.Flags().Int("input-skip-lines", gss.NoSkip, "the number of lines to skip before processing")
-	processCmd.Flags().Int("input-limit", gss.NoLimit, "maximum number of objects to read from input")
+	processCmd.Flags().Int("input-limit", gss.NoLimit, "maximum number of objects to read from each input")
 
 	processCmd.Flags().String("temp-uri", "", "the temporary uri for storing results")
+	processCmd.Flags().String("keyring-uri", GO_RAILGUN_DEFAULT_KEYRING, "the path to the keyring file, which resolves the data keys of encrypted input and the output key")
 
 	// Output Flags
-	processCmd.Flags().StringP("output-uri", "o", "stdout", "the output uri (a dfl expression itself)")
+	processCmd.Flags().StringP("output-uri", "o", "stdout", "the output uri (a dfl expression itself), which may include {part} for the part number of rotated output files")
 	processCmd.Flags().StringP("output-compression", "", "", "the output compression: "+strings.Join(GO_RAILGUN_COMPRESSION_ALGORITHMS, ", "))
 	processCmd.Flags().StringP("output-format", "", "", "the output format: "+strings.Join(gss.Formats, ", "))
 	processCmd.Flags().StringSliceP("output-header", "", []string{}, "the output header")
 	processCmd.Flags().StringP("output-passphrase", "", "", "output passphrase for AES-256 encryption")
-	processCmd.Flags().StringP("output-salt", "", "", "output salt for AES-256 encryption")
+	processCmd.Flags().StringP("output-salt", "", "", "hex-encoded output salt for AES-256-GCM encryption, if blank then a random salt is generated and stored in the file")
+	processCmd.Flags().String("output-key", "", "the name of the master key in the keyring whose primary data key encrypts the output")
 	processCmd.Flags().IntP("output-limit", "", gss.NoLimit, "maximum number of objects to send to output")
 	processCmd.Flags().BoolP("output-append", "", false, "append to output files")
 	processCmd.Flags().Bool("output-buffer-memory", false, "buffer output in memory")
 	processCmd.Flags().Bool("output-mkdirs", false, "make directories if missing for output files")
+	processCmd.Flags().Int("output-max-bytes", 0, "when streaming, rotate output files to the next {part} once this many bytes are written, if 0 then never rotate by size")
+	processCmd.Flags().Int("output-max-records", 0, "when streaming, rotate output files to the next {part} once this many records are written, if 0 then never rotate by count")
+	processCmd.Flags().Duration("output-max-age", 0, "when streaming, rotate output files to the next {part} once they are this old, if 0 then never rotate by age")
+
+	// Error Output Flags
+	processCmd.Flags().String("error-output-uri", "", "the output uri (a dfl expression itself) for records that fail to parse or evaluate when streaming, written with their line number, input uri, and error")
+	processCmd.Flags().String("error-output-format", "jsonl", "the format of the error output: csv, tsv, or jsonl")
+	processCmd.Flags().Int("max-errors", 0, "abort streaming after this many records fail, if 0 then never abort")
+
+	// Checkpoint Flags
+	processCmd.Flags().String("checkpoint-uri", "", "the uri of the checkpoint, which records the lines of input processed and the size of local output files when streaming")
+	processCmd.Flags().Duration("checkpoint-interval", 30*time.Second, "the interval between checkpoints")
+	processCmd.Flags().Bool("resume", false, "resume from the checkpoint, skipping input already processed.  Local output files are truncated to the checkpoint, so records are written exactly once, while other outputs may repeat records written since the checkpoint")
 
 	// DFL Flags
 	processCmd.Flags().StringP("dfl-expression", "", "", "DFL expression to use")
@@ -0,0 +1,44 @@
+// =================================================================
+//
+// Copyright (C) 2018 Spatial Current, Inc. - All Rights Reserved
+// Released as open source under the MIT License.  See LICENSE file.
+//
+// =================================================================
+
+package cli
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestStreamSummaryMaxErrors(t *testing.T) {
+	summary := newStreamSummary(3)
+	failures := make(chan *recordFailure, 10)
+	for i := 1; i <= 5; i++ {
+		summary.record(inputLine{Number: i}, false, errors.New("error"), failures)
+		select {
+		case <-summary.Done():
+			if i < 3 {
+				t.Fatalf("expected stream to continue after %d failed records", i)
+			}
+		default:
+			if i >= 3 {
+				t.Fatalf("expected stream to abort after %d failed records", i)
+			}
+		}
+	}
+	if !summary.Aborted() {
+		t.Fatal("expected stream to be aborted")
+	}
+
+	unlimited := newStreamSummary(0)
+	for i := 1; i <= 5; i++ {
+		unlimited.record(inputLine{Number: i}, false, errors.New("error"), failures)
+	}
+	select {
+	case <-unlimited.Done():
+		t.Fatal("expected stream without a maximum to never abort")
+	default:
+	}
+}
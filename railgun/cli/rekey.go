@@ -0,0 +1,246 @@
+// =================================================================
+//
+// Copyright (C) 2018 Spatial Current, Inc. - All Rights Reserved
+// Released as open source under the MIT License.  See LICENSE file.
+//
+// =================================================================
+
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/pkg/errors"
+	"github.com/spatialcurrent/cobra"
+	"github.com/spatialcurrent/go-reader-writer/grw"
+	"github.com/spatialcurrent/go-simple-serializer/gss"
+	"github.com/spatialcurrent/railgun/railgun/keyring"
+	"github.com/spatialcurrent/railgun/railgun/util"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// rekey decrypts the file at the uri and encrypts it again as an AES-256-GCM envelope,
+// with the primary data key of the new master key if given, otherwise with the new passphrase.
+// Local files are written to a temporary file that is renamed over the original, so a failure does not lose data.
+//
+// Files in the legacy AES-256-CFB format are only rekeyed if legacy is true.
+// Since a wrong passphrase cannot be detected by AES-256-CFB, the plain text of legacy files must parse in the format before it is encrypted again.
+func rekey(uri string, compression string, passphrase string, salt string, legacy bool, format string, kr *keyring.Keyring, newPassphrase string, newSalt string, newKey string, kdf util.KdfParams, s3_client *s3.S3) error {
+
+	scheme, path := grw.SplitUri(uri)
+	local := scheme == "" || scheme == "file"
+
+	outputUri := uri
+	if local {
+		outputUri = path + ".rekey"
+	}
+
+	inputReader, _, err := grw.ReadFromResource(uri, compression, 4096, false, s3_client)
+	if err != nil {
+		return errors.Wrap(err, "error opening resource at uri "+uri)
+	}
+	defer inputReader.Close()
+
+	br := bufio.NewReader(inputReader)
+	magic, _ := br.Peek(len(util.EnvelopeMagic))
+	isLegacy := !util.IsEnvelope(magic)
+	if isLegacy && !legacy {
+		return errors.New("resource at uri " + uri + " is not an envelope, use --legacy to rekey files in the legacy AES-256-CFB format")
+	}
+
+	plain, err := util.DecryptStream(br, passphrase, salt, true, kr)
+	if err != nil {
+		return errors.Wrap(err, "error decrypting resource at uri "+uri)
+	}
+
+	// legacy files are checked before they are encrypted again and objects are overwritten as they are read, so read them into memory first.
+	if isLegacy || !local {
+		b, err := ioutil.ReadAll(plain)
+		if err != nil {
+			return errors.Wrap(err, "error decrypting resource at uri "+uri)
+		}
+		if isLegacy {
+			if err := checkPlainText(b, format); err != nil {
+				return errors.Wrap(err, "error checking resource at uri "+uri+", the passphrase or salt is probably wrong")
+			}
+		}
+		plain = bytes.NewReader(b)
+	}
+
+	outputWriter, err := grw.WriteToResource(outputUri, compression, false, s3_client)
+	if err != nil {
+		return errors.Wrap(err, "error opening resource at uri "+outputUri)
+	}
+
+	// removeOutput removes the temporary file, so a failed rekey does not leave it behind.
+	removeOutput := func() {
+		if local {
+			os.Remove(outputUri)
+		}
+	}
+
+	var envelopeWriter *util.EnvelopeWriter
+	if len(newKey) > 0 {
+		keyId, key, err := kr.PrimaryDataKey(newKey)
+		if err != nil {
+			outputWriter.Close()
+			removeOutput()
+			return errors.Wrap(err, "error resolving key "+newKey)
+		}
+		envelopeWriter, err = util.NewKeyEnvelopeWriter(outputWriter, keyId, key)
+	} else {
+		envelopeWriter, err = util.NewEnvelopeWriter(outputWriter, newPassphrase, newSalt, kdf)
+	}
+	if err != nil {
+		outputWriter.Close()
+		removeOutput()
+		return errors.Wrap(err, "error creating encrypted writer")
+	}
+
+	if _, err := io.Copy(envelopeWriter, plain); err != nil {
+		outputWriter.Close()
+		removeOutput()
+		return errors.Wrap(err, "error rekeying resource at uri "+uri)
+	}
+
+	if err := envelopeWriter.Close(); err != nil {
+		outputWriter.Close()
+		removeOutput()
+		return errors.Wrap(err, "error writing encrypted data to uri "+outputUri)
+	}
+
+	if err := outputWriter.Close(); err != nil {
+		removeOutput()
+		return errors.Wrap(err, "error closing resource at uri "+outputUri)
+	}
+
+	if local {
+		if err := os.Rename(outputUri, path); err != nil {
+			removeOutput()
+			return errors.Wrap(err, "error renaming "+outputUri+" to "+path)
+		}
+	}
+
+	return nil
+}
+
+// checkPlainText returns an error if the plain text is not valid UTF-8 or does not parse in the format.
+func checkPlainText(b []byte, format string) error {
+	if !utf8.Valid(b) {
+		return errors.New("plain text is not valid UTF-8")
+	}
+	t, err := gss.GetType(b, format)
+	if err != nil {
+		return errors.Wrap(err, "error getting type of plain text")
+	}
+	if _, err := gss.DeserializeBytes(b, format, gss.NoHeader, gss.NoComment, false, gss.NoSkip, gss.NoLimit, t, false); err != nil {
+		return errors.Wrap(err, "plain text is not valid "+format)
+	}
+	return nil
+}
+
+func rekeyFunction(cmd *cobra.Command, args []string) {
+	if len(args) == 0 {
+		cmd.Usage()
+		os.Exit(1)
+	}
+
+	v := initViper(cmd)
+
+	passphrase := v.GetString("passphrase")
+	newPassphrase := v.GetString("new-passphrase")
+	if len(newPassphrase) == 0 {
+		newPassphrase = passphrase
+	}
+	newKey := v.GetString("new-key")
+	if len(newPassphrase) == 0 && len(newKey) == 0 {
+		fmt.Println("error: missing new passphrase or new key")
+		os.Exit(1)
+	}
+
+	legacy := v.GetBool("legacy")
+	if legacy && len(v.GetString("format")) == 0 {
+		fmt.Println("error: missing format, which is required to check the plain text of legacy files")
+		os.Exit(1)
+	}
+
+	kr := keyring.NewKeyring()
+	if keyringUri := v.GetString("keyring-uri"); len(keyringUri) > 0 {
+		k, err := keyring.Open(keyringUri)
+		if err != nil {
+			fmt.Println(errors.Wrap(err, "error loading keyring"))
+			os.Exit(1)
+		}
+		kr = k
+	}
+	if len(newKey) > 0 && !kr.HasMasterKey(newKey) {
+		fmt.Println("error: master key " + newKey + " is not in the keyring")
+		os.Exit(1)
+	}
+
+	kdf := util.KdfParams{
+		Time:    uint32(v.GetInt("kdf-time")),
+		Memory:  uint32(v.GetInt("kdf-memory")),
+		Threads: uint8(v.GetInt("kdf-threads")),
+	}
+	if err := kdf.Validate(); err != nil {
+		fmt.Println("error: " + err.Error())
+		os.Exit(1)
+	}
+
+	var s3_client *s3.S3
+	for _, uri := range args {
+		if strings.HasPrefix(uri, "s3://") {
+			aws_session, err := util.ConnectToAWS(
+				v.GetString("aws-access-key-id"),
+				v.GetString("aws-secret-access-key"),
+				v.GetString("aws-session-token"),
+				v.GetString("aws-default-region"))
+			if err != nil {
+				fmt.Println(errors.Wrap(err, "error connecting to AWS"))
+				os.Exit(1)
+			}
+			s3_client = s3.New(aws_session)
+			break
+		}
+	}
+
+	for _, uri := range args {
+		err := rekey(uri, v.GetString("compression"), passphrase, v.GetString("salt"), legacy, v.GetString("format"), kr, newPassphrase, v.GetString("new-salt"), newKey, kdf, s3_client)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		fmt.Println("rekeyed " + uri)
+	}
+}
+
+var rekeyCmd = &cobra.Command{
+	Use:   "rekey [flags] uri...",
+	Short: "re-encrypt files with a new passphrase or key using AES-256-GCM",
+	Long:  "Re-encrypt files with a new passphrase or the primary data key of a master key using AES-256-GCM.  Files encrypted with the legacy AES-256-CFB format are migrated to the new format with --legacy, if their plain text parses in the given format.",
+	Run:   rekeyFunction,
+}
+
+func init() {
+	cryptoCmd.AddCommand(rekeyCmd)
+
+	rekeyCmd.Flags().String("passphrase", "", "the current passphrase")
+	rekeyCmd.Flags().String("salt", GO_RAILGUN_DEFAULT_SALT, "the current salt of files in the legacy AES-256-CFB format")
+	rekeyCmd.Flags().Bool("legacy", false, "rekey files in the legacy AES-256-CFB format, which cannot detect a wrong passphrase")
+	rekeyCmd.Flags().String("format", "", "the format of the plain text of legacy files, which is checked before they are encrypted again: "+strings.Join(gss.Formats, ", "))
+	rekeyCmd.Flags().String("new-passphrase", "", "the new passphrase, if blank then the current passphrase")
+	rekeyCmd.Flags().String("new-salt", "", "the new hex-encoded salt, if blank then a random salt is generated")
+	rekeyCmd.Flags().String("new-key", "", "the name of the master key in the keyring whose primary data key encrypts the files, instead of a passphrase")
+	rekeyCmd.Flags().String("keyring-uri", GO_RAILGUN_DEFAULT_KEYRING, "the path to the keyring file, which resolves the data keys of files encrypted with a key and the new key")
+	rekeyCmd.Flags().String("compression", "", "the compression of the files: "+strings.Join(GO_RAILGUN_COMPRESSION_ALGORITHMS, ", "))
+	rekeyCmd.Flags().Int("kdf-time", int(util.DefaultKdfParams.Time), "the number of passes of the Argon2 key derivation function")
+	rekeyCmd.Flags().Int("kdf-memory", int(util.DefaultKdfParams.Memory), "the memory in KiB of the Argon2 key derivation function")
+	rekeyCmd.Flags().Int("kdf-threads", int(util.DefaultKdfParams.Threads), "the number of threads of the Argon2 key derivation function")
+}
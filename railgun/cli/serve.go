@@ -12,12 +12,14 @@ import (
 	"crypto/rsa"
 	"fmt"
 	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/mitchellh/go-homedir"
 	gocache "github.com/patrickmn/go-cache"
 	"github.com/spatialcurrent/cobra"
 	"github.com/spatialcurrent/viper"
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"strings"
 	"syscall"
 	"time"
@@ -28,96 +30,89 @@ import (
 	"github.com/pkg/errors"
 	"github.com/spatialcurrent/go-reader-writer/grw"
 	"github.com/spatialcurrent/go-simple-serializer/gss"
+	"github.com/spatialcurrent/railgun/railgun/cache"
 	"github.com/spatialcurrent/railgun/railgun/catalog"
 	rerrors "github.com/spatialcurrent/railgun/railgun/errors"
+	"github.com/spatialcurrent/railgun/railgun/keyring"
+	"github.com/spatialcurrent/railgun/railgun/logger"
+	"github.com/spatialcurrent/railgun/railgun/metrics"
 	"github.com/spatialcurrent/railgun/railgun/request"
 	"github.com/spatialcurrent/railgun/railgun/router"
+	"github.com/spatialcurrent/railgun/railgun/runs"
+	"github.com/spatialcurrent/railgun/railgun/tracing"
 	"github.com/spatialcurrent/railgun/railgun/util"
 )
 
 var emptyFeatureCollection = []byte("{\"type\":\"FeatureCollection\",\"features\":[]}")
 
-func NewRouter(v *viper.Viper, railgunCatalog *catalog.RailgunCatalog, errorWriter grw.ByteWriteCloser, logWriter grw.ByteWriteCloser, logFormat string, publicKey *rsa.PublicKey, privateKey *rsa.PrivateKey, validMethods []string, verbose bool) (*router.RailgunRouter, error) {
+func NewRouter(v *viper.Viper, railgunCatalog *catalog.RailgunCatalog, runStore runs.Store, errorWriter grw.ByteWriteCloser, logWriter grw.ByteWriteCloser, logFormat string, publicKey *rsa.PublicKey, privateKey *rsa.PrivateKey, validMethods []string, verbose bool) (*router.RailgunRouter, error) {
 
 	errorsChannel := make(chan error, 10000)
 	requests := make(chan request.Request, 10000)
 	messages := make(chan interface{}, 10000)
 
-	if logFormat == "text" {
-		go func(requests chan request.Request, logRequestsTile bool, logRequestsCache bool) {
-			for r := range requests {
-				switch r.(type) {
-				case *request.TileRequest:
-					if logRequestsTile {
-						messages <- r.String()
-					}
-				case *request.CacheRequest:
-					if logRequestsCache {
-						messages <- r.String()
-					}
+	m := metrics.NewMetrics(railgunCatalog)
+	if railgunCatalog.Cache != nil {
+		railgunCatalog.Cache.ObserveRead = m.ObserveDataStoreRead
+	}
+
+	accessLog, err := router.NewAccessLog(v.GetString("log-level"), v.GetStringArray("log-route-level"), v.GetFloat64("log-sample-rate"))
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating access log")
+	}
+
+	go func(requests chan request.Request, format string, logRequestsTile bool, logRequestsCache bool) {
+		for r := range requests {
+			m.ObserveRequest(r)
+			switch r.(type) {
+			case *request.AccessRequest:
+			case *request.TileRequest:
+				if !logRequestsTile {
+					continue
 				}
-			}
-		}(requests, v.GetBool("log-requests-tile"), v.GetBool("log-requests-cache"))
-	} else {
-		go func(requests chan request.Request, format string, errorsChannel chan error, logRequestsTile bool, logRequestsCache bool) {
-			for r := range requests {
-				switch r.(type) {
-				case *request.TileRequest:
-					if logRequestsTile {
-						msg, err := r.Serialize(format)
-						if err != nil {
-							errorsChannel <- err
-						} else {
-							messages <- msg
-						}
-					}
-				case *request.CacheRequest:
-					if logRequestsCache {
-						msg, err := r.Serialize(format)
-						if err != nil {
-							errorsChannel <- err
-						} else {
-							messages <- msg
-						}
-					}
+			case *request.CacheRequest:
+				if !logRequestsCache {
+					continue
 				}
+			default:
+				continue
 			}
-		}(requests, logFormat, errorsChannel, v.GetBool("log-requests-tile"), v.GetBool("log-requests-cache"))
-	}
-
-	go func(messages chan interface{}) {
-		for message := range messages {
-			logWriter.WriteString(fmt.Sprint(message) + "\n")
-			logWriter.Flush()
+			if format == "text" {
+				messages <- r.String()
+				continue
+			}
+			msg, err := r.Serialize(format)
+			if err != nil {
+				errorsChannel <- err
+				continue
+			}
+			messages <- msg
 		}
-	}(messages)
+	}(requests, logFormat, v.GetBool("log-requests-tile"), v.GetBool("log-requests-cache"))
 
-	errorDestination := v.GetString("error-destination")
-	logDestination := v.GetString("log-destination")
+	l := logger.New(logWriter, errorWriter)
+	l.ListenInfo(messages, nil)
 
-	if errorDestination == logDestination {
+	if v.GetString("error-destination") == v.GetString("log-destination") {
 		go func(errorsChannel chan error) {
 			for err := range errorsChannel {
 				messages <- err.Error()
 			}
 		}(errorsChannel)
 	} else {
-		go func(errorsChannel chan error) {
-			for err := range errorsChannel {
-				switch rerr := err.(type) {
-				case *rerrors.ErrInvalidParameter:
-					errorWriter.WriteString(rerr.Error())
-				case *rerrors.ErrMissing:
-					errorWriter.WriteString(rerr.Error())
-				default:
-					errorWriter.WriteString(rerr.Error())
-				}
-			}
-		}(errorsChannel)
+		l.ListenError(errorsChannel)
 	}
 
 	awsSessionCache := gocache.New(5*time.Minute, 10*time.Minute)
 
+	kr := keyring.NewKeyring()
+	if keyringUri := v.GetString("keyring-uri"); len(keyringUri) > 0 {
+		kr, err = keyring.Open(keyringUri)
+		if err != nil {
+			return nil, errors.Wrap(err, "error loading keyring")
+		}
+	}
+
 	r := router.NewRailgunRouter(
 		v,
 		railgunCatalog,
@@ -125,6 +120,10 @@ func NewRouter(v *viper.Viper, railgunCatalog *catalog.RailgunCatalog, errorWrit
 		messages,
 		errorsChannel,
 		awsSessionCache,
+		kr,
+		&metrics.RunStore{Store: runStore, Metrics: m},
+		m,
+		accessLog,
 		publicKey,
 		privateKey,
 		validMethods)
@@ -132,6 +131,21 @@ func NewRouter(v *viper.Viper, railgunCatalog *catalog.RailgunCatalog, errorWrit
 	return r, nil
 }
 
+func initRunStore(path string) (runs.Store, error) {
+	if len(path) == 0 {
+		return runs.NewMemoryStore(), nil
+	}
+	pathExpanded, err := homedir.Expand(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "error expanding path "+path)
+	}
+	err = os.MkdirAll(filepath.Dir(pathExpanded), 0700)
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating directory for "+path)
+	}
+	return runs.NewBoltStore(pathExpanded)
+}
+
 func initPublicKey(publicKeyString string, publicKeyUri string, s3_client *s3.S3) (*rsa.PublicKey, error) {
 
 	if len(publicKeyString) > 0 {
@@ -239,6 +253,16 @@ func serveFunction(cmd *cobra.Command, args []string) {
 	// Catalog Flags
 	catalogUri := v.GetString("catalog-uri")
 
+	// Cache Flags
+	cachePolicy := v.GetString("cache-policy")
+	cacheMaxBytes := v.GetInt64("cache-max-bytes")
+	cacheDefaultExpiration := v.GetDuration("cache-default-expiration")
+	cacheCleanupInterval := v.GetDuration("cache-cleanup-interval")
+	cacheRevalidateInterval := v.GetDuration("cache-revalidate-interval")
+	cacheStaleWhileRevalidate := v.GetBool("cache-stale-while-revalidate")
+	cacheDiskDir := v.GetString("cache-disk-dir")
+	cacheDiskMaxBytes := v.GetInt64("cache-disk-max-bytes")
+
 	// Security Flags
 	publicKeyString := v.GetString("jwt-public-key")
 	publicKeyUri := v.GetString("jwt-public-key-uri")
@@ -272,7 +296,26 @@ func serveFunction(cmd *cobra.Command, args []string) {
 		os.Exit(1)
 	}
 
-	railgunCatalog := catalog.NewRailgunCatalog()
+	layerCache, err := cache.NewCache(cachePolicy, cacheMaxBytes, cacheDefaultExpiration, cacheCleanupInterval, cacheRevalidateInterval, cacheStaleWhileRevalidate)
+	if err != nil {
+		errorWriter.WriteError(err)
+		errorWriter.Close()
+		os.Exit(1)
+	}
+	defer layerCache.Stop()
+
+	if len(cacheDiskDir) > 0 {
+		disk, err := cache.NewDisk(cacheDiskDir, cacheDiskMaxBytes)
+		if err != nil {
+			errorWriter.WriteError(err)
+			errorWriter.Close()
+			os.Exit(1)
+		}
+		layerCache.Disk = disk
+	}
+	layerCache.LoadTimeout = v.GetDuration("cache-load-timeout")
+
+	railgunCatalog := catalog.NewRailgunCatalog(layerCache)
 
 	err = railgunCatalog.LoadFromViper(v)
 	if err != nil {
@@ -326,13 +369,57 @@ func serveFunction(cmd *cobra.Command, args []string) {
 		os.Exit(1)
 	}
 
-	handler, err := NewRouter(v, railgunCatalog, errorWriter, logWriter, logFormat, publicKey, privateKey, validMethods, verbose)
+	runStore, err := initRunStore(v.GetString("runs-db"))
+	if err != nil {
+		errorWriter.WriteError(errors.Wrap(err, "error initializing run store"))
+		errorWriter.Close()
+		os.Exit(1)
+	}
+
+	shutdownTracing, err := tracing.Init(tracing.Config{
+		Exporter:    v.GetString("trace-exporter"),
+		Endpoint:    v.GetString("trace-endpoint"),
+		Insecure:    v.GetBool("trace-insecure"),
+		Uri:         v.GetString("trace-uri"),
+		SampleRatio: v.GetFloat64("trace-sample-ratio"),
+		ServiceName: v.GetString("trace-service-name"),
+	})
+	if err != nil {
+		errorWriter.WriteError(errors.Wrap(err, "error initializing tracing"))
+		errorWriter.Close()
+		os.Exit(1)
+	}
+
+	handler, err := NewRouter(v, railgunCatalog, runStore, errorWriter, logWriter, logFormat, publicKey, privateKey, validMethods, verbose)
 	if err != nil {
 		errorWriter.WriteString(errors.Wrap(err, "error creating new router").Error())
 		errorWriter.Close()
 		os.Exit(1)
 	}
 
+	go func(retention time.Duration, max int, interval time.Duration, messages chan interface{}, errorsChannel chan error) {
+		if interval <= 0 {
+			return
+		}
+		for {
+			before := time.Time{}
+			if retention > 0 {
+				before = time.Now().Add(-1 * retention)
+			}
+			n, err := runStore.Prune(before, max)
+			if err != nil {
+				errorsChannel <- errors.Wrap(err, "error pruning runs")
+			} else if n > 0 && verbose {
+				messages <- fmt.Sprintf("pruned %d runs", n)
+			}
+			time.Sleep(interval)
+		}
+	}(v.GetDuration("runs-retention"), v.GetInt("runs-max"), v.GetDuration("runs-prune-interval"), handler.Messages, handler.Errors)
+
+	if v.GetBool("scheduler-enabled") {
+		handler.Scheduler.Start()
+	}
+
 	srv := &http.Server{
 		Addr:         address,
 		IdleTimeout:  httpTimeoutIdle,
@@ -341,6 +428,27 @@ func serveFunction(cmd *cobra.Command, args []string) {
 		Handler:      handler,
 	}
 
+	var metricsServer *http.Server
+	if metricsAddress := v.GetString("metrics-address"); len(metricsAddress) > 0 {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", handler.Metrics.Handler())
+		metricsServer = &http.Server{
+			Addr:         metricsAddress,
+			IdleTimeout:  httpTimeoutIdle,
+			ReadTimeout:  httpTimeoutRead,
+			WriteTimeout: httpTimeoutWrite,
+			Handler:      mux,
+		}
+		go func() {
+			if verbose {
+				fmt.Println("serving metrics on " + metricsServer.Addr)
+			}
+			if err := metricsServer.ListenAndServe(); err != nil {
+				fmt.Println(err)
+			}
+		}()
+	}
+
 	go func() {
 		if verbose {
 			fmt.Println("starting up server...")
@@ -354,11 +462,17 @@ func serveFunction(cmd *cobra.Command, args []string) {
 	c := make(chan os.Signal, 1)
 	signal.Notify(c, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
 	<-c
+	handler.Scheduler.Stop()
+	runStore.Close()
 	errorWriter.Close()
 	logWriter.Close()
 	ctx, cancel := context.WithTimeout(context.Background(), wait)
 	defer cancel()
 	srv.Shutdown(ctx)
+	if metricsServer != nil {
+		metricsServer.Shutdown(ctx)
+	}
+	shutdownTracing(ctx)
 	if verbose {
 		fmt.Println("received signal to attemping graceful shutdown of server")
 	}
@@ -392,19 +506,46 @@ func init() {
 	serveCmd.Flags().DurationP("http-timeout-read", "", time.Second*15, "the read timeout for the http server")
 	serveCmd.Flags().DurationP("http-timeout-write", "", time.Second*15, "the write timeout for the http server")
 	serveCmd.Flags().BoolP("http-middleware-gzip", "", false, "enable GZIP middleware")
+	serveCmd.Flags().String("metrics-address", "", "bind address for a separate server for metrics, if empty then metrics are served at /metrics")
 
 	// Cache Flags
-	serveCmd.Flags().DurationP("cache-default-expiration", "", time.Minute*5, "the default exipration for items in the cache")
+	serveCmd.Flags().DurationP("cache-default-expiration", "", time.Minute*5, "the default exipration for items in the cache, overridden by the ttl of a layer")
 	serveCmd.Flags().DurationP("cache-cleanup-interval", "", time.Minute*10, "the cleanup interval for the cache")
+	serveCmd.Flags().Int64("cache-max-bytes", 1<<30, "the maximum estimated size in memory of the items in the cache shared by layers, if 0 then unbounded")
+	serveCmd.Flags().Duration("cache-revalidate-interval", time.Minute, "how often cached data is checked against the ETag or modification time of its data store, if 0 then never")
+	serveCmd.Flags().Duration("cache-load-timeout", time.Minute*5, "the timeout for reading data into the cache, which is shared by concurrent requests and so is not canceled with any one request, if 0 then none")
+	serveCmd.Flags().Bool("cache-stale-while-revalidate", true, "serve cached data while it is revalidated in the background")
+	serveCmd.Flags().String("cache-disk-dir", "", "the directory of the second-level cache that keeps the data read from data stores across restarts, if empty then disabled")
+	serveCmd.Flags().Int64("cache-disk-max-bytes", 10<<30, "the maximum size of the files in the cache directory, if 0 then unbounded")
+	serveCmd.Flags().String("cache-policy", cache.PolicyLRU, "the eviction policy of the cache: "+strings.Join(cache.Policies, ", "))
 
 	// Input Flags
 	serveCmd.Flags().StringP("input-passphrase", "", "", "input passphrase for AES-256 encryption")
 	serveCmd.Flags().StringP("input-salt", "", "", "input salt for AES-256 encryption")
+	serveCmd.Flags().MarkDeprecated("input-passphrase", "use the key of the data store")
+	serveCmd.Flags().MarkDeprecated("input-salt", "use the key of the data store")
+	serveCmd.Flags().String("keyring-uri", GO_RAILGUN_DEFAULT_KEYRING, "the path to the keyring file of the master keys, data keys, and passphrases that decrypt data stores, as created by railgun keys, where passphrases and salts can be overridden by RAILGUN_KEY_<NAME>_PASSPHRASE and RAILGUN_KEY_<NAME>_SALT")
 	serveCmd.Flags().IntP("input-reader-buffer-size", "", 4096, "the buffer size for the input reader")
 
 	// Logging Flags
 	serveCmd.Flags().BoolP("log-requests-tile", "", false, "log tile requests")
 	serveCmd.Flags().BoolP("log-requests-cache", "", false, "log cache hit/miss")
+	serveCmd.Flags().String("log-level", "info", "the minimum level of requests in the access log: debug, info, warn, error, or none")
+	serveCmd.Flags().StringArray("log-route-level", []string{}, "the minimum level of requests to a route in the access log, as route:level")
+	serveCmd.Flags().Float64("log-sample-rate", 1.0, "the fraction of successful requests written to the access log, between 0 and 1")
+
+	// Health Flags
+	serveCmd.Flags().Duration("health-timeout", 2*time.Second, "the timeout for each readiness check")
+	serveCmd.Flags().Duration("health-cache-ttl", 10*time.Second, "how long the results of readiness checks are reused")
+	serveCmd.Flags().Float64("health-queue-threshold", 0.9, "the fraction of the capacity of a queue at which the server is not ready")
+
+	// Tracing Flags
+	serveCmd.Flags().String("trace-exporter", "none", "the exporter for OpenTelemetry traces: none, otlp, or stdout")
+	serveCmd.Flags().String("trace-endpoint", "", "the host and port of the OTLP/HTTP collector, defaults to localhost:4318")
+	serveCmd.Flags().Bool("trace-insecure", false, "export traces to the OTLP collector over HTTP rather than HTTPS")
+	serveCmd.Flags().String("trace-uri", "stdout", "the destination of the stdout exporter: stdout, stderr, or a file path")
+	serveCmd.Flags().Float64("trace-sample-ratio", 1.0, "the fraction of new traces that are sampled, between 0 and 1")
+	serveCmd.Flags().String("trace-service-name", "railgun", "the service name of traces")
 
 	// Mask Flags
 	serveCmd.Flags().IntP("mask-max-zoom", "", 18, "maximum mask zoom level")
@@ -419,6 +560,20 @@ func init() {
 	serveCmd.Flags().StringP("cors-origin", "", "*", "value for Access-Control-Allow-Origin header")
 	serveCmd.Flags().StringP("cors-credentials", "", "false", "value for Access-Control-Allow-Credentials header")
 
+	// Scheduler Flags
+	serveCmd.Flags().BoolP("scheduler-enabled", "", true, "run jobs and workflows with a schedule")
+	serveCmd.Flags().DurationP("scheduler-sync-interval", "", time.Second*30, "the interval for syncing schedules from the catalog")
+
+	// Session Flags
+	serveCmd.Flags().DurationP("session-ttl", "", time.Minute*30, "the default time-to-live of an idle service session")
+	serveCmd.Flags().DurationP("session-max-ttl", "", time.Hour*24, "the maximum time-to-live of an idle service session")
+
+	// Runs Flags
+	serveCmd.Flags().StringP("runs-db", "", "", "path to the database of runs, e.g., ~/.railgun/runs.db, if empty then runs are only kept in memory")
+	serveCmd.Flags().DurationP("runs-retention", "", time.Hour*24*30, "how long runs are kept, zero to keep runs forever")
+	serveCmd.Flags().IntP("runs-max", "", 10000, "the maximum number of runs kept, zero for no limit")
+	serveCmd.Flags().DurationP("runs-prune-interval", "", time.Hour, "the interval for deleting runs past retention")
+
 	// Catalog Skip Errors
 	serveCmd.Flags().String("catalog-uri", "", "uri of the catalog backend")
 	serveCmd.Flags().BoolP("config-skip-errors", "", false, "skip loading config with bad errors")
@@ -431,5 +586,6 @@ func init() {
 	serveCmd.Flags().String("jwt-public-key-uri", "", "URI to public RSA Key for JWT")
 	serveCmd.Flags().StringArray("jwt-valid-methods", []string{"RS512"}, "Valid methods for JWT")
 	serveCmd.Flags().Duration("jwt-session-duration", 60*time.Minute, "duration of authenticated session")
+	serveCmd.Flags().StringArray("admin-user", []string{}, "a user allowed to use the admin endpoints, e.g., the cache, in addition to root")
 
 }
@@ -13,8 +13,12 @@ import (
 	"github.com/spatialcurrent/railgun/railgun/util"
 )
 
+// Input is the configuration of the input.
+// Uris are the uris given, which may include globs and s3 prefixes, and Uri is the resource currently being read.
 type Input struct {
-	Uri              string   `viper:"input-uri"`
+	Uris             []string `viper:"input-uri"`
+	Uri              string   `viper:"-"`
+	Parallelism      int      `viper:"input-parallelism"`
 	Format           string   `viper:"input-format"`
 	Header           []string `viper:"input-header"`
 	Comment          string   `viper:"input-comment"`
@@ -44,12 +48,24 @@ func (i Input) Path() string {
 	return inputPath
 }
 
+func (i Input) hasPrefix(prefix string) bool {
+	if strings.HasPrefix(i.Uri, prefix) {
+		return true
+	}
+	for _, uri := range i.Uris {
+		if strings.HasPrefix(uri, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
 func (i Input) IsAthenaStoredQuery() bool {
-	return strings.HasPrefix(i.Uri, "athena://")
+	return i.hasPrefix("athena://")
 }
 
 func (i Input) IsS3Bucket() bool {
-	return strings.HasPrefix(i.Uri, "s3://")
+	return i.hasPrefix("s3://")
 }
 
 func (i Input) IsEncrypted() bool {
@@ -70,7 +86,9 @@ func (i Input) Options() gss.Options {
 
 func (i Input) Map() map[string]interface{} {
 	return map[string]interface{}{
+		"Uris":             i.Uris,
 		"Uri":              i.Uri,
+		"Parallelism":      i.Parallelism,
 		"Format":           i.Format,
 		"Header":           i.Header,
 		"Comment":          i.Comment,
@@ -95,3 +113,12 @@ func (i *Input) Init() {
 		}
 	}
 }
+
+// WithUri returns a copy of the input for the resource at the uri, with the format and compression inferred from the uri if not given.
+func (i Input) WithUri(uri string) *Input {
+	i.Uris = []string{uri}
+	i.Uri = uri
+	i.Header = append([]string{}, i.Header...)
+	i.Init()
+	return &i
+}
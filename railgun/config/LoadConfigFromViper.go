@@ -3,6 +3,7 @@ package config
 import (
 	"github.com/spatialcurrent/viper"
 	"reflect"
+	"time"
 )
 
 func LoadConfigFromViper(c interface{}, v *viper.Viper) {
@@ -20,7 +21,9 @@ func LoadConfigFromViper(c interface{}, v *viper.Viper) {
 			}
 		} else {
 			if key, ok := structField.Tag.Lookup("viper"); ok && key != "" && key != "-" {
-				if fieldType.Kind() == reflect.String {
+				if fieldType == reflect.TypeOf(time.Duration(0)) {
+					fieldValue.SetInt(int64(v.GetDuration(key)))
+				} else if fieldType.Kind() == reflect.String {
 					fieldValue.SetString(v.GetString(key))
 				} else if fieldType.Kind() == reflect.Bool {
 					fieldValue.SetBool(v.GetBool(key))
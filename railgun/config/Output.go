@@ -2,6 +2,7 @@ package config
 
 import (
 	"strings"
+	"time"
 )
 
 import (
@@ -14,18 +15,22 @@ import (
 )
 
 type Output struct {
-	Uri          string   `viper:"output-uri"`
-	Format       string   `viper:"output-format"`
-	Header       []string `viper:"output-header"`
-	Comment      string   `viper:"output-comment"`
-	LazyQuotes   bool     `viper:"output-lazy-quotes"`
-	Append       bool     `viper:"output-append"`
-	BufferMemory bool     `viper:"output-buffer-memory"`
-	Compression  string   `viper:"output-compression"`
-	Passphrase   string   `viper:"output-passphrase"`
-	Salt         string   `viper:"output-salt"`
-	Limit        int      `viper:"output-limit"`
-	Mkdirs       bool     `viper:"output-mkdirs"`
+	Uri          string        `viper:"output-uri"`
+	Format       string        `viper:"output-format"`
+	Header       []string      `viper:"output-header"`
+	Comment      string        `viper:"output-comment"`
+	LazyQuotes   bool          `viper:"output-lazy-quotes"`
+	Append       bool          `viper:"output-append"`
+	BufferMemory bool          `viper:"output-buffer-memory"`
+	Compression  string        `viper:"output-compression"`
+	Passphrase   string        `viper:"output-passphrase"`
+	Salt         string        `viper:"output-salt"`
+	Key          string        `viper:"output-key"`
+	Limit        int           `viper:"output-limit"`
+	Mkdirs       bool          `viper:"output-mkdirs"`
+	MaxBytes     int           `viper:"output-max-bytes"`
+	MaxRecords   int           `viper:"output-max-records"`
+	MaxAge       time.Duration `viper:"output-max-age"`
 }
 
 func (o Output) CanStream() bool {
@@ -40,8 +45,13 @@ func (o Output) HasCompression() bool {
 	return len(o.Compression) > 0
 }
 
+// IsRotated returns true if output files are rotated into parts by size, record count, or age.
+func (o Output) IsRotated() bool {
+	return o.MaxBytes > 0 || o.MaxRecords > 0 || o.MaxAge > 0
+}
+
 func (o Output) IsEncrypted() bool {
-	return len(o.Passphrase) > 0
+	return len(o.Passphrase) > 0 || len(o.Key) > 0
 }
 
 func (o Output) Path() string {
@@ -77,8 +87,12 @@ func (o Output) Map() map[string]interface{} {
 		"Compression":  o.Compression,
 		"Passphrase":   o.Passphrase,
 		"Salt":         o.Salt,
+		"Key":          o.Key,
 		"Limit":        o.Limit,
 		"Mkdirs":       o.Mkdirs,
+		"MaxBytes":     o.MaxBytes,
+		"MaxRecords":   o.MaxRecords,
+		"MaxAge":       o.MaxAge.String(),
 	}
 }
 
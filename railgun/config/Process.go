@@ -19,6 +19,7 @@ type Process struct {
 	ErrorCompression string `viper:"error-compression"`
 	LogDestination   string `viper:"log-destination"`
 	LogCompression   string `viper:"log-compression"`
+	CheckpointUri    string `viper:"checkpoint-uri"`
 }
 
 func (p *Process) AWSSessionOptions() session.Options {
@@ -34,7 +35,7 @@ func (p *Process) HasAthenaStoredQuery() bool {
 }
 
 func (p *Process) HasS3Bucket() bool {
-	return (p.Input != nil && p.Input.IsS3Bucket()) || (p.Temp != nil && p.Temp.IsS3Bucket()) || (p.Output != nil && p.Output.IsS3Bucket()) || strings.HasPrefix(p.ErrorDestination, "s3://") || strings.HasPrefix(p.LogDestination, "s3://")
+	return (p.Input != nil && p.Input.IsS3Bucket()) || (p.Temp != nil && p.Temp.IsS3Bucket()) || (p.Output != nil && p.Output.IsS3Bucket()) || strings.HasPrefix(p.ErrorDestination, "s3://") || strings.HasPrefix(p.LogDestination, "s3://") || strings.HasPrefix(p.CheckpointUri, "s3://")
 }
 
 func (p *Process) InputOptions() gss.Options {
@@ -21,6 +21,8 @@ type DataStore struct {
 	Format      string     `rest:"format, the format of the data (default inferred from uri)"`
 	Compression string     `rest:"compression, the compression of the data (default inferred from uri)"`
 	Extent      []float64  `rest:"extent, the extent of the data"`
+	Critical    bool       `rest:"critical, the server is not ready if the data is not reachable"`
+	Key         string     `rest:"key, the name of the passphrase or master key that encrypts the data, resolved from the keyring or environment"`
 }
 
 func (ds DataStore) GetName() string {
@@ -37,6 +39,8 @@ func (ds DataStore) Map() map[string]interface{} {
 		"format":      ds.Format,
 		"compression": ds.Compression,
 		"extent":      dfl.Literal{Value: ds.Extent}.Dfl(dfl.DefaultQuotes, false, 0),
+		"critical":    ds.Critical,
+		"key":         ds.Key,
 	}
 }
 
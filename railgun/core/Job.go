@@ -10,6 +10,7 @@ package core
 import (
 	"github.com/spatialcurrent/go-dfl/dfl"
 	"reflect"
+	"time"
 )
 
 type Job struct {
@@ -18,7 +19,11 @@ type Job struct {
 	Title       string                 `rest:"title, the title of the job, not required"`
 	Description string                 `rest:"description, a verbose description of the job, not required"`
 	Variables   map[string]interface{} `rest:"variables, the input variables for the job"`
+	Input       dfl.Node               `rest:"input, a DFL expression for the input of the job, e.g., $jobs.clean.output"`
 	Output      *DataStore             `rest:"output, the output for the job"`
+	Retry       *Retry                 `rest:"retry, the retry policy for the job, e.g., {attempts: 3, backoff: '1s', retryOn: 'input,output,timeout'}"`
+	Timeout     time.Duration          `rest:"timeout, the timeout for each attempt of the job, e.g., 5m"`
+	Schedule    *Schedule              `rest:"schedule, the cron schedule for running the job, e.g., {cron: '0 * * * *', timezone: 'UTC', overlap: skip, jitter: '30s'}"`
 }
 
 func (j Job) GetName() string {
@@ -39,9 +44,21 @@ func (j Job) Map() map[string]interface{} {
 	if len(variables) > 0 {
 		m["variables"] = dfl.Dictionary{Nodes: variables}.Dfl(dfl.DefaultQuotes, false, 0)
 	}
+	if j.Input != nil {
+		m["input"] = j.Input.Dfl(dfl.DefaultQuotes, false, 0)
+	}
 	if j.Output != nil {
 		m["output"] = j.Output.Name
 	}
+	if j.Retry != nil {
+		m["retry"] = j.Retry.Dfl()
+	}
+	if j.Timeout > 0 {
+		m["timeout"] = j.Timeout.String()
+	}
+	if j.Schedule != nil {
+		m["schedule"] = j.Schedule.Dfl()
+	}
 	return m
 }
 
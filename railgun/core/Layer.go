@@ -11,6 +11,7 @@ import (
 	"github.com/spatialcurrent/go-dfl/dfl"
 	"github.com/spatialcurrent/railgun/railgun/cache"
 	"reflect"
+	"time"
 )
 
 type Layer struct {
@@ -22,7 +23,9 @@ type Layer struct {
 	Defaults    map[string]interface{} `rest:"defaults, the default values of the variables for this service"`
 	Extent      []float64              `rest:"extent, the extent of the data"`
 	Tags        []string               `rest:"tags, tags for the service"`
-	Cache       *cache.Cache
+	Ttl         time.Duration          `rest:"ttl, how long the data is cached, e.g., 1h, defaults to the cache default expiration"`
+	Pinned      bool                   `rest:"pinned, the data is never evicted to make room in the cache"`
+	Cache       *cache.Group
 }
 
 func (l Layer) GetName() string {
@@ -36,6 +39,10 @@ func (l Layer) Map() map[string]interface{} {
 		"description": l.Description,
 		"datastore":   l.DataStore.Name,
 		"extent":      l.Extent,
+		"pinned":      l.Pinned,
+	}
+	if l.Ttl > 0 {
+		m["ttl"] = l.Ttl.String()
 	}
 	if l.Node != nil {
 		m["expression"] = l.Node.Dfl(dfl.DefaultQuotes, false, 0)
@@ -0,0 +1,220 @@
+// =================================================================
+//
+// Copyright (C) 2018 Spatial Current, Inc. - All Rights Reserved
+// Released as open source under the MIT License.  See LICENSE file.
+//
+// =================================================================
+
+package core
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/spatialcurrent/go-dfl/dfl"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+const (
+	ParameterTypeString = "string"
+	ParameterTypeInt    = "int"
+	ParameterTypeFloat  = "float"
+	ParameterTypeBool   = "bool"
+	ParameterTypeBbox   = "bbox"
+	ParameterTypeEnum   = "enum"
+	ParameterTypeArray  = "array"
+)
+
+var ParameterTypes = []string{
+	ParameterTypeString,
+	ParameterTypeInt,
+	ParameterTypeFloat,
+	ParameterTypeBool,
+	ParameterTypeBbox,
+	ParameterTypeEnum,
+	ParameterTypeArray,
+}
+
+// Parameter is a typed variable declared by a process.
+type Parameter struct {
+	Name        string      `rest:"name, the name of the variable" required:"yes"`
+	Type        string      `rest:"type, the type of the variable: string, int, float, bool, bbox, enum, or array" required:"yes"`
+	Description string      `rest:"description, a description of the variable"`
+	Required    bool        `rest:"required, the variable is required if it has no default"`
+	Default     interface{} `rest:"default, the default value of the variable"`
+	Minimum     *float64    `rest:"minimum, the minimum value of a number or the minimum length of a string or array"`
+	Maximum     *float64    `rest:"maximum, the maximum value of a number or the maximum length of a string or array"`
+	Values      []string    `rest:"values, the comma-separated values allowed for an enum"`
+}
+
+// Convert validates the value and returns it as the declared type.
+// Ints are returned as int, floats as float64, bboxes as []float64, enums as string, and arrays as []interface{}.
+func (p *Parameter) Convert(value interface{}) (interface{}, error) {
+	switch p.Type {
+	case ParameterTypeString:
+		str, ok := value.(string)
+		if !ok {
+			return nil, errors.New("must be a string")
+		}
+		if err := p.checkRange(float64(len(str)), "length"); err != nil {
+			return nil, err
+		}
+		return str, nil
+	case ParameterTypeInt:
+		f, ok := toFloat(value)
+		if !ok || f != math.Trunc(f) {
+			return nil, errors.New("must be an integer")
+		}
+		if err := p.checkRange(f, "value"); err != nil {
+			return nil, err
+		}
+		return int(f), nil
+	case ParameterTypeFloat:
+		f, ok := toFloat(value)
+		if !ok {
+			return nil, errors.New("must be a number")
+		}
+		if err := p.checkRange(f, "value"); err != nil {
+			return nil, err
+		}
+		return f, nil
+	case ParameterTypeBool:
+		switch v := value.(type) {
+		case bool:
+			return v, nil
+		case string:
+			b, err := strconv.ParseBool(v)
+			if err == nil {
+				return b, nil
+			}
+		}
+		return nil, errors.New("must be a boolean")
+	case ParameterTypeBbox:
+		values := toSlice(value)
+		if len(values) != 4 {
+			return nil, errors.New("must be a bounding box with 4 numbers: minx, miny, maxx, maxy")
+		}
+		bbox := make([]float64, 0, 4)
+		for _, v := range values {
+			f, ok := toFloat(v)
+			if !ok {
+				return nil, errors.New("must be a bounding box with 4 numbers: minx, miny, maxx, maxy")
+			}
+			bbox = append(bbox, f)
+		}
+		if bbox[0] > bbox[2] || bbox[1] > bbox[3] {
+			return nil, errors.New("must be a bounding box with minx <= maxx and miny <= maxy")
+		}
+		return bbox, nil
+	case ParameterTypeEnum:
+		str := fmt.Sprint(value)
+		for _, v := range p.Values {
+			if v == str {
+				return str, nil
+			}
+		}
+		return nil, errors.New("must be one of " + strings.Join(p.Values, ", "))
+	case ParameterTypeArray:
+		values := toSlice(value)
+		if values == nil {
+			return nil, errors.New("must be an array")
+		}
+		if err := p.checkRange(float64(len(values)), "length"); err != nil {
+			return nil, err
+		}
+		return values, nil
+	}
+	return nil, errors.New("has unknown type " + p.Type)
+}
+
+func (p *Parameter) checkRange(f float64, what string) error {
+	if p.Minimum != nil && f < *p.Minimum {
+		return errors.New("must have a " + what + " greater than or equal to " + strconv.FormatFloat(*p.Minimum, 'f', -1, 64))
+	}
+	if p.Maximum != nil && f > *p.Maximum {
+		return errors.New("must have a " + what + " less than or equal to " + strconv.FormatFloat(*p.Maximum, 'f', -1, 64))
+	}
+	return nil
+}
+
+func (p Parameter) Map() map[string]interface{} {
+	m := map[string]interface{}{
+		"type":     p.Type,
+		"required": p.Required,
+	}
+	if len(p.Description) > 0 {
+		m["description"] = p.Description
+	}
+	if p.Default != nil {
+		m["default"] = p.Default
+	}
+	if p.Minimum != nil {
+		m["minimum"] = *p.Minimum
+	}
+	if p.Maximum != nil {
+		m["maximum"] = *p.Maximum
+	}
+	if len(p.Values) > 0 {
+		m["values"] = strings.Join(p.Values, ",")
+	}
+	return m
+}
+
+func (p Parameter) Node() dfl.Node {
+	dict := map[dfl.Node]dfl.Node{}
+	for k, v := range p.Map() {
+		dict[dfl.Literal{Value: k}] = dfl.Literal{Value: v}
+	}
+	return dfl.Dictionary{Nodes: dict}
+}
+
+func (p Parameter) Dfl() string {
+	return p.Node().Dfl(dfl.DefaultQuotes, false, 0)
+}
+
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case int:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case float32:
+		return float64(v), true
+	case float64:
+		return v, true
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// toSlice returns the value as a slice.  Strings are split on commas.
+// Returns nil if the value is not a slice or string.
+func toSlice(value interface{}) []interface{} {
+	if str, ok := value.(string); ok {
+		values := make([]interface{}, 0)
+		for _, s := range strings.Split(str, ",") {
+			if s = strings.TrimSpace(s); len(s) > 0 {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	if value == nil {
+		return nil
+	}
+	v := reflect.ValueOf(value)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return nil
+	}
+	values := make([]interface{}, 0, v.Len())
+	for i := 0; i < v.Len(); i++ {
+		values = append(values, v.Index(i).Interface())
+	}
+	return values
+}
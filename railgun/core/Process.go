@@ -9,15 +9,17 @@ package core
 
 import (
 	"github.com/spatialcurrent/go-dfl/dfl"
+	rerrors "github.com/spatialcurrent/railgun/railgun/errors"
 	"reflect"
 )
 
 type Process struct {
-	Name        string   `rest:"name, the unique name of the process" required:"yes"`
-	Title       string   `rest:"title, the title of the process"`
-	Description string   `rest:"description, a verbose description of the process"`
-	Node        dfl.Node `rest:"expression, the DFL expression of the process" required:"yes"`
-	Tags        []string `rest:"tags, tags for the service"`
+	Name        string       `rest:"name, the unique name of the process" required:"yes"`
+	Title       string       `rest:"title, the title of the process"`
+	Description string       `rest:"description, a verbose description of the process"`
+	Node        dfl.Node     `rest:"expression, the DFL expression of the process" required:"yes"`
+	Tags        []string     `rest:"tags, tags for the service"`
+	Parameters  []*Parameter `rest:"parameters, a dictionary of the typed variables of the process, by name"`
 }
 
 func (p Process) GetName() string {
@@ -39,9 +41,52 @@ func (p Process) Map() map[string]interface{} {
 	if len(tags) > 0 {
 		m["tags"] = dfl.Array{Nodes: tags}.Dfl(dfl.DefaultQuotes, false, 0)
 	}
+	if len(p.Parameters) > 0 {
+		parameters := map[dfl.Node]dfl.Node{}
+		for _, param := range p.Parameters {
+			parameters[dfl.Literal{Value: param.Name}] = param.Node()
+		}
+		m["parameters"] = dfl.Dictionary{Nodes: parameters}.Dfl(dfl.DefaultQuotes, false, 0)
+	}
 	return m
 }
 
+// Validate checks the variables against the parameters declared by the process.
+// Returns a copy of the variables with defaults applied and declared variables converted to their types.
+// Variables that are not declared are passed through unchanged.
+func (p Process) Validate(variables map[string]interface{}) (map[string]interface{}, error) {
+	if len(p.Parameters) == 0 {
+		return variables, nil
+	}
+	vars := make(map[string]interface{}, len(variables))
+	for k, v := range variables {
+		vars[k] = v
+	}
+	invalid := map[string]string{}
+	for _, param := range p.Parameters {
+		value, ok := vars[param.Name]
+		if !ok || value == nil {
+			if param.Default == nil {
+				if param.Required {
+					invalid[param.Name] = "is required"
+				}
+				continue
+			}
+			value = param.Default
+		}
+		converted, err := param.Convert(value)
+		if err != nil {
+			invalid[param.Name] = err.Error()
+			continue
+		}
+		vars[param.Name] = converted
+	}
+	if len(invalid) > 0 {
+		return vars, &rerrors.ErrInvalidVariables{Errors: invalid}
+	}
+	return vars, nil
+}
+
 func (p Process) Dfl() string {
 	dict := map[dfl.Node]dfl.Node{}
 	for k, v := range p.Map() {
@@ -0,0 +1,77 @@
+// =================================================================
+//
+// Copyright (C) 2018 Spatial Current, Inc. - All Rights Reserved
+// Released as open source under the MIT License.  See LICENSE file.
+//
+// =================================================================
+
+package core
+
+import (
+	"github.com/spatialcurrent/go-dfl/dfl"
+	"math"
+	"strings"
+	"time"
+)
+
+const (
+	ErrorClassInput   = "input"   // error reading the input of a job
+	ErrorClassProcess = "process" // error evaluating the process of a job
+	ErrorClassOutput  = "output"  // error writing the output of a job
+	ErrorClassTimeout = "timeout" // the job did not complete before its timeout
+)
+
+var ErrorClasses = []string{ErrorClassInput, ErrorClassProcess, ErrorClassOutput, ErrorClassTimeout}
+
+// DefaultRetryOn are the error classes that are retried by default.
+// Process errors are not retried by default, since evaluating the same input again usually fails the same way.
+var DefaultRetryOn = []string{ErrorClassInput, ErrorClassOutput, ErrorClassTimeout}
+
+// Retry is the retry policy for a job.
+type Retry struct {
+	Attempts   int           `rest:"attempts, the maximum number of attempts, including the first attempt"`
+	Backoff    time.Duration `rest:"backoff, the delay before the first retry, e.g., 1s"`
+	MaxBackoff time.Duration `rest:"maxBackoff, the maximum delay between attempts, e.g., 1m"`
+	Multiplier float64       `rest:"multiplier, the factor the delay is multiplied by after each retry, defaults to 2"`
+	RetryOn    []string      `rest:"retryOn, the comma-separated classes of errors that are retried: input, process, output, timeout"`
+}
+
+// Delay returns the delay before the next attempt, given the number of failed attempts.
+func (r *Retry) Delay(failed int) time.Duration {
+	d := float64(r.Backoff) * math.Pow(r.Multiplier, float64(failed-1))
+	if r.MaxBackoff > 0 && d > float64(r.MaxBackoff) {
+		return r.MaxBackoff
+	}
+	return time.Duration(d)
+}
+
+// Retryable returns true if errors of the given class are retried.
+func (r *Retry) Retryable(class string) bool {
+	for _, c := range r.RetryOn {
+		if c == class {
+			return true
+		}
+	}
+	return false
+}
+
+func (r Retry) Map() map[string]interface{} {
+	m := map[string]interface{}{
+		"attempts":   r.Attempts,
+		"backoff":    r.Backoff.String(),
+		"multiplier": r.Multiplier,
+		"retryOn":    strings.Join(r.RetryOn, ","),
+	}
+	if r.MaxBackoff > 0 {
+		m["maxBackoff"] = r.MaxBackoff.String()
+	}
+	return m
+}
+
+func (r Retry) Dfl() string {
+	dict := map[dfl.Node]dfl.Node{}
+	for k, v := range r.Map() {
+		dict[dfl.Literal{Value: k}] = dfl.Literal{Value: v}
+	}
+	return dfl.Dictionary{Nodes: dict}.Dfl(dfl.DefaultQuotes, false, 0)
+}
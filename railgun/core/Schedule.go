@@ -0,0 +1,107 @@
+// =================================================================
+//
+// Copyright (C) 2018 Spatial Current, Inc. - All Rights Reserved
+// Released as open source under the MIT License.  See LICENSE file.
+//
+// =================================================================
+
+package core
+
+import (
+	"github.com/pkg/errors"
+	"github.com/robfig/cron"
+	"github.com/spatialcurrent/go-dfl/dfl"
+	"time"
+)
+
+const (
+	OverlapSkip    = "skip"    // skip a scheduled run if the previous run is still running
+	OverlapQueue   = "queue"   // run again once the previous run completes
+	OverlapReplace = "replace" // cancel the previous run and start a new one
+)
+
+var OverlapPolicies = []string{OverlapSkip, OverlapQueue, OverlapReplace}
+
+// Schedule is a cron schedule for a job or workflow that is executed by railgun serve.
+type Schedule struct {
+	Cron     string        `rest:"cron, a standard 5-field cron expression, e.g., 0 * * * *" required:"yes"`
+	Timezone string        `rest:"timezone, the IANA time zone for the cron expression, defaults to UTC"`
+	Overlap  string        `rest:"overlap, the policy when a run is still running: skip, queue, or replace, defaults to skip"`
+	Jitter   time.Duration `rest:"jitter, the maximum random delay added to each run, e.g., 30s"`
+	cron     cron.Schedule
+	location *time.Location
+}
+
+func NewSchedule(expression string, timezone string, overlap string, jitter time.Duration) (*Schedule, error) {
+
+	c, err := cron.ParseStandard(expression)
+	if err != nil {
+		return nil, errors.Wrap(err, "error parsing cron expression "+expression)
+	}
+
+	if len(timezone) == 0 {
+		timezone = "UTC"
+	}
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, errors.Wrap(err, "error loading timezone "+timezone)
+	}
+
+	if len(overlap) == 0 {
+		overlap = OverlapSkip
+	}
+	valid := false
+	for _, policy := range OverlapPolicies {
+		if overlap == policy {
+			valid = true
+			break
+		}
+	}
+	if !valid {
+		return nil, errors.New("invalid overlap policy " + overlap)
+	}
+
+	if jitter < 0 {
+		return nil, errors.New("jitter cannot be negative")
+	}
+
+	s := &Schedule{
+		Cron:     expression,
+		Timezone: timezone,
+		Overlap:  overlap,
+		Jitter:   jitter,
+		cron:     c,
+		location: location,
+	}
+	return s, nil
+}
+
+// Next returns the next time the schedule fires after the given time, not including jitter.
+func (s *Schedule) Next(t time.Time) time.Time {
+	return s.cron.Next(t.In(s.location))
+}
+
+// Equal returns true if the schedules have the same settings.
+func (s *Schedule) Equal(o *Schedule) bool {
+	return s.Cron == o.Cron && s.Timezone == o.Timezone && s.Overlap == o.Overlap && s.Jitter == o.Jitter
+}
+
+func (s Schedule) Map() map[string]interface{} {
+	m := map[string]interface{}{
+		"cron":     s.Cron,
+		"timezone": s.Timezone,
+		"overlap":  s.Overlap,
+	}
+	if s.Jitter > 0 {
+		m["jitter"] = s.Jitter.String()
+	}
+	return m
+}
+
+func (s Schedule) Dfl() string {
+	dict := map[dfl.Node]dfl.Node{}
+	for k, v := range s.Map() {
+		dict[dfl.Literal{Value: k}] = dfl.Literal{Value: v}
+	}
+	return dfl.Dictionary{Nodes: dict}.Dfl(dfl.DefaultQuotes, false, 0)
+}
@@ -10,6 +10,7 @@ package core
 import (
 	"github.com/spatialcurrent/go-dfl/dfl"
 	"reflect"
+	"time"
 )
 
 type Workflow struct {
@@ -18,6 +19,9 @@ type Workflow struct {
 	Description string                 `rest:"description, a verbose description of the workflow, not required"`
 	Variables   map[string]interface{} `rest:"variables, global variables for the workflow"`
 	Jobs        []*Job                 `rest:"jobs, the jobs for the workflow, in order of execution" required:"yes"`
+	Retry       *Retry                 `rest:"retry, the default retry policy for jobs in the workflow without their own"`
+	Timeout     time.Duration          `rest:"timeout, the timeout for the entire workflow, e.g., 1h"`
+	Schedule    *Schedule              `rest:"schedule, the cron schedule for running the workflow, e.g., {cron: '0 * * * *', timezone: 'UTC', overlap: skip, jitter: '30s'}"`
 }
 
 func (w Workflow) GetName() string {
@@ -44,6 +48,15 @@ func (w Workflow) Map() map[string]interface{} {
 	if len(variables) > 0 {
 		m["variables"] = dfl.Dictionary{Nodes: variables}.Dfl(dfl.DefaultQuotes, false, 0)
 	}
+	if w.Retry != nil {
+		m["retry"] = w.Retry.Dfl()
+	}
+	if w.Timeout > 0 {
+		m["timeout"] = w.Timeout.String()
+	}
+	if w.Schedule != nil {
+		m["schedule"] = w.Schedule.Dfl()
+	}
 	return m
 }
 
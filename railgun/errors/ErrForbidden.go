@@ -0,0 +1,17 @@
+// =================================================================
+//
+// Copyright (C) 2018 Spatial Current, Inc. - All Rights Reserved
+// Released as open source under the MIT License.  See LICENSE file.
+//
+// =================================================================
+
+package errors
+
+// ErrForbidden is returned when the user of a request is not allowed to perform it.
+type ErrForbidden struct {
+	User string
+}
+
+func (e *ErrForbidden) Error() string {
+	return "user " + e.User + " is not allowed to perform this request"
+}
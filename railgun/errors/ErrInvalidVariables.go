@@ -0,0 +1,32 @@
+// =================================================================
+//
+// Copyright (C) 2018 Spatial Current, Inc. - All Rights Reserved
+// Released as open source under the MIT License.  See LICENSE file.
+//
+// =================================================================
+
+package errors
+
+import (
+	"sort"
+	"strings"
+)
+
+// ErrInvalidVariables is returned when variables do not match the parameters declared by a process.
+// Errors is a map of variable name to the reason the variable is invalid.
+type ErrInvalidVariables struct {
+	Errors map[string]string
+}
+
+func (e *ErrInvalidVariables) Error() string {
+	names := make([]string, 0, len(e.Errors))
+	for name := range e.Errors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	reasons := make([]string, 0, len(names))
+	for _, name := range names {
+		reasons = append(reasons, name+" "+e.Errors[name])
+	}
+	return "invalid variables: " + strings.Join(reasons, "; ")
+}
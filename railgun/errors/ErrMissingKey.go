@@ -0,0 +1,17 @@
+// =================================================================
+//
+// Copyright (C) 2018 Spatial Current, Inc. - All Rights Reserved
+// Released as open source under the MIT License.  See LICENSE file.
+//
+// =================================================================
+
+package errors
+
+// ErrMissingKey is returned when a key referenced by a data store is not in the keyring or the environment.
+type ErrMissingKey struct {
+	Name string
+}
+
+func (e *ErrMissingKey) Error() string {
+	return "key " + e.Name + " is not in the keyring or the environment"
+}
@@ -0,0 +1,17 @@
+// =================================================================
+//
+// Copyright (C) 2018 Spatial Current, Inc. - All Rights Reserved
+// Released as open source under the MIT License.  See LICENSE file.
+//
+// =================================================================
+
+package errors
+
+// ErrNotAcceptable is returned when none of the media types in the Accept header of a request are supported.
+type ErrNotAcceptable struct {
+	Accept string
+}
+
+func (e *ErrNotAcceptable) Error() string {
+	return "none of the media types in \"" + e.Accept + "\" are supported"
+}
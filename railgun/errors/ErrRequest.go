@@ -0,0 +1,23 @@
+// =================================================================
+//
+// Copyright (C) 2018 Spatial Current, Inc. - All Rights Reserved
+// Released as open source under the MIT License.  See LICENSE file.
+//
+// =================================================================
+
+package errors
+
+// ErrRequest is an error that occurred while handling the request with the given id.
+type ErrRequest struct {
+	RequestId string
+	Err       error
+}
+
+func (e *ErrRequest) Error() string {
+	return "request " + e.RequestId + ": " + e.Err.Error()
+}
+
+// Cause returns the underlying error, so errors.Cause returns the original error.
+func (e *ErrRequest) Cause() error {
+	return e.Err
+}
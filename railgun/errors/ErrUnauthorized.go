@@ -0,0 +1,15 @@
+// =================================================================
+//
+// Copyright (C) 2018 Spatial Current, Inc. - All Rights Reserved
+// Released as open source under the MIT License.  See LICENSE file.
+//
+// =================================================================
+
+package errors
+
+// ErrUnauthorized is returned when a request requires credentials but has none or the credentials are invalid.
+type ErrUnauthorized struct{}
+
+func (e *ErrUnauthorized) Error() string {
+	return "authorization is required"
+}
@@ -11,7 +11,6 @@ import (
 	"github.com/pkg/errors"
 	"github.com/spatialcurrent/go-try-get/gtg"
 	rerrors "github.com/spatialcurrent/railgun/railgun/errors"
-	"github.com/spatialcurrent/railgun/railgun/util"
 	"io/ioutil"
 	"net/http"
 	"reflect"
@@ -24,13 +23,16 @@ type AuthenticateHandler struct {
 
 func (h *AuthenticateHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
-	_, format, _ := util.SplitNameFormatCompression(r.URL.Path)
+	format, ok := h.NegotiateFormat(w, r)
+	if !ok {
+		return
+	}
 
 	switch r.Method {
 	case "POST":
 		statusCode, obj, err := h.Post(w, r, format)
 		if err != nil {
-			h.Messages <- err
+			h.SendError(r, err)
 			err = h.RespondWithError(w, err, format)
 			if err != nil {
 				panic(err)
@@ -38,7 +40,7 @@ func (h *AuthenticateHandler) ServeHTTP(w http.ResponseWriter, r *http.Request)
 		} else {
 			err = h.RespondWithObject(w, statusCode, obj, format)
 			if err != nil {
-				h.Messages <- err
+				h.SendError(r, err)
 				err = h.RespondWithError(w, err, format)
 				if err != nil {
 					panic(err)
@@ -62,7 +64,7 @@ func (h *AuthenticateHandler) Post(w http.ResponseWriter, r *http.Request, forma
 		return http.StatusInternalServerError, nil, errors.Wrap(err, "error reading from request body")
 	}
 
-	inputObject, err := h.ParseBody(body, format)
+	inputObject, err := h.ParseBody(r, body, format)
 	if err != nil {
 		return http.StatusBadRequest, nil, err
 	}
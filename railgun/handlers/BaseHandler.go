@@ -18,19 +18,28 @@ import (
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
 	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/gorilla/mux"
 	gocache "github.com/patrickmn/go-cache"
 	"github.com/pkg/errors"
 	"github.com/spatialcurrent/go-simple-serializer/gss"
 	"github.com/spatialcurrent/railgun/railgun/catalog"
 	rerrors "github.com/spatialcurrent/railgun/railgun/errors"
+	"github.com/spatialcurrent/railgun/railgun/keyring"
+	"github.com/spatialcurrent/railgun/railgun/metrics"
 	"github.com/spatialcurrent/railgun/railgun/request"
+	"github.com/spatialcurrent/railgun/railgun/runner"
+	"github.com/spatialcurrent/railgun/railgun/runs"
 	"github.com/spatialcurrent/railgun/railgun/util"
 	"github.com/spatialcurrent/viper"
+	"github.com/vmihailenco/msgpack"
 	"net/http"
 	"strings"
 	"time"
 )
 
+// DefaultFormat is the format of responses when the Accept header of a request allows any format.
+const DefaultFormat = "json"
+
 type BaseHandler struct {
 	Viper           *viper.Viper
 	Catalog         *catalog.RailgunCatalog
@@ -42,6 +51,9 @@ type BaseHandler struct {
 	PrivateKey      *rsa.PrivateKey
 	SessionDuration time.Duration
 	ValidMethods    []string
+	Runs            runs.Store
+	Metrics         *metrics.Metrics
+	Keyring         *keyring.Keyring
 }
 
 func (h *BaseHandler) GetAuthorization(r *http.Request) (string, error) {
@@ -92,6 +104,52 @@ func (h *BaseHandler) ParseAuthorization(str string) (*jwt.StandardClaims, error
 	return token.Claims.(*jwt.StandardClaims), nil
 }
 
+// GetUser returns the subject of the authorization token in the request, or anonymous if the request is not authenticated.
+func (h *BaseHandler) GetUser(r *http.Request) string {
+	authorization, err := h.GetAuthorization(r)
+	if err != nil {
+		return "anonymous"
+	}
+	claims, err := h.ParseAuthorization(authorization)
+	if err != nil {
+		return "anonymous"
+	}
+	return claims.Subject
+}
+
+// AuthorizeAdmin returns an error if the user of the request is not the root user or one of the admin users,
+// which are configured with the admin-user flag.
+func (h *BaseHandler) AuthorizeAdmin(r *http.Request) error {
+	user := h.GetUser(r)
+	if user == "anonymous" {
+		return &rerrors.ErrUnauthorized{}
+	}
+	if user == "root" {
+		return nil
+	}
+	if h.Viper != nil {
+		for _, admin := range h.Viper.GetStringArray("admin-user") {
+			if user == admin {
+				return nil
+			}
+		}
+	}
+	return &rerrors.ErrForbidden{User: user}
+}
+
+// AuthorizeRuns returns the user whose runs the request can read, or a blank string if the user is an admin and can read every run.
+// Returns an error if the request is not authenticated.
+func (h *BaseHandler) AuthorizeRuns(r *http.Request) (string, error) {
+	err := h.AuthorizeAdmin(r)
+	if err == nil {
+		return "", nil
+	}
+	if _, ok := err.(*rerrors.ErrForbidden); ok {
+		return h.GetUser(r), nil
+	}
+	return "", err
+}
+
 func (h *BaseHandler) GetAWSSessionId(awsAccessKeyId string, awsSessionToken string) string {
 
 	if len(awsAccessKeyId) > 0 {
@@ -126,7 +184,98 @@ func (h *BaseHandler) GetAWSS3Client() (*s3.S3, error) {
 	return s3.New(awsSession), nil
 }
 
-func (h *BaseHandler) ParseBody(inputBytes []byte, format string) (interface{}, error) {
+func (h *BaseHandler) NewRunner() *runner.Runner {
+	return &runner.Runner{GetS3Client: h.GetAWSS3Client, Metrics: h.Metrics, Keyring: h.Keyring}
+}
+
+// StartRun records the start of a run of a service, job, or workflow by the caller of the request.
+func (h *BaseHandler) StartRun(r *http.Request, t string, name string) *runs.Run {
+	run := runs.NewRun(t, name, runs.TriggerApi)
+	run.User = h.GetUser(r)
+	run.Status = runs.StatusRunning
+	run.Start = time.Now()
+	h.SaveRun(run)
+	return run
+}
+
+// FinishRun records the end of the run.
+// If the run has not already been marked as failed, then the run succeeded if err is nil.
+func (h *BaseHandler) FinishRun(run *runs.Run, err error) {
+	run.End = time.Now()
+	if err != nil {
+		run.Status = runs.StatusFailed
+		run.Error = err.Error()
+	} else if run.Status == runs.StatusRunning {
+		run.Status = runs.StatusSucceeded
+	}
+	h.SaveRun(run)
+}
+
+func (h *BaseHandler) SaveRun(run *runs.Run) {
+	if h.Runs == nil {
+		return
+	}
+	if err := h.Runs.Save(run); err != nil {
+		h.Errors <- errors.Wrap(err, "error saving run "+run.Id)
+	}
+}
+
+// SendError sends the error to the errors channel.
+// If the request is logged, then the error includes the id of the request and is added to the access log.
+func (h *BaseHandler) SendError(r *http.Request, err error) {
+	if ar := request.GetAccessRequest(r); ar != nil {
+		ar.Error = err.Error()
+		err = &rerrors.ErrRequest{RequestId: ar.Id, Err: err}
+	}
+	h.Errors <- err
+}
+
+// GetFormat returns the format of the response.
+// If the route has an extension, then the format is the extension.
+// Otherwise, the format is negotiated with the Accept header of the request.
+func (h *BaseHandler) GetFormat(r *http.Request) (string, error) {
+	if _, ok := mux.Vars(r)["ext"]; ok {
+		_, format, _ := util.SplitNameFormatCompression(r.URL.Path)
+		return format, nil
+	}
+	return util.NegotiateFormat(r.Header.Get("Accept"), DefaultFormat)
+}
+
+// NegotiateFormat returns the format of the response, as returned by GetFormat.
+// If no format is acceptable, then responds with 406 Not Acceptable and returns false.
+func (h *BaseHandler) NegotiateFormat(w http.ResponseWriter, r *http.Request) (string, bool) {
+	format, err := h.GetFormat(r)
+	if err != nil {
+		err = h.RespondWithError(w, err, DefaultFormat)
+		if err != nil {
+			panic(err)
+		}
+		return "", false
+	}
+	return format, true
+}
+
+// ParseBody deserializes the body of the request.
+// The format of the body is the Content-Type of the request, if known, and otherwise the given format.
+func (h *BaseHandler) ParseBody(r *http.Request, inputBytes []byte, format string) (interface{}, error) {
+
+	if f, ok := util.ContentTypeFormat(r.Header.Get("Content-Type")); ok {
+		format = f
+	}
+
+	switch format {
+	case "msgpack":
+		var inputObject interface{}
+		err := msgpack.Unmarshal(inputBytes, &inputObject)
+		if err != nil {
+			return nil, errors.Wrap(err, "error deserializing body")
+		}
+		return inputObject, nil
+	case "geojson":
+		format = "json"
+	case "", "html":
+		format = DefaultFormat
+	}
 
 	inputType, err := gss.GetType(inputBytes, format)
 	if err != nil {
@@ -143,6 +292,17 @@ func (h *BaseHandler) ParseBody(inputBytes []byte, format string) (interface{},
 	return inputObject, nil
 }
 
+// SerializeBytes serializes the object for a response in the given format.
+func (h *BaseHandler) SerializeBytes(obj interface{}, format string) ([]byte, error) {
+	switch format {
+	case "msgpack":
+		return msgpack.Marshal(obj)
+	case "geojson":
+		return gss.SerializeBytes(obj, "json", []string{}, gss.NoLimit)
+	}
+	return gss.SerializeBytes(obj, format, []string{}, gss.NoLimit)
+}
+
 func (h *BaseHandler) RespondWithObject(w http.ResponseWriter, statusCode int, obj interface{}, format string) error {
 
 	if format == "html" {
@@ -203,24 +363,12 @@ func (h *BaseHandler) RespondWithObject(w http.ResponseWriter, statusCode int, o
 		return nil
 	}
 
-	b, err := gss.SerializeBytes(obj, format, []string{}, gss.NoLimit)
+	b, err := h.SerializeBytes(obj, format)
 	if err != nil {
 		return errors.Wrap(err, "error serializing response body")
 	}
 
-	contentType := "text/plain; charset=utf-8"
-	switch format {
-	case "bson":
-		contentType = "application/ubjson"
-	case "json":
-		contentType = "application/json"
-	case "toml":
-		contentType = "application/toml"
-	case "yaml", "yml":
-		contentType = "text/yaml"
-	}
-
-	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Type", util.ContentType(format))
 	if statusCode != http.StatusOK {
 		w.WriteHeader(statusCode)
 	}
@@ -230,18 +378,35 @@ func (h *BaseHandler) RespondWithObject(w http.ResponseWriter, statusCode int, o
 
 func (h *BaseHandler) RespondWithError(w http.ResponseWriter, err error, format string) error {
 
-	b, serr := gss.SerializeBytes(map[string]interface{}{"success": false, "error": err.Error()}, format, []string{}, gss.NoLimit)
+	obj := map[string]interface{}{"success": false, "error": err.Error()}
+	if e, ok := errors.Cause(err).(*rerrors.ErrInvalidVariables); ok {
+		obj["fields"] = e.Errors
+	}
+
+	format = errorFormat(format)
+	b, serr := h.SerializeBytes(obj, format)
 	if serr != nil {
 		return serr
 	}
 
+	w.Header().Set("Content-Type", util.ContentType(format))
 	switch errors.Cause(err).(type) {
 	case *rerrors.ErrMissingRequiredParameter:
 		w.WriteHeader(http.StatusBadRequest)
+	case *rerrors.ErrInvalidParameter:
+		w.WriteHeader(http.StatusBadRequest)
+	case *rerrors.ErrInvalidVariables:
+		w.WriteHeader(http.StatusBadRequest)
 	case *rerrors.ErrMissingObject:
 		w.WriteHeader(http.StatusNotFound)
 	case *rerrors.ErrDependent:
 		w.WriteHeader(http.StatusBadRequest)
+	case *rerrors.ErrNotAcceptable:
+		w.WriteHeader(http.StatusNotAcceptable)
+	case *rerrors.ErrUnauthorized:
+		w.WriteHeader(http.StatusUnauthorized)
+	case *rerrors.ErrForbidden:
+		w.WriteHeader(http.StatusForbidden)
 	default:
 		w.WriteHeader(http.StatusInternalServerError)
 	}
@@ -251,11 +416,23 @@ func (h *BaseHandler) RespondWithError(w http.ResponseWriter, err error, format
 }
 
 func (h *BaseHandler) RespondWithNotImplemented(w http.ResponseWriter, format string) error {
-	b, err := gss.SerializeBytes(map[string]interface{}{"success": false, "error": "not implemented"}, format, []string{}, gss.NoLimit)
+	format = errorFormat(format)
+	b, err := h.SerializeBytes(map[string]interface{}{"success": false, "error": "not implemented"}, format)
 	if err != nil {
 		return err
 	}
+	w.Header().Set("Content-Type", util.ContentType(format))
 	w.WriteHeader(http.StatusNotImplemented)
 	w.Write(b)
 	return nil
 }
+
+// errorFormat returns the format for an error response.
+// Errors are returned as json if the format of the response cannot hold an error object, e.g., an image.
+func errorFormat(format string) string {
+	switch format {
+	case "", "html", "png", "jpg", "jpeg":
+		return DefaultFormat
+	}
+	return format
+}
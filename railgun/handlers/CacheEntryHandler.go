@@ -0,0 +1,73 @@
+// =================================================================
+//
+// Copyright (C) 2018 Spatial Current, Inc. - All Rights Reserved
+// Released as open source under the MIT License.  See LICENSE file.
+//
+// =================================================================
+
+package handlers
+
+import (
+	"github.com/gorilla/mux"
+	rerrors "github.com/spatialcurrent/railgun/railgun/errors"
+	"net/http"
+)
+
+// CacheEntryHandler deletes an entry from the cache shared by layers.
+type CacheEntryHandler struct {
+	*BaseHandler
+}
+
+func (h *CacheEntryHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	format, ok := h.NegotiateFormat(w, r)
+	if !ok {
+		return
+	}
+
+	switch r.Method {
+	case "DELETE":
+		obj, err := h.Delete(w, r, format, mux.Vars(r))
+		if err != nil {
+			h.SendError(r, err)
+			err = h.RespondWithError(w, err, format)
+			if err != nil {
+				panic(err)
+			}
+		} else {
+			err = h.RespondWithObject(w, http.StatusOK, obj, format)
+			if err != nil {
+				h.SendError(r, err)
+				err = h.RespondWithError(w, err, format)
+				if err != nil {
+					panic(err)
+				}
+			}
+		}
+	case "OPTIONS":
+	default:
+		err := h.RespondWithNotImplemented(w, format)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+}
+
+func (h *CacheEntryHandler) Delete(w http.ResponseWriter, r *http.Request, format string, vars map[string]string) (interface{}, error) {
+
+	if err := h.AuthorizeAdmin(r); err != nil {
+		return nil, err
+	}
+
+	key, ok := vars["key"]
+	if !ok {
+		return nil, &rerrors.ErrMissingRequiredParameter{Name: "key"}
+	}
+
+	if !h.Catalog.Cache.Delete(key) {
+		return nil, &rerrors.ErrMissingObject{Type: "cache entry", Name: key}
+	}
+
+	return map[string]interface{}{"success": true, "message": "cache entry " + key + " deleted"}, nil
+}
@@ -0,0 +1,76 @@
+// =================================================================
+//
+// Copyright (C) 2018 Spatial Current, Inc. - All Rights Reserved
+// Released as open source under the MIT License.  See LICENSE file.
+//
+// =================================================================
+
+package handlers
+
+import (
+	"net/http"
+)
+
+// CacheHandler lists the entries in the cache shared by layers.
+// The layer parameter in the query string filters the entries to those read by the layer.
+type CacheHandler struct {
+	*BaseHandler
+}
+
+func (h *CacheHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	format, ok := h.NegotiateFormat(w, r)
+	if !ok {
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		obj, err := h.Get(w, r, format)
+		if err != nil {
+			h.SendError(r, err)
+			err = h.RespondWithError(w, err, format)
+			if err != nil {
+				panic(err)
+			}
+		} else {
+			err = h.RespondWithObject(w, http.StatusOK, obj, format)
+			if err != nil {
+				h.SendError(r, err)
+				err = h.RespondWithError(w, err, format)
+				if err != nil {
+					panic(err)
+				}
+			}
+		}
+	default:
+		err := h.RespondWithNotImplemented(w, format)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+}
+
+func (h *CacheHandler) Get(w http.ResponseWriter, r *http.Request, format string) (interface{}, error) {
+
+	if err := h.AuthorizeAdmin(r); err != nil {
+		return nil, err
+	}
+
+	entries := make([]map[string]interface{}, 0)
+	for _, e := range h.Catalog.Cache.Entries(r.URL.Query().Get("layer")) {
+		entries = append(entries, e.Map())
+	}
+
+	obj := map[string]interface{}{
+		"entries":  entries,
+		"stats":    h.Catalog.Cache.Stats().Map(),
+		"maxBytes": h.Catalog.Cache.MaxBytes(),
+	}
+	if h.Catalog.Cache.Disk != nil {
+		obj["disk"] = h.Catalog.Cache.Disk.Stats().Map()
+	}
+
+	return obj, nil
+}
@@ -13,7 +13,6 @@ import (
 	"github.com/pkg/errors"
 	"github.com/spatialcurrent/railgun/railgun/core"
 	rerrors "github.com/spatialcurrent/railgun/railgun/errors"
-	"github.com/spatialcurrent/railgun/railgun/util"
 	"io/ioutil"
 	"net/http"
 	"reflect"
@@ -27,7 +26,10 @@ type GroupHandler struct {
 
 func (h *GroupHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
-	_, format, _ := util.SplitNameFormatCompression(r.URL.Path)
+	format, ok := h.NegotiateFormat(w, r)
+	if !ok {
+		return
+	}
 
 	switch r.Method {
 	case "GET":
@@ -35,7 +37,7 @@ func (h *GroupHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		obj, err := h.Get(w, r, format)
 		h.Catalog.Unlock()
 		if err != nil {
-			h.Messages <- err
+			h.SendError(r, err)
 			err = h.RespondWithError(w, err, format)
 			if err != nil {
 				panic(err)
@@ -43,7 +45,7 @@ func (h *GroupHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		} else {
 			err = h.RespondWithObject(w, http.StatusOK, obj, format)
 			if err != nil {
-				h.Messages <- err
+				h.SendError(r, err)
 				err = h.RespondWithError(w, err, format)
 				if err != nil {
 					panic(err)
@@ -55,7 +57,7 @@ func (h *GroupHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		obj, err := h.Post(w, r, format)
 		h.Catalog.Unlock()
 		if err != nil {
-			h.Messages <- err
+			h.SendError(r, err)
 			err = h.RespondWithError(w, err, format)
 			if err != nil {
 				panic(err)
@@ -63,7 +65,7 @@ func (h *GroupHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		} else {
 			err = h.RespondWithObject(w, http.StatusOK, obj, format)
 			if err != nil {
-				h.Messages <- err
+				h.SendError(r, err)
 				err = h.RespondWithError(w, err, format)
 				if err != nil {
 					panic(err)
@@ -116,7 +118,7 @@ func (h *GroupHandler) Post(w http.ResponseWriter, r *http.Request, format strin
 		return nil, errors.Wrap(err, "error reading from request body")
 	}
 
-	obj, err := h.ParseBody(body, format)
+	obj, err := h.ParseBody(r, body, format)
 	if err != nil {
 		return nil, err
 	}
@@ -8,7 +8,6 @@
 package handlers
 
 import (
-	"github.com/spatialcurrent/railgun/railgun/util"
 	"net/http"
 )
 
@@ -18,7 +17,10 @@ type HealthHandler struct {
 
 func (h *HealthHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
-	_, format, _ := util.SplitNameFormatCompression(r.URL.Path)
+	format, ok := h.NegotiateFormat(w, r)
+	if !ok {
+		return
+	}
 
 	switch r.Method {
 	case "GET":
@@ -27,7 +29,7 @@ func (h *HealthHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		}
 		err := h.RespondWithObject(w, http.StatusOK, obj, format)
 		if err != nil {
-			h.Messages <- err
+			h.SendError(r, err)
 			err = h.RespondWithError(w, err, format)
 			if err != nil {
 				panic(err)
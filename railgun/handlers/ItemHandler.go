@@ -20,7 +20,6 @@ import (
 	"github.com/gorilla/mux"
 	"github.com/pkg/errors"
 	rerrors "github.com/spatialcurrent/railgun/railgun/errors"
-	"github.com/spatialcurrent/railgun/railgun/util"
 )
 
 type ItemHandler struct {
@@ -32,7 +31,10 @@ type ItemHandler struct {
 
 func (h *ItemHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
-	_, format, _ := util.SplitNameFormatCompression(r.URL.Path)
+	format, ok := h.NegotiateFormat(w, r)
+	if !ok {
+		return
+	}
 
 	switch r.Method {
 	case "GET":
@@ -40,7 +42,7 @@ func (h *ItemHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		obj, err := h.Get(w, r, format)
 		h.Catalog.Unlock()
 		if err != nil {
-			h.Messages <- err
+			h.SendError(r, err)
 			err = h.RespondWithError(w, err, format)
 			if err != nil {
 				panic(err)
@@ -48,7 +50,7 @@ func (h *ItemHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		} else {
 			err = h.RespondWithObject(w, http.StatusOK, obj, format)
 			if err != nil {
-				h.Messages <- err
+				h.SendError(r, err)
 				err = h.RespondWithError(w, err, format)
 				if err != nil {
 					panic(err)
@@ -60,7 +62,7 @@ func (h *ItemHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		obj, err := h.Post(w, r, format)
 		h.Catalog.Unlock()
 		if err != nil {
-			h.Messages <- err
+			h.SendError(r, err)
 			err = h.RespondWithError(w, err, format)
 			if err != nil {
 				panic(err)
@@ -68,7 +70,7 @@ func (h *ItemHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		} else {
 			err = h.RespondWithObject(w, http.StatusOK, obj, format)
 			if err != nil {
-				h.Messages <- err
+				h.SendError(r, err)
 				err = h.RespondWithError(w, err, format)
 				if err != nil {
 					panic(err)
@@ -80,7 +82,7 @@ func (h *ItemHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		obj, err := h.Delete(w, r, format)
 		h.Catalog.Unlock()
 		if err != nil {
-			h.Messages <- err
+			h.SendError(r, err)
 			err = h.RespondWithError(w, err, format)
 			if err != nil {
 				panic(err)
@@ -88,7 +90,7 @@ func (h *ItemHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		} else {
 			err = h.RespondWithObject(w, http.StatusOK, obj, format)
 			if err != nil {
-				h.Messages <- err
+				h.SendError(r, err)
 				err = h.RespondWithError(w, err, format)
 				if err != nil {
 					panic(err)
@@ -154,7 +156,7 @@ func (h *ItemHandler) Post(w http.ResponseWriter, r *http.Request, format string
 		return nil, errors.Wrap(err, "error reading from request body")
 	}
 
-	obj, err := h.ParseBody(body, format)
+	obj, err := h.ParseBody(r, body, format)
 	if err != nil {
 		return nil, err
 	}
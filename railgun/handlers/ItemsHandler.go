@@ -34,7 +34,7 @@ func (h *ItemsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	qs := request.NewQueryString(r)
 	err := h.Run(w, r, vars, qs)
 	if err != nil {
-		h.Errors <- err
+		h.SendError(r, err)
 		respondWithEmptyFeatureCollection(w)
 		//w.WriteHeader(http.StatusInternalServerError)
 	}
@@ -93,8 +93,10 @@ func (h *ItemsHandler) Run(w http.ResponseWriter, r *http.Request, vars map[stri
 
 	// Input Flags
 	inputReaderBufferSize := h.Viper.GetInt("input-reader-buffer-size")
-	inputPassphrase := h.Viper.GetString("input-passphrase")
-	inputSalt := h.Viper.GetString("input-salt")
+	inputPassphrase, inputSalt, err := h.Keyring.Lookup(layer.DataStore.Key)
+	if err != nil {
+		return errors.Wrap(err, "error resolving key for data store "+layer.DataStore.Name)
+	}
 
 	_, inputUriString, err := dfl.EvaluateString(layer.DataStore.Uri, map[string]interface{}{}, map[string]interface{}{}, h.DflFuncs, dfl.DefaultQuotes)
 	if err != nil {
@@ -151,7 +153,7 @@ func (h *ItemsHandler) Run(w http.ResponseWriter, r *http.Request, vars map[stri
 		}
 	}
 
-	inputBytes, err := util.DecryptReader(inputReader, inputPassphrase, inputSalt)
+	inputBytes, err := util.DecryptReader(inputReader, inputPassphrase, inputSalt, len(layer.DataStore.Key) > 0, h.Keyring)
 	if err != nil {
 		return errors.Wrap(err, "error decoding input")
 	}
@@ -8,16 +8,11 @@
 package handlers
 
 import (
-	//"fmt"
 	"github.com/gorilla/mux"
-	"github.com/pkg/errors"
-	"github.com/spatialcurrent/go-dfl/dfl"
-	"github.com/spatialcurrent/go-reader-writer/grw"
-	"github.com/spatialcurrent/go-simple-serializer/gss"
 	rerrors "github.com/spatialcurrent/railgun/railgun/errors"
-	"github.com/spatialcurrent/railgun/railgun/util"
+	"github.com/spatialcurrent/railgun/railgun/runner"
+	"github.com/spatialcurrent/railgun/railgun/runs"
 	"net/http"
-	//"reflect"
 )
 
 type JobExecHandler struct {
@@ -26,25 +21,38 @@ type JobExecHandler struct {
 
 func (h *JobExecHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
-	_, format, _ := util.SplitNameFormatCompression(r.URL.Path)
+	format, ok := h.NegotiateFormat(w, r)
+	if !ok {
+		return
+	}
 
 	switch r.Method {
 	case "POST":
-		obj, err := h.Post(w, r, format, mux.Vars(r))
+		vars := mux.Vars(r)
+		run := h.StartRun(r, "job", vars["name"])
+		rc := &ResponseCounter{ResponseWriter: w}
+		obj, err := h.Post(rc, r, format, vars, run)
 		if err != nil {
-			h.Messages <- err
-			err = h.RespondWithError(w, err, format)
+			h.SendError(r, err)
+			h.FinishRun(run, err)
+			err = h.RespondWithError(rc, err, format)
 			if err != nil {
 				panic(err)
 			}
 		} else {
-			err = h.RespondWithObject(w, http.StatusOK, obj, format)
+			err = h.RespondWithObject(rc, http.StatusOK, obj, format)
 			if err != nil {
-				h.Messages <- err
-				err = h.RespondWithError(w, err, format)
+				h.SendError(r, err)
+				h.FinishRun(run, err)
+				err = h.RespondWithError(rc, err, format)
 				if err != nil {
 					panic(err)
 				}
+			} else {
+				if len(run.Outputs) == 0 {
+					run.OutputSize = rc.Bytes
+				}
+				h.FinishRun(run, nil)
 			}
 		}
 	case "OPTIONS":
@@ -57,7 +65,7 @@ func (h *JobExecHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 }
 
-func (h *JobExecHandler) Post(w http.ResponseWriter, r *http.Request, format string, vars map[string]string) (interface{}, error) {
+func (h *JobExecHandler) Post(w http.ResponseWriter, r *http.Request, format string, vars map[string]string, run *runs.Run) (interface{}, error) {
 
 	jobName, ok := vars["name"]
 	if !ok {
@@ -69,45 +77,21 @@ func (h *JobExecHandler) Post(w http.ResponseWriter, r *http.Request, format str
 		return nil, &rerrors.ErrMissingObject{Type: "job", Name: jobName}
 	}
 
-	variables := map[string]interface{}{}
-	for k, v := range job.Service.Defaults {
-		variables[k] = v
-	}
-	for k, v := range job.Variables {
-		variables[k] = v
-	}
-
-	_, inputUri, err := dfl.EvaluateString(job.Service.DataStore.Uri, variables, map[string]interface{}{}, dfl.DefaultFunctionMap, dfl.DefaultQuotes)
+	result, err := h.NewRunner().RunJob(r.Context(), job, map[string]interface{}{}, map[string]interface{}{})
+	runner.RecordJob(run, result)
 	if err != nil {
-		return nil, errors.Wrap(err, "invalid data store uri")
+		return nil, err
 	}
 
-	inputReader, _, err := grw.ReadFromResource(inputUri, job.Service.DataStore.Compression, 4096, false, nil)
-	if err != nil {
-		return nil, errors.Wrap(err, "error opening resource at uri "+inputUri)
-	}
-
-	inputBytes, err := inputReader.ReadAllAndClose()
-	if err != nil {
-		return nil, errors.Wrap(err, "error reading from resource at uri "+inputUri)
+	if len(result.OutputUri) > 0 {
+		return map[string]interface{}{
+			"success":  true,
+			"message":  "job with name " + jobName + " completed.",
+			"uri":      result.OutputUri,
+			"attempts": result.AttemptsMap(),
+		}, nil
 	}
 
-	inputFormat := job.Service.DataStore.Format
-
-	inputType, err := gss.GetType(inputBytes, inputFormat)
-	if err != nil {
-		return nil, errors.Wrap(err, "error getting type for input")
-	}
-
-	inputObject, err := gss.DeserializeBytes(inputBytes, inputFormat, []string{}, "", false, gss.NoSkip, gss.NoLimit, inputType, false)
-	if err != nil {
-		return nil, errors.Wrap(err, "error deserializing input using format "+inputFormat)
-	}
-
-	_, outputObject, err := job.Service.Process.Node.Evaluate(variables, inputObject, dfl.DefaultFunctionMap, dfl.DefaultQuotes)
-	if err != nil {
-		return nil, errors.Wrap(err, "error evaluating process with name "+job.Service.Process.Name)
-	}
-	return outputObject, nil
+	return result.Output, nil
 
 }
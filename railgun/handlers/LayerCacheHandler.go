@@ -0,0 +1,152 @@
+// =================================================================
+//
+// Copyright (C) 2018 Spatial Current, Inc. - All Rights Reserved
+// Released as open source under the MIT License.  See LICENSE file.
+//
+// =================================================================
+
+package handlers
+
+import (
+	"fmt"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+	"github.com/spatialcurrent/go-dfl/dfl"
+	"github.com/spatialcurrent/go-try-get/gtg"
+	"github.com/spatialcurrent/railgun/railgun/cache"
+	rerrors "github.com/spatialcurrent/railgun/railgun/errors"
+	"github.com/spatialcurrent/railgun/railgun/parser"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// LayerCacheHandler purges the cached data of a layer or, if Warm is true, loads the data of the layer into the cache.
+// Data stores with uris that depend on the tile are warmed with the variables in the body of the request, as a DFL dictionary, e.g., {"variables": "{z: 4, x: 2, y: 5}"}.
+type LayerCacheHandler struct {
+	*BaseHandler
+	Warm bool
+}
+
+func (h *LayerCacheHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	format, ok := h.NegotiateFormat(w, r)
+	if !ok {
+		return
+	}
+
+	switch {
+	case r.Method == "DELETE" && !h.Warm, r.Method == "POST" && h.Warm:
+		obj, err := h.Handle(w, r, format, mux.Vars(r))
+		if err != nil {
+			h.SendError(r, err)
+			err = h.RespondWithError(w, err, format)
+			if err != nil {
+				panic(err)
+			}
+		} else {
+			err = h.RespondWithObject(w, http.StatusOK, obj, format)
+			if err != nil {
+				h.SendError(r, err)
+				err = h.RespondWithError(w, err, format)
+				if err != nil {
+					panic(err)
+				}
+			}
+		}
+	case r.Method == "OPTIONS":
+	default:
+		err := h.RespondWithNotImplemented(w, format)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+}
+
+func (h *LayerCacheHandler) Handle(w http.ResponseWriter, r *http.Request, format string, vars map[string]string) (interface{}, error) {
+
+	if err := h.AuthorizeAdmin(r); err != nil {
+		return nil, err
+	}
+
+	layerName, ok := vars["name"]
+	if !ok {
+		return nil, &rerrors.ErrMissingRequiredParameter{Name: "name"}
+	}
+
+	layer, ok := h.Catalog.GetLayer(layerName)
+	if !ok {
+		return nil, &rerrors.ErrMissingObject{Type: "layer", Name: layerName}
+	}
+
+	if !h.Warm {
+		deleted := h.Catalog.Cache.DeleteGroup(layer.Name)
+		return map[string]interface{}{
+			"success": true,
+			"message": fmt.Sprintf("deleted %d cache entries for layer %s", deleted, layer.Name),
+			"deleted": deleted,
+		}, nil
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading from request body")
+	}
+
+	variables := map[string]interface{}{}
+	if len(body) > 0 {
+		obj, err := h.ParseBody(r, body, format)
+		if err != nil {
+			return nil, errors.Wrap(err, "error parsing body")
+		}
+		variables, err = parser.ParseMap(obj, "variables")
+		if err != nil {
+			return nil, &rerrors.ErrInvalidParameter{Name: "variables", Value: gtg.TryGetString(obj, "variables", "")}
+		}
+	}
+
+	_, uri, err := dfl.EvaluateString(layer.DataStore.Uri, map[string]interface{}{}, variables, dfl.DefaultFunctionMap, dfl.DefaultQuotes)
+	if err != nil {
+		return nil, &rerrors.ErrInvalidParameter{Name: "variables", Value: fmt.Sprint(variables)}
+	}
+
+	passphrase, salt, err := h.Keyring.Lookup(layer.DataStore.Key)
+	if err != nil {
+		return nil, errors.Wrap(err, "error resolving key for data store "+layer.DataStore.Name)
+	}
+
+	var s3_client *s3.S3
+	if strings.HasPrefix(uri, "s3://") {
+		client, err := h.GetAWSS3Client()
+		if err != nil {
+			return nil, errors.Wrap(err, "error connecting to AWS")
+		}
+		s3_client = client
+	}
+
+	hit, _, err := layer.Cache.Get(
+		r.Context(),
+		uri,
+		layer.DataStore.Format,
+		layer.DataStore.Compression,
+		h.Viper.GetInt("input-reader-buffer-size"),
+		layer.DataStore.Key,
+		passphrase,
+		salt,
+		h.Keyring,
+		s3_client,
+		h.Viper.GetBool("verbose"))
+	if err != nil {
+		return nil, errors.Wrap(err, "error warming cache for layer "+layer.Name)
+	}
+
+	return map[string]interface{}{
+		"success": true,
+		"message": "warmed cache for layer " + layer.Name,
+		"key":     cache.Key(cache.ItemKey(uri, layer.DataStore.Format, layer.DataStore.Compression, layer.DataStore.Key)),
+		"uri":     uri,
+		"hit":     hit,
+	}, nil
+}
@@ -19,6 +19,8 @@ import (
 	"github.com/spatialcurrent/railgun/railgun/img"
 	"github.com/spatialcurrent/railgun/railgun/named"
 	"github.com/spatialcurrent/railgun/railgun/request"
+	"github.com/spatialcurrent/railgun/railgun/tracing"
+	"github.com/spatialcurrent/railgun/railgun/util"
 	"image/color"
 	"math"
 	"net/http"
@@ -32,10 +34,22 @@ type LayerMaskHandler struct {
 
 func (h *LayerMaskHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
+	if _, ok := vars["ext"]; !ok {
+		// masks are images, so the format is png unless jpg is preferred.
+		format, err := util.NegotiateFormat(r.Header.Get("Accept"), "png")
+		if err != nil || (format != "png" && format != "jpg") {
+			err = h.RespondWithError(w, &rerrors.ErrNotAcceptable{Accept: r.Header.Get("Accept")}, DefaultFormat)
+			if err != nil {
+				panic(err)
+			}
+			return
+		}
+		vars["ext"] = format
+	}
 	qs := request.NewQueryString(r)
 	err := h.Run(w, r, vars, qs)
 	if err != nil {
-		h.Errors <- err
+		h.SendError(r, err)
 		w.WriteHeader(http.StatusInternalServerError)
 		img.RespondWithImage(vars["ext"], w, img.CreateImage(color.RGBA{255, 0, 0, 220}))
 	}
@@ -46,13 +60,17 @@ func (h *LayerMaskHandler) Run(w http.ResponseWriter, r *http.Request, vars map[
 	ext := vars["ext"]
 
 	tileRequest := &request.TileRequest{Layer: vars["name"], Header: r.Header}
-	cacheRequest := &request.CacheRequest{}
+	cacheRequest := &request.CacheRequest{Layer: vars["name"]}
 	// Defer putting tile request into requests channel, so it can pick up more metadata during execution
 	defer func() {
 		h.Requests <- tileRequest
 		if len(cacheRequest.Key) > 0 {
 			h.Requests <- cacheRequest
 		}
+		ar := request.GetAccessRequest(r)
+		ar.AddTileRequest(tileRequest)
+		ar.AddCacheRequest(cacheRequest)
+		setTileAttributes(r.Context(), tileRequest, cacheRequest)
 	}()
 
 	layer, ok := h.Catalog.GetLayer(vars["name"])
@@ -145,8 +163,10 @@ func (h *LayerMaskHandler) Run(w http.ResponseWriter, r *http.Request, vars map[
 
 	// Input Flags
 	inputReaderBufferSize := h.Viper.GetInt("input-reader-buffer-size")
-	inputPassphrase := h.Viper.GetString("input-passphrase")
-	inputSalt := h.Viper.GetString("input-salt")
+	inputPassphrase, inputSalt, err := h.Keyring.Lookup(layer.DataStore.Key)
+	if err != nil {
+		return errors.Wrap(err, "error resolving key for data store "+layer.DataStore.Name)
+	}
 
 	verbose := h.Viper.GetBool("verbose")
 
@@ -160,12 +180,15 @@ func (h *LayerMaskHandler) Run(w http.ResponseWriter, r *http.Request, vars map[
 	}
 
 	hit, inputObject, err := layer.Cache.Get(
+		r.Context(),
 		inputUriString,
 		layer.DataStore.Format,
 		layer.DataStore.Compression,
 		inputReaderBufferSize,
+		layer.DataStore.Key,
 		inputPassphrase,
 		inputSalt,
+		h.Keyring,
 		s3_client,
 		verbose)
 	if err != nil {
@@ -178,6 +201,7 @@ func (h *LayerMaskHandler) Run(w http.ResponseWriter, r *http.Request, vars map[
 	//maskBoundingBox := geo.TileToBoundingBox(maskZoom, tile.X*pow_diff, tile.Y*pow_diff)
 	//fmt.Println("Mask BBOX:", maskBoundingBox)
 
+	_, span := tracing.Start(r.Context(), "pipeline.Evaluate", tracing.AttributeLayer.String(layer.Name))
 	_, outputObject, err := dfl.EvaluateMap(
 		dfl.Pipeline{Nodes: pipeline},
 		map[string]interface{}{
@@ -187,8 +211,11 @@ func (h *LayerMaskHandler) Run(w http.ResponseWriter, r *http.Request, vars map[
 		dfl.DefaultFunctionMap,
 		dfl.DefaultQuotes)
 	if err != nil {
-		return errors.Wrap(err, "error processing features")
+		err = errors.Wrap(err, "error processing features")
+		tracing.End(span, err)
+		return err
 	}
+	tracing.End(span, nil)
 
 	groups, ok := outputObject.(map[string]map[string][]interface{})
 	if !ok {
@@ -8,6 +8,7 @@
 package handlers
 
 import (
+	"context"
 	"fmt"
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/gorilla/mux"
@@ -22,7 +23,8 @@ import (
 	//"github.com/spatialcurrent/railgun/railgun/named"
 	"github.com/spatialcurrent/railgun/railgun/pipeline"
 	"github.com/spatialcurrent/railgun/railgun/request"
-	"github.com/spatialcurrent/railgun/railgun/util"
+	"github.com/spatialcurrent/railgun/railgun/tracing"
+	"go.opentelemetry.io/otel/attribute"
 	//"image/color"
 	"net/http"
 	"strings"
@@ -50,7 +52,10 @@ type LayerTileHandler struct {
 
 func (h *LayerTileHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
-	_, format, _ := util.SplitNameFormatCompression(r.URL.Path)
+	format, ok := h.NegotiateFormat(w, r)
+	if !ok {
+		return
+	}
 
 	switch r.Method {
 	case "GET":
@@ -58,7 +63,7 @@ func (h *LayerTileHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		obj, err := h.Get(w, r, format)
 		h.Catalog.Unlock()
 		if err != nil {
-			h.Messages <- err
+			h.SendError(r, err)
 			err = h.RespondWithError(w, err, format)
 			if err != nil {
 				panic(err)
@@ -66,7 +71,7 @@ func (h *LayerTileHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		} else {
 			err = h.RespondWithObject(w, http.StatusOK, obj, format)
 			if err != nil {
-				h.Messages <- err
+				h.SendError(r, err)
 				err = h.RespondWithError(w, err, format)
 				if err != nil {
 					panic(err)
@@ -93,13 +98,17 @@ func (h *LayerTileHandler) Get(w http.ResponseWriter, r *http.Request, format st
 	}
 
 	tileRequest := &request.TileRequest{Layer: layerName, Header: r.Header}
-	cacheRequest := &request.CacheRequest{}
+	cacheRequest := &request.CacheRequest{Layer: layerName}
 	// Defer putting tile request into requests channel, so it can pick up more metadata during execution
 	defer func() {
 		h.Requests <- tileRequest
 		if len(cacheRequest.Key) > 0 {
 			h.Requests <- cacheRequest
 		}
+		ar := request.GetAccessRequest(r)
+		ar.AddTileRequest(tileRequest)
+		ar.AddCacheRequest(cacheRequest)
+		setTileAttributes(r.Context(), tileRequest, cacheRequest)
 	}()
 
 	layer, ok := h.Catalog.GetLayer(layerName)
@@ -208,8 +217,10 @@ func (h *LayerTileHandler) Get(w http.ResponseWriter, r *http.Request, format st
 
 	// Input Flags
 	inputReaderBufferSize := h.Viper.GetInt("input-reader-buffer-size")
-	inputPassphrase := h.Viper.GetString("input-passphrase")
-	inputSalt := h.Viper.GetString("input-salt")
+	inputPassphrase, inputSalt, err := h.Keyring.Lookup(layer.DataStore.Key)
+	if err != nil {
+		return nil, errors.Wrap(err, "error resolving key for data store "+layer.DataStore.Name)
+	}
 
 	verbose := h.Viper.GetBool("verbose")
 
@@ -223,12 +234,15 @@ func (h *LayerTileHandler) Get(w http.ResponseWriter, r *http.Request, format st
 	}
 
 	hit, inputObject, err := layer.Cache.Get(
+		r.Context(),
 		inputUriString,
 		layer.DataStore.Format,
 		layer.DataStore.Compression,
 		inputReaderBufferSize,
+		layer.DataStore.Key,
 		inputPassphrase,
 		inputSalt,
+		h.Keyring,
 		s3_client,
 		verbose)
 	if err != nil {
@@ -250,15 +264,36 @@ func (h *LayerTileHandler) Get(w http.ResponseWriter, r *http.Request, format st
 	variables["bbox"] = bufferedBoundingBox
 	variables["limit"] = limit
 
+	_, span := tracing.Start(r.Context(), "pipeline.Evaluate", tracing.AttributeLayer.String(layerName))
 	outputObject, err := p.Evaluate(
 		variables,
 		inputObject)
 	if err != nil {
-		return nil, errors.Wrap(err, "error processing features")
+		err = errors.Wrap(err, "error processing features")
+		tracing.End(span, err)
+		return nil, err
 	}
 
 	tileRequest.Features = gtg.TryGetInt(outputObject, "numberOfFeatures", 0)
+	span.SetAttributes(tracing.AttributeFeatures.Int(tileRequest.Features))
+	tracing.End(span, nil)
 
 	return gss.StringifyMapKeys(outputObject), nil
 
 }
+
+// setTileAttributes sets the layer, tile, data store, features, and cache hit of the tile request on the span of the request.
+func setTileAttributes(ctx context.Context, tr *request.TileRequest, cr *request.CacheRequest) {
+	attributes := []attribute.KeyValue{
+		tracing.AttributeLayer.String(tr.Layer),
+		tracing.AttributeTile.String(tr.Tile.String()),
+		tracing.AttributeFeatures.Int(tr.Features),
+	}
+	if len(tr.Source) > 0 {
+		attributes = append(attributes, tracing.AttributeDataStoreUri.String(tr.Source))
+	}
+	if len(cr.Key) > 0 {
+		attributes = append(attributes, tracing.AttributeCacheHit.Bool(cr.Hit))
+	}
+	tracing.SetAttributes(ctx, attributes...)
+}
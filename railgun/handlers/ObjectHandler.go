@@ -8,7 +8,6 @@
 package handlers
 
 import (
-	"github.com/spatialcurrent/railgun/railgun/util"
 	"net/http"
 )
 
@@ -19,13 +18,16 @@ type ObjectHandler struct {
 
 func (h *ObjectHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
-	_, format, _ := util.SplitNameFormatCompression(r.URL.Path)
+	format, ok := h.NegotiateFormat(w, r)
+	if !ok {
+		return
+	}
 
 	switch r.Method {
 	case "GET":
 		err := h.RespondWithObject(w, http.StatusOK, h.Object, format)
 		if err != nil {
-			h.Messages <- err
+			h.SendError(r, err)
 			err = h.RespondWithError(w, err, format)
 			if err != nil {
 				panic(err)
@@ -0,0 +1,883 @@
+// =================================================================
+//
+// Copyright (C) 2018 Spatial Current, Inc. - All Rights Reserved
+// Released as open source under the MIT License.  See LICENSE file.
+//
+// =================================================================
+
+package handlers
+
+import (
+	"encoding/json"
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+	"github.com/spatialcurrent/railgun/railgun/core"
+	rerrors "github.com/spatialcurrent/railgun/railgun/errors"
+	"github.com/spatialcurrent/railgun/railgun/openapi"
+	"github.com/spatialcurrent/railgun/railgun/util"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ObjectFormats are the formats that objects can be serialized to with RespondWithObject.
+var ObjectFormats = []string{"json", "yaml", "toml", "bson", "html"}
+
+// openApiGroups maps the name of each group route to the name of the core type.
+var openApiGroups = map[string]string{
+	"workspaces": "workspace",
+	"datastores": "datastore",
+	"layers":     "layer",
+	"processes":  "process",
+	"services":   "service",
+	"jobs":       "job",
+	"workflows":  "workflow",
+}
+
+var openApiPathParameter = regexp.MustCompile("{([a-zA-Z0-9_]+)}")
+
+// jwtSecurity is the security of routes that require an authenticated user, such as the runs and admin routes.
+var jwtSecurity = []map[string][]string{
+	map[string][]string{"jwt": []string{}},
+}
+
+// OpenApiHandler serves an OpenAPI 3.1 document describing every route of the router.
+type OpenApiHandler struct {
+	*BaseHandler
+	Router *mux.Router
+}
+
+func (h *OpenApiHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	format, ok := h.NegotiateFormat(w, r)
+	if !ok {
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		obj, err := h.Get(w, r, format)
+		if err != nil {
+			h.SendError(r, err)
+			err = h.RespondWithError(w, err, format)
+			if err != nil {
+				panic(err)
+			}
+		} else {
+			err = h.RespondWithObject(w, http.StatusOK, obj, format)
+			if err != nil {
+				h.SendError(r, err)
+				err = h.RespondWithError(w, err, format)
+				if err != nil {
+					panic(err)
+				}
+			}
+		}
+	default:
+		err := h.RespondWithNotImplemented(w, format)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+}
+
+func (h *OpenApiHandler) Get(w http.ResponseWriter, r *http.Request, format string) (interface{}, error) {
+	if format != "json" && format != "yaml" {
+		return nil, &rerrors.ErrInvalidParameter{Name: "ext", Value: format}
+	}
+	doc, err := h.BuildDocument()
+	if err != nil {
+		return nil, err
+	}
+	// round trip through json, so the field names and omitted fields are the same for every format.
+	b, err := json.Marshal(doc)
+	if err != nil {
+		return nil, errors.Wrap(err, "error marshaling OpenAPI document")
+	}
+	obj := map[string]interface{}{}
+	err = json.Unmarshal(b, &obj)
+	if err != nil {
+		return nil, errors.Wrap(err, "error unmarshaling OpenAPI document")
+	}
+	return obj, nil
+}
+
+// BuildDocument returns the OpenAPI document for the routes of the router and the services in the catalog.
+func (h *OpenApiHandler) BuildDocument() (*openapi.Document, error) {
+
+	paths := map[string]*openapi.PathItem{}
+	err := h.Router.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		path, err := route.GetPathTemplate()
+		if err != nil {
+			return nil
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			methods = []string{"GET"}
+		}
+		// routes without an extension negotiate the format of the response with the Accept header.
+		name := route.GetName()
+		negotiated := strings.HasSuffix(name, "_negotiated")
+		// routes with the same path and different methods are merged into one path item.
+		item, ok := paths[path]
+		if !ok {
+			item = &openapi.PathItem{}
+		}
+		for _, method := range methods {
+			// the first route registered for a method is the one matched by the router.
+			if item.GetOperation(method) != nil {
+				continue
+			}
+			if op := h.BuildOperation(strings.TrimSuffix(name, "_negotiated"), strings.ToUpper(method), path); op != nil {
+				if negotiated {
+					op.OperationId += "_negotiated"
+					op.Parameters = append(op.Parameters, h.acceptParameter())
+				}
+				item.SetOperation(method, op)
+			}
+		}
+		paths[path] = item
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "error walking routes")
+	}
+
+	for _, service := range h.Catalog.ListServices() {
+		if len(service.Process.Parameters) == 0 {
+			continue
+		}
+		path := "/services/" + service.Name + "/exec.{ext}"
+		op := h.BuildOperation("service_exec", "POST", path)
+		op.OperationId = "post_service_exec_" + service.Name
+		op.Summary = "Execute the " + service.Name + " service"
+		op.Description = service.Description
+		op.RequestBody = h.requestBody("the variables and session for the service", false, openapi.Ref(h.getExecRequestSchemaName(service)))
+		if item, ok := paths[path]; ok {
+			item.Post = op
+		} else {
+			paths[path] = &openapi.PathItem{Post: op}
+		}
+	}
+
+	var contact *openapi.Contact
+	if name, email, url := h.Viper.GetString("swagger-contact-name"), h.Viper.GetString("swagger-contact-email"), h.Viper.GetString("swagger-contact-url"); len(name) > 0 || len(email) > 0 || len(url) > 0 {
+		contact = &openapi.Contact{Name: name, Email: email, Url: url}
+	}
+
+	doc := &openapi.Document{
+		OpenApi: openapi.Version,
+		Info: &openapi.Info{
+			Version:        "1.0.0",
+			Title:          "Railgun",
+			Description:    "A simple and fast data processing tool",
+			TermsOfService: "https://spatialcurrent.io/terms-of-service/",
+			Contact:        contact,
+			License: &openapi.License{
+				Name: "MIT",
+				Url:  "https://github.com/spatialcurrent/railgun/blob/master/LICENSE",
+			},
+		},
+		Servers: []*openapi.Server{&openapi.Server{Url: h.Viper.GetString("http-location")}},
+		Paths:   paths,
+		Components: &openapi.Components{
+			Schemas:   h.BuildSchemas(),
+			Responses: h.buildErrorResponses(),
+			SecuritySchemes: map[string]*openapi.SecurityScheme{
+				"jwt": &openapi.SecurityScheme{
+					Type:         "http",
+					Scheme:       "bearer",
+					BearerFormat: "JWT",
+					Description:  "A token returned by /authenticate.{ext}",
+				},
+			},
+		},
+		// requests are anonymous unless authenticated
+		Security: []map[string][]string{
+			map[string][]string{},
+			map[string][]string{"jwt": []string{}},
+		},
+	}
+
+	return doc, nil
+}
+
+// BuildOperation returns the operation for the route with the given name and method, or nil if the route does not support the method.
+// Routes that are not known are given a generic operation, so every route is in the document.
+func (h *OpenApiHandler) BuildOperation(name string, method string, path string) *openapi.Operation {
+
+	if method == "OPTIONS" {
+		return nil
+	}
+
+	op := &openapi.Operation{
+		OperationId: strings.ToLower(method) + "_" + name,
+		Parameters:  h.pathParameters(path, ObjectFormats),
+		Responses: map[string]*openapi.Response{
+			"500": &openapi.Response{Ref: "#/components/responses/InternalServerError"},
+		},
+	}
+
+	if t, ok := openApiGroups[name]; ok {
+		op.Tags = []string{strings.Title(name)}
+		switch method {
+		case "GET":
+			op.Summary = "List " + name
+			op.Responses["200"] = h.response("OK", ObjectFormats, &openapi.Schema{
+				Type: "object",
+				Properties: map[string]*openapi.Schema{
+					"items": &openapi.Schema{Type: "array", Items: openapi.Ref(strings.Title(t))},
+				},
+			})
+		case "POST":
+			op.Summary = "Add a " + t
+			op.Security = []map[string][]string{map[string][]string{"jwt": []string{}}}
+			op.RequestBody = h.requestBody("the "+t+" as an object of DFL expressions", true, openapi.Ref(strings.Title(t)))
+			op.Responses["200"] = h.response("OK", ObjectFormats, openapi.Ref("Result"))
+			op.Responses["400"] = &openapi.Response{Ref: "#/components/responses/BadRequest"}
+		default:
+			return nil
+		}
+		return op
+	}
+
+	if t, ok := core.CoreTypes[name]; ok {
+		plural := ""
+		for k, v := range openApiGroups {
+			if v == name {
+				plural = k
+			}
+		}
+		op.Tags = []string{strings.Title(plural)}
+		op.Responses["404"] = &openapi.Response{Ref: "#/components/responses/NotFound"}
+		switch method {
+		case "GET":
+			op.Summary = "Get a " + name
+			op.Responses["200"] = h.response("OK", ObjectFormats, &openapi.Schema{
+				Type: "object",
+				Properties: map[string]*openapi.Schema{
+					"success": &openapi.Schema{Type: "boolean"},
+					"item":    openapi.Ref(strings.Title(name)),
+				},
+			})
+		case "POST":
+			op.Summary = "Update a " + name
+			op.Security = []map[string][]string{map[string][]string{"jwt": []string{}}}
+			op.RequestBody = h.requestBody("the fields of the "+name+" to update", true, &openapi.Schema{Type: "object", Properties: h.coreProperties(t)})
+			op.Responses["200"] = h.response("OK", ObjectFormats, openapi.Ref("Result"))
+			op.Responses["400"] = &openapi.Response{Ref: "#/components/responses/BadRequest"}
+		case "DELETE":
+			op.Summary = "Delete a " + name
+			op.Security = []map[string][]string{map[string][]string{"jwt": []string{}}}
+			op.Responses["200"] = h.response("OK", ObjectFormats, openapi.Ref("Result"))
+			op.Responses["400"] = &openapi.Response{Ref: "#/components/responses/BadRequest"}
+		default:
+			return nil
+		}
+		return op
+	}
+
+	switch name {
+	case "home":
+		op.Summary = "Home page"
+		op.Parameters = nil
+		op.Responses["200"] = h.response("OK", []string{"html"}, &openapi.Schema{Type: "string"})
+	case "swagger":
+		op.Summary = "Swagger 2.0 document"
+		op.Tags = []string{"Documentation"}
+		op.Parameters = h.pathParameters(path, []string{"json", "yaml"})
+		op.Responses["200"] = h.response("OK", []string{"json", "yaml"}, &openapi.Schema{Type: "object"})
+	case "openapi":
+		op.Summary = "OpenAPI 3.1 document"
+		op.Tags = []string{"Documentation"}
+		op.Parameters = h.pathParameters(path, []string{"json", "yaml"})
+		op.Responses["200"] = h.response("OK", []string{"json", "yaml"}, &openapi.Schema{Type: "object"})
+		op.Responses["400"] = &openapi.Response{Ref: "#/components/responses/BadRequest"}
+	case "health", "health_live":
+		op.Summary = "Liveness check"
+		op.Tags = []string{"Health"}
+		op.Responses["200"] = h.response("OK", ObjectFormats, &openapi.Schema{
+			Type:       "object",
+			Properties: map[string]*openapi.Schema{"status": &openapi.Schema{Type: "string"}},
+		})
+	case "health_ready":
+		op.Summary = "Readiness check of the catalog backend, critical data stores, and queues"
+		op.Tags = []string{"Health"}
+		op.Responses["200"] = h.response("Ready", ObjectFormats, openapi.Ref("Readiness"))
+		op.Responses["503"] = h.response("Not ready", ObjectFormats, openapi.Ref("Readiness"))
+	case "metrics":
+		op.Summary = "Metrics in the Prometheus text format"
+		op.Tags = []string{"Health"}
+		op.Parameters = []*openapi.Parameter{}
+		op.Responses["200"] = &openapi.Response{
+			Description: "OK",
+			Content:     map[string]*openapi.MediaType{"text/plain": &openapi.MediaType{Schema: &openapi.Schema{Type: "string"}}},
+		}
+	case "authenticate":
+		op.Summary = "Authenticate and return a JWT"
+		op.Tags = []string{"Security"}
+		op.Security = []map[string][]string{map[string][]string{}}
+		op.RequestBody = h.requestBody("the login credentials", true, openapi.Ref("Credentials"))
+		op.Responses["200"] = h.response("OK", ObjectFormats, openapi.Ref("Token"))
+		op.Responses["400"] = &openapi.Response{Ref: "#/components/responses/BadRequest"}
+		op.Responses["401"] = h.response("Unauthorized", ObjectFormats, openapi.Ref("Token"))
+	case "formats":
+		op.Summary = "List the formats supported by go-simple-serializer (GSS)"
+		op.Tags = []string{"GSS"}
+		op.Responses["200"] = h.response("OK", ObjectFormats, &openapi.Schema{
+			Type: "object",
+			Properties: map[string]*openapi.Schema{
+				"formats": &openapi.Schema{Type: "array", Items: &openapi.Schema{Type: "string"}},
+			},
+		})
+	case "functions":
+		op.Summary = "List the DFL functions"
+		op.Tags = []string{"DFL"}
+		op.Responses["200"] = h.response("OK", ObjectFormats, &openapi.Schema{
+			Type: "object",
+			Properties: map[string]*openapi.Schema{
+				"functions": &openapi.Schema{Type: "array", Items: &openapi.Schema{Type: "object"}},
+			},
+		})
+	case "service_exec":
+		if method != "POST" {
+			return nil
+		}
+		op.Summary = "Execute a service"
+		op.Tags = []string{"Services"}
+		op.Parameters = append(h.pathParameters(path, append(append([]string{}, ObjectFormats...), StreamFormats...)), &openapi.Parameter{
+			Name:        "stream",
+			In:          "query",
+			Description: "Evaluate the process for each record of a csv, tsv, or jsonl data store and stream the results as csv, tsv, or jsonl",
+			Schema:      &openapi.Schema{Type: "boolean", Default: false},
+		}, &openapi.Parameter{
+			Name:        "session",
+			In:          "query",
+			Description: "The id of the session",
+			Schema:      &openapi.Schema{Type: "string"},
+		})
+		op.RequestBody = h.requestBody("the variables and session for the service", false, openapi.Ref("ExecRequest"))
+		op.Responses["200"] = h.response("The output of the process", append(append([]string{}, ObjectFormats...), StreamFormats...), &openapi.Schema{})
+		op.Responses["400"] = &openapi.Response{Ref: "#/components/responses/BadRequest"}
+		op.Responses["404"] = &openapi.Response{Ref: "#/components/responses/NotFound"}
+	case "service_sessions":
+		if method != "POST" {
+			return nil
+		}
+		op.Summary = "Create a session for a service"
+		op.Tags = []string{"Services"}
+		op.RequestBody = h.requestBody("the time-to-live and initial variables of the session", false, &openapi.Schema{
+			Type: "object",
+			Properties: map[string]*openapi.Schema{
+				"ttl":       &openapi.Schema{Type: "string", Description: "how long the session is kept while idle, e.g., 30m"},
+				"variables": &openapi.Schema{Type: "string", Description: "a DFL dictionary of the initial variables"},
+			},
+		})
+		op.Responses["200"] = h.response("OK", ObjectFormats, openapi.Ref("Session"))
+		op.Responses["400"] = &openapi.Response{Ref: "#/components/responses/BadRequest"}
+		op.Responses["404"] = &openapi.Response{Ref: "#/components/responses/NotFound"}
+	case "service_session":
+		switch method {
+		case "GET":
+			op.Summary = "Get a session"
+			op.Responses["200"] = h.response("OK", ObjectFormats, openapi.Ref("Session"))
+		case "DELETE":
+			op.Summary = "Delete a session"
+			op.Responses["200"] = h.response("OK", ObjectFormats, openapi.Ref("Result"))
+		default:
+			return nil
+		}
+		op.Tags = []string{"Services"}
+		op.Responses["404"] = &openapi.Response{Ref: "#/components/responses/NotFound"}
+	case "job_exec", "workflow_exec":
+		if method != "POST" {
+			return nil
+		}
+		t := strings.TrimSuffix(name, "_exec")
+		op.Summary = "Execute a " + t
+		op.Tags = []string{strings.Title(t) + "s"}
+		op.Responses["200"] = h.response("The result of the "+t, ObjectFormats, &openapi.Schema{})
+		op.Responses["400"] = &openapi.Response{Ref: "#/components/responses/BadRequest"}
+		op.Responses["404"] = &openapi.Response{Ref: "#/components/responses/NotFound"}
+	case "schedules":
+		op.Summary = "List the scheduled jobs and workflows"
+		op.Tags = []string{"Schedules"}
+		op.Responses["200"] = h.response("OK", ObjectFormats, &openapi.Schema{
+			Type: "object",
+			Properties: map[string]*openapi.Schema{
+				"schedules": &openapi.Schema{Type: "array", Items: &openapi.Schema{Type: "object"}},
+			},
+		})
+	case "runs":
+		op.Summary = "List runs, most recent first"
+		op.Description = "Admin users can list every run, while other users can only list their own runs."
+		op.Tags = []string{"Runs"}
+		op.Security = jwtSecurity
+		for _, p := range []string{"type", "name", "service", "job", "workflow", "status"} {
+			op.Parameters = append(op.Parameters, &openapi.Parameter{Name: p, In: "query", Schema: &openapi.Schema{Type: "string"}})
+		}
+		op.Parameters = append(op.Parameters, &openapi.Parameter{
+			Name:        "since",
+			In:          "query",
+			Description: "Only runs created since a duration ago, e.g., 24h, or a time in RFC3339 format",
+			Schema:      &openapi.Schema{Type: "string"},
+		}, &openapi.Parameter{
+			Name:   "limit",
+			In:     "query",
+			Schema: &openapi.Schema{Type: "integer", Default: 100, Minimum: floatPtr(0)},
+		})
+		op.Responses["200"] = h.response("OK", ObjectFormats, &openapi.Schema{
+			Type:       "object",
+			Properties: map[string]*openapi.Schema{"items": &openapi.Schema{Type: "array", Items: openapi.Ref("Run")}},
+		})
+		op.Responses["400"] = &openapi.Response{Ref: "#/components/responses/BadRequest"}
+		op.Responses["401"] = &openapi.Response{Ref: "#/components/responses/Unauthorized"}
+	case "run":
+		op.Summary = "Get a run"
+		op.Tags = []string{"Runs"}
+		op.Security = jwtSecurity
+		op.Responses["401"] = &openapi.Response{Ref: "#/components/responses/Unauthorized"}
+		op.Responses["200"] = h.response("OK", ObjectFormats, openapi.Ref("Run"))
+		op.Responses["404"] = &openapi.Response{Ref: "#/components/responses/NotFound"}
+	case "run_logs":
+		op.Summary = "Get the logs of a run"
+		op.Tags = []string{"Runs"}
+		op.Security = jwtSecurity
+		op.Responses["401"] = &openapi.Response{Ref: "#/components/responses/Unauthorized"}
+		op.Responses["200"] = h.response("OK", ObjectFormats, &openapi.Schema{
+			Type: "object",
+			Properties: map[string]*openapi.Schema{
+				"id":   &openapi.Schema{Type: "string"},
+				"logs": &openapi.Schema{Type: "array", Items: &openapi.Schema{Type: "string"}},
+			},
+		})
+		op.Responses["404"] = &openapi.Response{Ref: "#/components/responses/NotFound"}
+	case "cache":
+		op.Summary = "List the entries in the cache shared by layers"
+		op.Tags = []string{"Cache"}
+		op.Security = jwtSecurity
+		op.Parameters = append(op.Parameters, &openapi.Parameter{
+			Name:        "layer",
+			In:          "query",
+			Description: "Only entries read by this layer",
+			Schema:      &openapi.Schema{Type: "string"},
+		})
+		op.Responses["200"] = h.response("OK", ObjectFormats, &openapi.Schema{
+			Type: "object",
+			Properties: map[string]*openapi.Schema{
+				"entries":  &openapi.Schema{Type: "array", Items: openapi.Ref("CacheEntry")},
+				"stats":    &openapi.Schema{Type: "object"},
+				"maxBytes": &openapi.Schema{Type: "integer"},
+				"disk":     &openapi.Schema{Type: "object", Description: "the statistics of the disk cache, if enabled"},
+			},
+		})
+		op.Responses["401"] = &openapi.Response{Ref: "#/components/responses/Unauthorized"}
+		op.Responses["403"] = &openapi.Response{Ref: "#/components/responses/Forbidden"}
+	case "cache_entry":
+		op.Summary = "Delete an entry from the cache"
+		op.Tags = []string{"Cache"}
+		op.Security = jwtSecurity
+		op.Responses["200"] = h.response("OK", ObjectFormats, openapi.Ref("Result"))
+		op.Responses["401"] = &openapi.Response{Ref: "#/components/responses/Unauthorized"}
+		op.Responses["403"] = &openapi.Response{Ref: "#/components/responses/Forbidden"}
+		op.Responses["404"] = &openapi.Response{Ref: "#/components/responses/NotFound"}
+	case "layer_cache":
+		op.Summary = "Delete the cached data of a layer"
+		op.Tags = []string{"Cache"}
+		op.Security = jwtSecurity
+		op.Responses["200"] = h.response("OK", ObjectFormats, openapi.Ref("Result"))
+		op.Responses["401"] = &openapi.Response{Ref: "#/components/responses/Unauthorized"}
+		op.Responses["403"] = &openapi.Response{Ref: "#/components/responses/Forbidden"}
+		op.Responses["404"] = &openapi.Response{Ref: "#/components/responses/NotFound"}
+	case "layer_cache_warm":
+		op.Summary = "Load the data of a layer into the cache"
+		op.Tags = []string{"Cache"}
+		op.Security = jwtSecurity
+		op.RequestBody = h.requestBody("the variables used to evaluate the uri of the data store, e.g., z, x, and y", false, &openapi.Schema{
+			Type:       "object",
+			Properties: map[string]*openapi.Schema{"variables": &openapi.Schema{Type: "string", Description: "a DFL dictionary of the variables, e.g., {z: 4, x: 2, y: 5}"}},
+		})
+		op.Responses["200"] = h.response("OK", ObjectFormats, openapi.Ref("Result"))
+		op.Responses["400"] = &openapi.Response{Ref: "#/components/responses/BadRequest"}
+		op.Responses["401"] = &openapi.Response{Ref: "#/components/responses/Unauthorized"}
+		op.Responses["403"] = &openapi.Response{Ref: "#/components/responses/Forbidden"}
+		op.Responses["404"] = &openapi.Response{Ref: "#/components/responses/NotFound"}
+	case "tile":
+		formats := []string{"json", "jsonl", "yaml", "geojson", "geojsonl"}
+		op.Summary = "Get a tile of features filtered by a DFL expression"
+		op.Tags = []string{"Layers"}
+		op.Parameters = append(h.pathParameters(path, formats), h.dflParameter(), &openapi.Parameter{
+			Name:        "buffer",
+			In:          "query",
+			Description: "The number of tiles to buffer by",
+			Schema:      &openapi.Schema{Type: "integer", Default: 0, Minimum: floatPtr(0)},
+		}, h.limitParameter())
+		op.Responses["200"] = h.response("OK", formats, &openapi.Schema{})
+		op.Responses["404"] = &openapi.Response{Ref: "#/components/responses/NotFound"}
+	case "mask":
+		formats := []string{"png", "jpg", "jpeg", "json", "yaml"}
+		op.Summary = "Get a mask tile of features filtered by a DFL expression"
+		op.Tags = []string{"Layers"}
+		op.Parameters = append(h.pathParameters(path, formats), h.dflParameter(), h.limitParameter(), &openapi.Parameter{
+			Name:        "threshold",
+			In:          "query",
+			Description: "The minimum threshold for the cell to be considered in the region",
+			Schema:      &openapi.Schema{Type: "integer", Default: 0, Minimum: floatPtr(0)},
+		}, &openapi.Parameter{
+			Name:        "zoom",
+			In:          "query",
+			Description: "The mask zoom level",
+			Schema:      &openapi.Schema{Type: "integer", Default: 16, Minimum: floatPtr(0), Maximum: floatPtr(18)},
+		}, &openapi.Parameter{
+			Name:        "alpha",
+			In:          "query",
+			Description: "The mask alpha level",
+			Schema:      &openapi.Schema{Type: "integer", Default: 255, Minimum: floatPtr(0), Maximum: floatPtr(255)},
+		})
+		op.Responses["200"] = h.response("OK", formats, &openapi.Schema{})
+		op.Responses["404"] = &openapi.Response{Ref: "#/components/responses/NotFound"}
+	default:
+		op.Summary = name
+		op.Responses["200"] = h.response("OK", ObjectFormats, &openapi.Schema{})
+	}
+
+	return op
+}
+
+// pathParameters returns the parameters in the path template, with the ext parameter limited to the given formats.
+func (h *OpenApiHandler) pathParameters(path string, formats []string) []*openapi.Parameter {
+	params := make([]*openapi.Parameter, 0)
+	for _, match := range openApiPathParameter.FindAllStringSubmatch(path, -1) {
+		p := &openapi.Parameter{Name: match[1], In: "path", Required: true}
+		switch match[1] {
+		case "ext":
+			enum := make([]interface{}, 0, len(formats))
+			for _, f := range formats {
+				enum = append(enum, f)
+			}
+			p.Description = "The file extension, which sets the format of the response"
+			p.Schema = &openapi.Schema{Type: "string", Enum: enum, Default: formats[0]}
+		case "z":
+			p.Description = "The tile zoom level"
+			p.Schema = &openapi.Schema{Type: "integer", Minimum: floatPtr(0), Maximum: floatPtr(18)}
+		case "x", "y":
+			p.Description = "The tile " + match[1] + " coordinate"
+			p.Schema = &openapi.Schema{Type: "integer", Minimum: floatPtr(0)}
+		default:
+			p.Schema = &openapi.Schema{Type: "string"}
+		}
+		params = append(params, p)
+	}
+	return params
+}
+
+func (h *OpenApiHandler) acceptParameter() *openapi.Parameter {
+	return &openapi.Parameter{
+		Name:        "Accept",
+		In:          "header",
+		Description: "The media types acceptable for the response, e.g., application/json or application/x-yaml",
+		Schema:      &openapi.Schema{Type: "string", Default: "application/json"},
+	}
+}
+
+func (h *OpenApiHandler) dflParameter() *openapi.Parameter {
+	return &openapi.Parameter{
+		Name:        "dfl",
+		In:          "query",
+		Description: "The DFL expression",
+		Schema:      &openapi.Schema{Type: "string"},
+	}
+}
+
+func (h *OpenApiHandler) limitParameter() *openapi.Parameter {
+	return &openapi.Parameter{
+		Name:        "limit",
+		In:          "query",
+		Description: "Limit the number of results to this maximum count",
+		Schema:      &openapi.Schema{Type: "integer", Default: 0, Minimum: floatPtr(0)},
+	}
+}
+
+// content returns the media types for the formats.  Formats with the same content type, e.g., jpg and jpeg, are only included once.
+func (h *OpenApiHandler) content(formats []string, schema *openapi.Schema) map[string]*openapi.MediaType {
+	content := map[string]*openapi.MediaType{}
+	for _, f := range formats {
+		s := schema
+		switch f {
+		case "png", "jpg", "jpeg":
+			s = &openapi.Schema{Type: "string", ContentMediaType: util.ContentType(f)}
+		case "html":
+			s = &openapi.Schema{Type: "string"}
+		}
+		content[util.ContentType(f)] = &openapi.MediaType{Schema: s}
+	}
+	return content
+}
+
+func (h *OpenApiHandler) response(description string, formats []string, schema *openapi.Schema) *openapi.Response {
+	return &openapi.Response{Description: description, Content: h.content(formats, schema)}
+}
+
+func (h *OpenApiHandler) requestBody(description string, required bool, schema *openapi.Schema) *openapi.RequestBody {
+	return &openapi.RequestBody{
+		Description: description,
+		Required:    required,
+		Content:     h.content([]string{"json", "yaml", "toml", "bson"}, schema),
+	}
+}
+
+// buildErrorResponses returns the responses written by RespondWithError and RespondWithNotImplemented.
+func (h *OpenApiHandler) buildErrorResponses() map[string]*openapi.Response {
+	formats := []string{"json", "yaml", "toml", "bson"}
+	return map[string]*openapi.Response{
+		"BadRequest":          h.response("Bad request.  If variables are invalid, then the reason for each variable is in fields.", formats, openapi.Ref("Error")),
+		"Unauthorized":        h.response("Unauthorized.  The request requires authentication.", formats, openapi.Ref("Error")),
+		"Forbidden":           h.response("Forbidden.  The user is not allowed to perform the request.", formats, openapi.Ref("Error")),
+		"NotFound":            h.response("Not found.  The object with the provided name was not found.", formats, openapi.Ref("Error")),
+		"InternalServerError": h.response("Server error.", formats, openapi.Ref("Error")),
+		"NotImplemented":      h.response("Not implemented.", formats, openapi.Ref("Error")),
+	}
+}
+
+func (h *OpenApiHandler) coreProperties(t reflect.Type) map[string]*openapi.Schema {
+	properties := map[string]*openapi.Schema{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if str, ok := f.Tag.Lookup("rest"); ok && str != "" && str != "-" {
+			arr := strings.SplitN(str, ",", 2)
+			p := &openapi.Schema{Type: "string"}
+			if len(arr) == 2 {
+				p.Description = strings.TrimSpace(arr[1])
+			}
+			properties[strings.TrimSpace(arr[0])] = p
+		}
+	}
+	return properties
+}
+
+func (h *OpenApiHandler) coreRequired(t reflect.Type) []string {
+	required := make([]string, 0)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if str, ok := f.Tag.Lookup("rest"); ok && str != "" && str != "-" {
+			if r, ok := f.Tag.Lookup("required"); ok && (r == "true" || r == "t" || r == "1" || r == "y" || r == "yes") {
+				required = append(required, strings.TrimSpace(strings.SplitN(str, ",", 2)[0]))
+			}
+		}
+	}
+	return required
+}
+
+func (h *OpenApiHandler) getExecRequestSchemaName(service *core.Service) string {
+	return strings.Title(service.Name) + "ExecRequest"
+}
+
+// BuildSchemas returns the schemas for the core types, the responses, and the exec requests of services with declared parameters.
+func (h *OpenApiHandler) BuildSchemas() map[string]*openapi.Schema {
+	schemas := map[string]*openapi.Schema{
+		"Credentials": &openapi.Schema{
+			Type:     "object",
+			Required: []string{"username", "password"},
+			Properties: map[string]*openapi.Schema{
+				"username": &openapi.Schema{Type: "string"},
+				"password": &openapi.Schema{Type: "string", Format: "password"},
+			},
+		},
+		"Token": &openapi.Schema{
+			Type: "object",
+			Properties: map[string]*openapi.Schema{
+				"success":  &openapi.Schema{Type: "boolean"},
+				"username": &openapi.Schema{Type: "string"},
+				"message":  &openapi.Schema{Type: "string"},
+				"token":    &openapi.Schema{Type: "string"},
+			},
+		},
+		"Error": &openapi.Schema{
+			Type:     "object",
+			Required: []string{"success", "error"},
+			Properties: map[string]*openapi.Schema{
+				"success": &openapi.Schema{Type: "boolean"},
+				"error":   &openapi.Schema{Type: "string"},
+				"fields": &openapi.Schema{
+					Type:                 "object",
+					Description:          "the reason each invalid variable is invalid, by variable name",
+					AdditionalProperties: &openapi.Schema{Type: "string"},
+				},
+			},
+		},
+		"Result": &openapi.Schema{
+			Type: "object",
+			Properties: map[string]*openapi.Schema{
+				"success": &openapi.Schema{Type: "boolean"},
+				"message": &openapi.Schema{Type: "string"},
+			},
+		},
+		"ExecRequest": &openapi.Schema{
+			Type: "object",
+			Properties: map[string]*openapi.Schema{
+				"variables": &openapi.Schema{Type: "string", Description: "a DFL dictionary of the input variables"},
+				"session":   &openapi.Schema{Type: "string", Description: "the id of the session, if not set then the service is executed without state"},
+			},
+		},
+		"Session": &openapi.Schema{
+			Type: "object",
+			Properties: map[string]*openapi.Schema{
+				"session": &openapi.Schema{Type: "string"},
+				"service": &openapi.Schema{Type: "string"},
+				"user":    &openapi.Schema{Type: "string"},
+				"ttl":     &openapi.Schema{Type: "string"},
+				"created": &openapi.Schema{Type: "string", Format: "date-time"},
+				"expires": &openapi.Schema{Type: "string", Format: "date-time"},
+			},
+		},
+		"CacheEntry": &openapi.Schema{
+			Type: "object",
+			Properties: map[string]*openapi.Schema{
+				"key":     &openapi.Schema{Type: "string"},
+				"uri":     &openapi.Schema{Type: "string"},
+				"layer":   &openapi.Schema{Type: "string", Description: "the layer that added the entry"},
+				"size":    &openapi.Schema{Type: "integer", Description: "the estimated size in bytes of the deserialized data"},
+				"age":     &openapi.Schema{Type: "number", Description: "the age of the entry in seconds"},
+				"hits":    &openapi.Schema{Type: "integer"},
+				"pinned":  &openapi.Schema{Type: "boolean"},
+				"expires": &openapi.Schema{Type: "string", Format: "date-time"},
+			},
+		},
+		"Readiness": &openapi.Schema{
+			Type: "object",
+			Properties: map[string]*openapi.Schema{
+				"status": &openapi.Schema{Type: "string", Enum: []interface{}{"ok", "fail"}},
+				"checks": &openapi.Schema{
+					Type: "array",
+					Items: &openapi.Schema{
+						Type: "object",
+						Properties: map[string]*openapi.Schema{
+							"name":     &openapi.Schema{Type: "string"},
+							"status":   &openapi.Schema{Type: "string", Enum: []interface{}{"ok", "fail", "skip"}},
+							"message":  &openapi.Schema{Type: "string"},
+							"duration": &openapi.Schema{Type: "number", Description: "the duration of the check in seconds"},
+							"checked":  &openapi.Schema{Type: "string", Format: "date-time"},
+						},
+					},
+				},
+			},
+		},
+		"Run": &openapi.Schema{
+			Type: "object",
+			Properties: map[string]*openapi.Schema{
+				"id":         &openapi.Schema{Type: "string"},
+				"type":       &openapi.Schema{Type: "string", Enum: []interface{}{"service", "job", "workflow"}},
+				"name":       &openapi.Schema{Type: "string"},
+				"trigger":    &openapi.Schema{Type: "string"},
+				"user":       &openapi.Schema{Type: "string"},
+				"status":     &openapi.Schema{Type: "string"},
+				"created":    &openapi.Schema{Type: "string", Format: "date-time"},
+				"scheduled":  &openapi.Schema{Type: "string", Format: "date-time"},
+				"start":      &openapi.Schema{Type: "string", Format: "date-time"},
+				"end":        &openapi.Schema{Type: "string", Format: "date-time"},
+				"duration":   &openapi.Schema{Type: "string"},
+				"attempts":   &openapi.Schema{Type: "integer"},
+				"inputs":     &openapi.Schema{Type: "array", Items: &openapi.Schema{Type: "string"}},
+				"variables":  &openapi.Schema{Type: "object"},
+				"outputs":    &openapi.Schema{Type: "array", Items: &openapi.Schema{Type: "string"}},
+				"outputSize": &openapi.Schema{Type: "integer"},
+				"error":      &openapi.Schema{Type: "string"},
+			},
+		},
+	}
+
+	for name, t := range core.CoreTypes {
+		schemas[strings.Title(name)] = &openapi.Schema{
+			Type:        "object",
+			Description: "The values are DFL expressions.",
+			Required:    h.coreRequired(t),
+			Properties:  h.coreProperties(t),
+		}
+	}
+
+	for _, service := range h.Catalog.ListServices() {
+		if len(service.Process.Parameters) == 0 {
+			continue
+		}
+		variables := &openapi.Schema{
+			Type:        "object",
+			Description: "the input variables, as an object or a DFL dictionary",
+			Properties:  map[string]*openapi.Schema{},
+			Required:    make([]string, 0),
+		}
+		for _, p := range service.Process.Parameters {
+			variables.Properties[p.Name] = parameterSchema(p)
+			if p.Required && p.Default == nil {
+				variables.Required = append(variables.Required, p.Name)
+			}
+		}
+		sort.Strings(variables.Required)
+		schemas[h.getExecRequestSchemaName(service)] = &openapi.Schema{
+			Type:  "object",
+			Title: service.Title,
+			Properties: map[string]*openapi.Schema{
+				"variables": variables,
+				"session":   &openapi.Schema{Type: "string", Description: "the id of the session, if not set then the service is executed without state"},
+			},
+		}
+	}
+
+	return schemas
+}
+
+// parameterSchema returns the schema for a parameter declared by a process.
+// The schema is also used by the swagger document, since JSON Schema is shared by both versions of the specification.
+func parameterSchema(p *core.Parameter) *openapi.Schema {
+	s := &openapi.Schema{Description: p.Description, Default: p.Default}
+	switch p.Type {
+	case core.ParameterTypeString:
+		s.Type = "string"
+		s.MinLength = intPtr(p.Minimum)
+		s.MaxLength = intPtr(p.Maximum)
+	case core.ParameterTypeInt:
+		s.Type = "integer"
+		s.Minimum = p.Minimum
+		s.Maximum = p.Maximum
+	case core.ParameterTypeFloat:
+		s.Type = "number"
+		s.Minimum = p.Minimum
+		s.Maximum = p.Maximum
+	case core.ParameterTypeBool:
+		s.Type = "boolean"
+	case core.ParameterTypeBbox:
+		four := 4
+		s.Type = "array"
+		s.Items = &openapi.Schema{Type: "number"}
+		s.MinItems = &four
+		s.MaxItems = &four
+		if len(s.Description) == 0 {
+			s.Description = "minx, miny, maxx, maxy"
+		}
+	case core.ParameterTypeEnum:
+		s.Type = "string"
+		for _, v := range p.Values {
+			s.Enum = append(s.Enum, v)
+		}
+	case core.ParameterTypeArray:
+		s.Type = "array"
+		s.Items = &openapi.Schema{}
+		s.MinItems = intPtr(p.Minimum)
+		s.MaxItems = intPtr(p.Maximum)
+	}
+	return s
+}
+
+func floatPtr(f float64) *float64 {
+	return &f
+}
+
+func intPtr(f *float64) *int {
+	if f == nil {
+		return nil
+	}
+	i := int(*f)
+	return &i
+}
@@ -0,0 +1,193 @@
+// =================================================================
+//
+// Copyright (C) 2018 Spatial Current, Inc. - All Rights Reserved
+// Released as open source under the MIT License.  See LICENSE file.
+//
+// =================================================================
+
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/mitchellh/go-homedir"
+	"github.com/pkg/errors"
+	"github.com/spatialcurrent/go-dfl/dfl"
+	"github.com/spatialcurrent/go-reader-writer/grw"
+	"github.com/spatialcurrent/railgun/railgun/cache"
+	"github.com/spatialcurrent/railgun/railgun/core"
+	"github.com/spatialcurrent/railgun/railgun/health"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ReadinessHandler checks that the server is ready to serve requests.
+// The server is ready if the catalog backend is loaded and writable, the data stores marked critical are reachable,
+// and the queues of requests, messages, and errors are not saturated.
+// Responds with 200 if ready and 503 if not, with the result of each check.
+type ReadinessHandler struct {
+	*BaseHandler
+	Checker *health.Checker
+}
+
+func (h *ReadinessHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	format, ok := h.NegotiateFormat(w, r)
+	if !ok {
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		checks := h.Check(r.Context())
+		status := health.StatusOk
+		statusCode := http.StatusOK
+		results := make([]map[string]interface{}, 0, len(checks))
+		for _, c := range checks {
+			if !c.Ok() {
+				status = health.StatusFail
+				statusCode = http.StatusServiceUnavailable
+			}
+			results = append(results, c.Map())
+		}
+		obj := map[string]interface{}{
+			"status": status,
+			"checks": results,
+		}
+		err := h.RespondWithObject(w, statusCode, obj, format)
+		if err != nil {
+			h.SendError(r, err)
+			err = h.RespondWithError(w, err, format)
+			if err != nil {
+				panic(err)
+			}
+		}
+	default:
+		err := h.RespondWithNotImplemented(w, format)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+}
+
+// Check runs the readiness checks in parallel and returns the results in a stable order.
+func (h *ReadinessHandler) Check(ctx context.Context) []*health.Check {
+	h.Catalog.Lock()
+	datastores := h.Catalog.ListDataStores()
+	h.Catalog.Unlock()
+	sort.Slice(datastores, func(i, j int) bool { return datastores[i].Name < datastores[j].Name })
+
+	names := []string{"catalog", "queues"}
+	funcs := []func(ctx context.Context) error{h.checkCatalog, h.checkQueues}
+	for _, ds := range datastores {
+		if !ds.Critical {
+			continue
+		}
+		ds := ds
+		names = append(names, "datastore:"+ds.Name)
+		funcs = append(funcs, func(ctx context.Context) error {
+			return h.checkDataStore(ctx, ds)
+		})
+	}
+
+	checks := make([]*health.Check, len(names))
+	wg := &sync.WaitGroup{}
+	for i := range names {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			checks[i] = h.Checker.Run(ctx, names[i], funcs[i])
+		}(i)
+	}
+	wg.Wait()
+	return checks
+}
+
+// checkCatalog checks that the catalog was loaded from the catalog backend and that the backend is writable.
+func (h *ReadinessHandler) checkCatalog(ctx context.Context) error {
+	uri := h.Viper.GetString("catalog-uri")
+	if len(uri) == 0 {
+		return &health.ErrSkip{Reason: "no catalog backend"}
+	}
+	if h.Catalog.Loaded.IsZero() {
+		return errors.New("catalog not loaded from " + uri)
+	}
+	scheme, path := grw.SplitUri(uri)
+	switch scheme {
+	case "s3":
+		s3_client, err := h.GetAWSS3Client()
+		if err != nil {
+			return errors.Wrap(err, "error connecting to AWS")
+		}
+		bucket := strings.SplitN(path, "/", 2)[0]
+		_, err = s3_client.HeadBucketWithContext(ctx, &s3.HeadBucketInput{Bucket: aws.String(bucket)})
+		if err != nil {
+			return errors.Wrap(err, "error reaching bucket "+bucket)
+		}
+		return nil
+	case "", "file":
+		pathExpanded, err := homedir.Expand(path)
+		if err != nil {
+			return errors.Wrap(err, "error expanding path "+path)
+		}
+		// the catalog is written to a new file, so the directory must be writable.
+		f, err := ioutil.TempFile(filepath.Dir(pathExpanded), ".railgun-ready-")
+		if err != nil {
+			return errors.Wrap(err, "catalog directory is not writable")
+		}
+		f.Close()
+		os.Remove(f.Name())
+		return nil
+	}
+	return &health.ErrSkip{Reason: "cannot check catalog backend with scheme " + scheme}
+}
+
+// checkQueues checks that the queues of requests, messages, and errors are not saturated.
+func (h *ReadinessHandler) checkQueues(ctx context.Context) error {
+	threshold := h.Viper.GetFloat64("health-queue-threshold")
+	saturated := make([]string, 0)
+	for name, size := range map[string][2]int{
+		"requests": [2]int{len(h.Requests), cap(h.Requests)},
+		"messages": [2]int{len(h.Messages), cap(h.Messages)},
+		"errors":   [2]int{len(h.Errors), cap(h.Errors)},
+	} {
+		if size[1] > 0 && float64(size[0])/float64(size[1]) >= threshold {
+			saturated = append(saturated, fmt.Sprintf("%s (%d of %d)", name, size[0], size[1]))
+		}
+	}
+	if len(saturated) > 0 {
+		sort.Strings(saturated)
+		return errors.New("saturated queues: " + strings.Join(saturated, ", "))
+	}
+	return nil
+}
+
+// checkDataStore checks that the data store is reachable, without reading it.
+func (h *ReadinessHandler) checkDataStore(ctx context.Context, ds *core.DataStore) error {
+	_, uri, err := dfl.EvaluateString(ds.Uri, map[string]interface{}{}, map[string]interface{}{}, dfl.DefaultFunctionMap, dfl.DefaultQuotes)
+	if err != nil {
+		return &health.ErrSkip{Reason: "uri depends on variables"}
+	}
+	scheme, _ := grw.SplitUri(uri)
+	if !cache.CanVersion(scheme) {
+		return &health.ErrSkip{Reason: "cannot check data store with scheme " + scheme}
+	}
+	var s3_client *s3.S3
+	if scheme == "s3" {
+		s3_client, err = h.GetAWSS3Client()
+		if err != nil {
+			return errors.Wrap(err, "error connecting to AWS")
+		}
+	}
+	// reading the version of the data store checks that it is reachable.
+	_, err = cache.Version(ctx, uri, s3_client)
+	return err
+}
@@ -0,0 +1,30 @@
+// =================================================================
+//
+// Copyright (C) 2018 Spatial Current, Inc. - All Rights Reserved
+// Released as open source under the MIT License.  See LICENSE file.
+//
+// =================================================================
+
+package handlers
+
+import (
+	"net/http"
+)
+
+// ResponseCounter is a http.ResponseWriter that counts the bytes written to the response body.
+type ResponseCounter struct {
+	http.ResponseWriter
+	Bytes int64
+}
+
+func (rc *ResponseCounter) Write(b []byte) (int, error) {
+	n, err := rc.ResponseWriter.Write(b)
+	rc.Bytes += int64(n)
+	return n, err
+}
+
+func (rc *ResponseCounter) Flush() {
+	if f, ok := rc.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
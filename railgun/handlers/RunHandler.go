@@ -0,0 +1,88 @@
+// =================================================================
+//
+// Copyright (C) 2018 Spatial Current, Inc. - All Rights Reserved
+// Released as open source under the MIT License.  See LICENSE file.
+//
+// =================================================================
+
+package handlers
+
+import (
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+	rerrors "github.com/spatialcurrent/railgun/railgun/errors"
+	"net/http"
+)
+
+// RunHandler returns a run or, if Logs is true, the logs of the run.
+type RunHandler struct {
+	*BaseHandler
+	Logs bool
+}
+
+func (h *RunHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	format, ok := h.NegotiateFormat(w, r)
+	if !ok {
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		obj, err := h.Get(w, r, format, mux.Vars(r))
+		if err != nil {
+			h.SendError(r, err)
+			err = h.RespondWithError(w, err, format)
+			if err != nil {
+				panic(err)
+			}
+		} else {
+			err = h.RespondWithObject(w, http.StatusOK, obj, format)
+			if err != nil {
+				h.SendError(r, err)
+				err = h.RespondWithError(w, err, format)
+				if err != nil {
+					panic(err)
+				}
+			}
+		}
+	default:
+		err := h.RespondWithNotImplemented(w, format)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+}
+
+func (h *RunHandler) Get(w http.ResponseWriter, r *http.Request, format string, vars map[string]string) (interface{}, error) {
+
+	user, err := h.AuthorizeRuns(r)
+	if err != nil {
+		return nil, err
+	}
+
+	id, ok := vars["id"]
+	if !ok {
+		return nil, &rerrors.ErrMissingRequiredParameter{Name: "id"}
+	}
+
+	run, ok, err := h.Runs.Get(id)
+	if err != nil {
+		return nil, errors.Wrap(err, "error getting run "+id)
+	}
+	// the runs of other users are missing, so their ids are not revealed.
+	if !ok || (len(user) > 0 && run.User != user) {
+		return nil, &rerrors.ErrMissingObject{Type: "run", Name: id}
+	}
+
+	if h.Logs {
+		logs := run.Logs
+		if logs == nil {
+			logs = make([]string, 0)
+		}
+		return map[string]interface{}{"id": run.Id, "logs": logs}, nil
+	}
+
+	return run.Map(), nil
+}
@@ -0,0 +1,113 @@
+// =================================================================
+//
+// Copyright (C) 2018 Spatial Current, Inc. - All Rights Reserved
+// Released as open source under the MIT License.  See LICENSE file.
+//
+// =================================================================
+
+package handlers
+
+import (
+	"github.com/pkg/errors"
+	rerrors "github.com/spatialcurrent/railgun/railgun/errors"
+	"github.com/spatialcurrent/railgun/railgun/runs"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type RunsHandler struct {
+	*BaseHandler
+}
+
+func (h *RunsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	format, ok := h.NegotiateFormat(w, r)
+	if !ok {
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		obj, err := h.Get(w, r, format)
+		if err != nil {
+			h.SendError(r, err)
+			err = h.RespondWithError(w, err, format)
+			if err != nil {
+				panic(err)
+			}
+		} else {
+			err = h.RespondWithObject(w, http.StatusOK, obj, format)
+			if err != nil {
+				h.SendError(r, err)
+				err = h.RespondWithError(w, err, format)
+				if err != nil {
+					panic(err)
+				}
+			}
+		}
+	default:
+		err := h.RespondWithNotImplemented(w, format)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+}
+
+// ParseQuery parses the filters for runs from the query string.
+// The job, workflow, and service parameters are shortcuts for type and name.
+// The since parameter is either a RFC 3339 timestamp or a duration before now, e.g., 24h.
+func (h *RunsHandler) ParseQuery(r *http.Request) (*runs.Query, error) {
+	qs := r.URL.Query()
+	q := &runs.Query{
+		Type:   qs.Get("type"),
+		Name:   qs.Get("name"),
+		Status: qs.Get("status"),
+		Limit:  100,
+	}
+	for _, t := range []string{"service", "job", "workflow"} {
+		if name := qs.Get(t); len(name) > 0 {
+			q.Type = t
+			q.Name = name
+		}
+	}
+	if since := qs.Get("since"); len(since) > 0 {
+		if d, err := time.ParseDuration(since); err == nil {
+			q.Since = time.Now().Add(-1 * d)
+		} else if t, err := time.Parse(time.RFC3339, since); err == nil {
+			q.Since = t
+		} else {
+			return nil, &rerrors.ErrInvalidParameter{Name: "since", Value: since}
+		}
+	}
+	if limit := qs.Get("limit"); len(limit) > 0 {
+		i, err := strconv.Atoi(limit)
+		if err != nil || i < 0 {
+			return nil, &rerrors.ErrInvalidParameter{Name: "limit", Value: limit}
+		}
+		q.Limit = i
+	}
+	return q, nil
+}
+
+func (h *RunsHandler) Get(w http.ResponseWriter, r *http.Request, format string) (interface{}, error) {
+	user, err := h.AuthorizeRuns(r)
+	if err != nil {
+		return nil, err
+	}
+	q, err := h.ParseQuery(r)
+	if err != nil {
+		return nil, err
+	}
+	q.User = user
+	list, err := h.Runs.List(q)
+	if err != nil {
+		return nil, errors.Wrap(err, "error listing runs")
+	}
+	items := make([]map[string]interface{}, 0, len(list))
+	for _, run := range list {
+		items = append(items, run.Map())
+	}
+	return map[string]interface{}{"items": items}, nil
+}
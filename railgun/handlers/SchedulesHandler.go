@@ -0,0 +1,47 @@
+// =================================================================
+//
+// Copyright (C) 2018 Spatial Current, Inc. - All Rights Reserved
+// Released as open source under the MIT License.  See LICENSE file.
+//
+// =================================================================
+
+package handlers
+
+import (
+	"github.com/spatialcurrent/railgun/railgun/scheduler"
+	"net/http"
+)
+
+type SchedulesHandler struct {
+	*BaseHandler
+	Scheduler *scheduler.Scheduler
+}
+
+func (h *SchedulesHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	format, ok := h.NegotiateFormat(w, r)
+	if !ok {
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		obj := map[string]interface{}{
+			"schedules": h.Scheduler.List(),
+		}
+		err := h.RespondWithObject(w, http.StatusOK, obj, format)
+		if err != nil {
+			h.SendError(r, err)
+			err = h.RespondWithError(w, err, format)
+			if err != nil {
+				panic(err)
+			}
+		}
+	default:
+		err := h.RespondWithNotImplemented(w, format)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+}
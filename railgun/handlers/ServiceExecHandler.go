@@ -9,13 +9,19 @@ package handlers
 
 import (
 	"bufio"
+	"bytes"
 	"compress/bzip2"
 	"compress/gzip"
+	"encoding/csv"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"os"
+	"reflect"
+	"strconv"
 	"strings"
+	"time"
 )
 
 import (
@@ -30,40 +36,76 @@ import (
 	"github.com/spatialcurrent/go-reader-writer/grw"
 	"github.com/spatialcurrent/go-simple-serializer/gss"
 	"github.com/spatialcurrent/go-try-get/gtg"
+	"github.com/spatialcurrent/railgun/railgun/core"
 	rerrors "github.com/spatialcurrent/railgun/railgun/errors"
 	"github.com/spatialcurrent/railgun/railgun/parser"
+	"github.com/spatialcurrent/railgun/railgun/runs"
+	"github.com/spatialcurrent/railgun/railgun/session"
+	"github.com/spatialcurrent/railgun/railgun/tracing"
 	"github.com/spatialcurrent/railgun/railgun/util"
 )
 
 type ServiceExecHandler struct {
 	*BaseHandler
-	Cache *gocache.Cache
+	Cache    *gocache.Cache
+	Sessions *session.Store
 }
 
 var cacheKeyDataStoreFormat = "%s/datastore/%d"
-var cacheKeyServiceFormat = "%s/variables"
 
 func (h *ServiceExecHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
-	_, format, _ := util.SplitNameFormatCompression(r.URL.Path)
+	format, ok := h.NegotiateFormat(w, r)
+	if !ok {
+		return
+	}
 
 	switch r.Method {
 	case "POST":
-		obj, err := h.Post(w, r, format, mux.Vars(r))
+		vars := mux.Vars(r)
+		run := h.StartRun(r, "service", vars["name"])
+		rc := &ResponseCounter{ResponseWriter: w}
+		if stream, _ := strconv.ParseBool(r.URL.Query().Get("stream")); stream {
+			err := h.Stream(rc, r, format, vars, run)
+			if err != nil {
+				h.SendError(r, err)
+				h.FinishRun(run, err)
+				if rc.Bytes == 0 {
+					err = h.RespondWithError(rc, err, format)
+					if err != nil {
+						panic(err)
+					}
+					return
+				}
+				// once records have been sent, the connection is aborted without the terminating chunk,
+				// so the client sees a truncated response rather than a complete one.
+				panic(http.ErrAbortHandler)
+			} else {
+				run.OutputSize = rc.Bytes
+				h.FinishRun(run, nil)
+			}
+			return
+		}
+		obj, err := h.Post(rc, r, format, vars, run)
 		if err != nil {
-			h.Messages <- err
-			err = h.RespondWithError(w, err, format)
+			h.SendError(r, err)
+			h.FinishRun(run, err)
+			err = h.RespondWithError(rc, err, format)
 			if err != nil {
 				panic(err)
 			}
 		} else {
-			err = h.RespondWithObject(w, http.StatusOK, obj, format)
+			err = h.RespondWithObject(rc, http.StatusOK, obj, format)
 			if err != nil {
-				h.Messages <- err
-				err = h.RespondWithError(w, err, format)
+				h.SendError(r, err)
+				h.FinishRun(run, err)
+				err = h.RespondWithError(rc, err, format)
 				if err != nil {
 					panic(err)
 				}
+			} else {
+				run.OutputSize = rc.Bytes
+				h.FinishRun(run, nil)
 			}
 		}
 	case "OPTIONS":
@@ -76,7 +118,7 @@ func (h *ServiceExecHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 }
 
-func (h *ServiceExecHandler) Post(w http.ResponseWriter, r *http.Request, format string, vars map[string]string) (interface{}, error) {
+func (h *ServiceExecHandler) Post(w http.ResponseWriter, r *http.Request, format string, vars map[string]string, run *runs.Run) (interface{}, error) {
 
 	serviceName, ok := vars["name"]
 	if !ok {
@@ -88,51 +130,20 @@ func (h *ServiceExecHandler) Post(w http.ResponseWriter, r *http.Request, format
 		return nil, &rerrors.ErrMissingObject{Type: "service", Name: serviceName}
 	}
 
-	body, err := ioutil.ReadAll(r.Body)
+	variables, sess, err := h.loadVariables(r, format, service)
 	if err != nil {
-		return nil, errors.Wrap(err, "error reading from request body")
-	}
-
-	variables := map[string]interface{}{}
-
-	cacheKeyService := fmt.Sprintf(cacheKeyServiceFormat, serviceName)
-
-	// Load variables from cache
-	if cacheVariables, found := h.Cache.Get(cacheKeyService); found {
-		if m, ok := cacheVariables.(*map[string]interface{}); ok {
-			h.Messages <- "variables found in cache " + cacheKeyService
-			for k, v := range *m {
-				variables[k] = v
-			}
-		}
-	}
-
-	// Load default variable values from service definition
-	for k, v := range service.Defaults {
-		variables[k] = v
+		return nil, err
 	}
-
-	// Load variables from request body
-	if len(body) > 0 {
-		obj, err := h.ParseBody(body, format)
-		if err != nil {
-			return nil, errors.Wrap(err, "error parsing body")
-		}
-
-		jobVariables, err := parser.ParseMap(obj, "variables")
-		if err != nil {
-			return nil, &rerrors.ErrInvalidParameter{Name: "variables", Value: gtg.TryGetString(obj, "variables", "")}
-		}
-
-		for k, v := range jobVariables {
-			variables[k] = v
-		}
+	if sess != nil {
+		defer sess.Unlock()
 	}
 
 	_, inputUri, err := dfl.EvaluateString(service.DataStore.Uri, variables, map[string]interface{}{}, dfl.DefaultFunctionMap, dfl.DefaultQuotes)
 	if err != nil {
 		return nil, errors.Wrap(err, "invalid data store uri")
 	}
+	run.Inputs = []string{inputUri}
+	tracing.SetAttributes(r.Context(), tracing.AttributeDataStoreUri.String(inputUri))
 
 	inputScheme, inputPath := grw.SplitUri(inputUri)
 
@@ -233,8 +244,12 @@ func (h *ServiceExecHandler) Post(w http.ResponseWriter, r *http.Request, format
 
 	}
 
+	tracing.SetAttributes(r.Context(), tracing.AttributeCacheHit.Bool(inputObject != nil))
+
 	if inputObject == nil {
 
+		start := time.Now()
+
 		//fmt.Println("Input Object is nil")
 
 		if inputReader == nil {
@@ -251,6 +266,15 @@ func (h *ServiceExecHandler) Post(w http.ResponseWriter, r *http.Request, format
 			return nil, errors.Wrap(err, "error reading from resource at uri "+inputUri)
 		}
 
+		passphrase, salt, err := h.Keyring.Lookup(service.DataStore.Key)
+		if err != nil {
+			return nil, errors.Wrap(err, "error resolving key for data store "+service.DataStore.Name)
+		}
+		inputBytes, err = util.DecryptBytes(inputBytes, passphrase, salt, len(service.DataStore.Key) > 0, h.Keyring)
+		if err != nil {
+			return nil, errors.Wrap(err, "error decrypting data from uri "+inputUri)
+		}
+
 		//fmt.Println("Input Bytes:", len(inputBytes))
 
 		inputFormat := service.DataStore.Format
@@ -269,6 +293,8 @@ func (h *ServiceExecHandler) Post(w http.ResponseWriter, r *http.Request, format
 
 		inputObject = object
 
+		h.Metrics.ObserveDataStoreRead(inputUri, time.Since(start))
+
 	}
 
 	//fmt.Println("saving to cache")
@@ -279,18 +305,273 @@ func (h *ServiceExecHandler) Post(w http.ResponseWriter, r *http.Request, format
 
 	//fmt.Println("evaluating")
 
+	_, span := tracing.Start(r.Context(), "process.Evaluate", tracing.AttributeProcess.String(service.Process.Name))
+	start := time.Now()
 	variables, outputObject, err := service.Process.Node.Evaluate(variables, inputObject, dfl.DefaultFunctionMap, dfl.DefaultQuotes)
+	h.Metrics.ObserveProcess(service.Process.Name, time.Since(start))
 	if err != nil {
-		return nil, errors.Wrap(err, "error evaluating process with name "+service.Process.Name)
+		err = errors.Wrap(err, "error evaluating process with name "+service.Process.Name)
+		tracing.End(span, err)
+		return nil, err
+	}
+	tracing.End(span, nil)
+	if m, ok := gss.StringifyMapKeys(variables).(map[string]interface{}); ok {
+		run.Variables = m
 	}
 
-	//fmt.Println("saving variables")
+	if sess != nil {
+		sess.Variables = variables
+		h.Sessions.Touch(sess)
+	}
 
-	// Set the variables to the cache every time to bump the expiration
-	h.Cache.Set(cacheKeyService, &variables, gocache.DefaultExpiration)
+	return outputObject, nil
 
-	//fmt.Println("variables saved")
+}
 
-	return outputObject, nil
+// loadVariables returns the variables for executing the service, in order of precedence: service defaults, session variables, and then request variables.
+// If the request uses a session, then the session is returned locked and the caller must unlock it.
+func (h *ServiceExecHandler) loadVariables(r *http.Request, format string, service *core.Service) (map[string]interface{}, *session.Session, error) {
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "error reading from request body")
+	}
+
+	var obj interface{}
+	if len(body) > 0 {
+		obj, err = h.ParseBody(r, body, format)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "error parsing body")
+		}
+	}
+
+	// the request variables are either an object, as described by the exec request schema of the service, or a DFL dictionary.
+	requestVariables := map[string]interface{}{}
+	if obj != nil {
+		if m := gtg.TryGet(obj, "variables", nil); m != nil && reflect.TypeOf(m).Kind() == reflect.Map {
+			requestVariables = gss.StringifyMapKeys(m).(map[string]interface{})
+		} else {
+			requestVariables, err = parser.ParseMap(obj, "variables")
+			if err != nil {
+				return nil, nil, &rerrors.ErrInvalidParameter{Name: "variables", Value: gtg.TryGetString(obj, "variables", "")}
+			}
+		}
+	}
+
+	variables := map[string]interface{}{}
+
+	// Load default variable values from service definition
+	for k, v := range service.Defaults {
+		variables[k] = v
+	}
 
+	// Load variables from the session, if any.  Exec is stateless without a session.
+	sessionId := r.URL.Query().Get("session")
+	if obj != nil {
+		sessionId = gtg.TryGetString(obj, "session", sessionId)
+	}
+	var sess *session.Session
+	if len(sessionId) > 0 {
+		s, ok := h.Sessions.Get(sessionId, service.Name, h.GetUser(r))
+		if !ok {
+			return nil, nil, &rerrors.ErrMissingObject{Type: "session", Name: sessionId}
+		}
+		// calls within a session are serialized, so each call sees the state of the previous call.
+		s.Lock()
+		for k, v := range s.Variables {
+			variables[k] = v
+		}
+		sess = s
+	}
+
+	// Load variables from request body
+	for k, v := range requestVariables {
+		variables[k] = v
+	}
+
+	// Validate variables before evaluating anything, so bad input is reported by field.
+	variables, err = service.Process.Validate(variables)
+	if err != nil {
+		if sess != nil {
+			sess.Unlock()
+		}
+		return nil, nil, err
+	}
+
+	return variables, sess, nil
+}
+
+// Stream evaluates the process of the service once for each record of a csv, tsv, or jsonl data store, like `railgun process --stream`.
+// Each result is written to the response as soon as it is ready, so memory use does not grow with the size of the data store.
+// The request body is always parsed as json, since the format of the response is one of the stream formats.
+// Variables set by the process are not saved to the session, since the process is evaluated for each record.
+// Stops reading the data store if the client cancels the request.
+func (h *ServiceExecHandler) Stream(w http.ResponseWriter, r *http.Request, format string, vars map[string]string, run *runs.Run) error {
+
+	serviceName, ok := vars["name"]
+	if !ok {
+		return &rerrors.ErrMissingRequiredParameter{Name: "name"}
+	}
+
+	service, ok := h.Catalog.GetService(serviceName)
+	if !ok {
+		return &rerrors.ErrMissingObject{Type: "service", Name: serviceName}
+	}
+
+	if !canStream(format) {
+		return &rerrors.ErrInvalidParameter{Name: "ext", Value: format}
+	}
+
+	inputFormat := service.DataStore.Format
+	if !canStream(inputFormat) {
+		return &rerrors.ErrInvalidParameter{Name: "stream", Value: "data store with format " + inputFormat}
+	}
+
+	variables, sess, err := h.loadVariables(r, "json", service)
+	if err != nil {
+		return err
+	}
+	if sess != nil {
+		defer sess.Unlock()
+	}
+
+	_, inputUri, err := dfl.EvaluateString(service.DataStore.Uri, variables, map[string]interface{}{}, dfl.DefaultFunctionMap, dfl.DefaultQuotes)
+	if err != nil {
+		return errors.Wrap(err, "invalid data store uri")
+	}
+	run.Inputs = []string{inputUri}
+	tracing.SetAttributes(r.Context(), tracing.AttributeDataStoreUri.String(inputUri))
+
+	var s3_client *s3.S3
+	if inputScheme, _ := grw.SplitUri(inputUri); inputScheme == "s3" {
+		s3_client, err = h.GetAWSS3Client()
+		if err != nil {
+			return errors.Wrap(err, "error connecting to AWS")
+		}
+	}
+
+	passphrase, salt, err := h.Keyring.Lookup(service.DataStore.Key)
+	if err != nil {
+		return errors.Wrap(err, "error resolving key for data store "+service.DataStore.Name)
+	}
+
+	inputReader, _, err := grw.ReadFromResource(inputUri, service.DataStore.Compression, 4096, false, s3_client)
+	if err != nil {
+		return errors.Wrap(err, "error opening resource at uri "+inputUri)
+	}
+	defer inputReader.Close()
+
+	// encrypted data is decrypted while it is read, so the stream is not held in memory.
+	plain, err := util.DecryptStream(inputReader, passphrase, salt, len(service.DataStore.Key) > 0, h.Keyring)
+	if err != nil {
+		return errors.Wrap(err, "error decrypting data from uri "+inputUri)
+	}
+	// next returns the next record of the data store, and false once the data store is exhausted.
+	// Records of csv and tsv data stores are read with a csv reader, so quoted fields can contain new lines.
+	var next func() (interface{}, bool, error)
+	if inputFormat == "jsonl" {
+		lines := bufio.NewReader(plain)
+		options := gss.Options{
+			Format: "json",
+			Limit:  1,
+			Type:   reflect.TypeOf(map[string]interface{}{}),
+		}
+		next = func() (interface{}, bool, error) {
+			for {
+				line, err := lines.ReadBytes('\n')
+				if err != nil && err != io.EOF {
+					return nil, false, errors.Wrap(err, "error reading line from resource")
+				}
+				if len(bytes.TrimSpace(line)) > 0 {
+					inputObject, err := options.DeserializeBytes(line, false)
+					if err != nil {
+						return nil, false, errors.Wrap(err, "error deserializing input using format "+inputFormat)
+					}
+					return inputObject, true, nil
+				}
+				if err == io.EOF {
+					return nil, false, nil
+				}
+			}
+		}
+	} else {
+		csvReader := csv.NewReader(plain)
+		if inputFormat == "tsv" {
+			csvReader.Comma = '\t'
+		}
+		csvReader.FieldsPerRecord = -1
+		header, err := csvReader.Read()
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return errors.Wrap(err, "error reading header from input with format "+inputFormat)
+		}
+		next = func() (interface{}, bool, error) {
+			record, err := csvReader.Read()
+			if err != nil {
+				if err == io.EOF {
+					return nil, false, nil
+				}
+				return nil, false, errors.Wrap(err, "error reading record from input with format "+inputFormat)
+			}
+			inputObject := make(map[string]interface{}, len(header))
+			for i, name := range header {
+				if i < len(record) {
+					inputObject[name] = record[i]
+				}
+			}
+			return inputObject, true, nil
+		}
+	}
+
+	ctx := r.Context()
+	sw := NewStreamWriter(w, format)
+	// the process is evaluated once per record, so the total evaluation time is recorded when the stream ends.
+	evaluation := time.Duration(0)
+	defer func() {
+		h.Metrics.ObserveProcess(service.Process.Name, evaluation)
+	}()
+	for {
+
+		if err := ctx.Err(); err != nil {
+			run.Log(fmt.Sprintf("canceled after %d records", sw.Count))
+			return errors.Wrap(err, "stream canceled")
+		}
+
+		inputObject, ok, err := next()
+		if err != nil {
+			return err
+		}
+		if !ok {
+			break
+		}
+
+		start := time.Now()
+		_, outputObject, err := service.Process.Node.Evaluate(variables, inputObject, dfl.DefaultFunctionMap, dfl.DefaultQuotes)
+		evaluation += time.Since(start)
+		if err != nil {
+			return errors.Wrap(err, "error evaluating process with name "+service.Process.Name)
+		}
+		switch outputObject.(type) {
+		case dfl.Null:
+		default:
+			err = sw.Write(outputObject)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	run.Log(fmt.Sprintf("streamed %d records", sw.Count))
+	return sw.Flush()
+}
+
+func canStream(format string) bool {
+	for _, f := range StreamFormats {
+		if format == f {
+			return true
+		}
+	}
+	return false
 }
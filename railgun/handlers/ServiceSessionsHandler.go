@@ -0,0 +1,198 @@
+// =================================================================
+//
+// Copyright (C) 2018 Spatial Current, Inc. - All Rights Reserved
+// Released as open source under the MIT License.  See LICENSE file.
+//
+// =================================================================
+
+package handlers
+
+import (
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+	"github.com/spatialcurrent/go-try-get/gtg"
+	rerrors "github.com/spatialcurrent/railgun/railgun/errors"
+	"github.com/spatialcurrent/railgun/railgun/parser"
+	"github.com/spatialcurrent/railgun/railgun/session"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+// ServiceSessionsHandler creates and deletes stateful sessions for a service.
+// The variables returned by each exec call within a session are the input variables of the next call.
+type ServiceSessionsHandler struct {
+	*BaseHandler
+	Sessions   *session.Store
+	DefaultTTL time.Duration
+	MaxTTL     time.Duration
+}
+
+func (h *ServiceSessionsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	format, ok := h.NegotiateFormat(w, r)
+	if !ok {
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		obj, err := h.Get(w, r, format, mux.Vars(r))
+		if err != nil {
+			h.SendError(r, err)
+			err = h.RespondWithError(w, err, format)
+			if err != nil {
+				panic(err)
+			}
+		} else {
+			err = h.RespondWithObject(w, http.StatusOK, obj, format)
+			if err != nil {
+				h.SendError(r, err)
+				err = h.RespondWithError(w, err, format)
+				if err != nil {
+					panic(err)
+				}
+			}
+		}
+	case "POST":
+		obj, err := h.Post(w, r, format, mux.Vars(r))
+		if err != nil {
+			h.SendError(r, err)
+			err = h.RespondWithError(w, err, format)
+			if err != nil {
+				panic(err)
+			}
+		} else {
+			err = h.RespondWithObject(w, http.StatusOK, obj, format)
+			if err != nil {
+				h.SendError(r, err)
+				err = h.RespondWithError(w, err, format)
+				if err != nil {
+					panic(err)
+				}
+			}
+		}
+	case "DELETE":
+		obj, err := h.Delete(w, r, format, mux.Vars(r))
+		if err != nil {
+			h.SendError(r, err)
+			err = h.RespondWithError(w, err, format)
+			if err != nil {
+				panic(err)
+			}
+		} else {
+			err = h.RespondWithObject(w, http.StatusOK, obj, format)
+			if err != nil {
+				h.SendError(r, err)
+				err = h.RespondWithError(w, err, format)
+				if err != nil {
+					panic(err)
+				}
+			}
+		}
+	case "OPTIONS":
+	default:
+		err := h.RespondWithNotImplemented(w, format)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+}
+
+func (h *ServiceSessionsHandler) Post(w http.ResponseWriter, r *http.Request, format string, vars map[string]string) (interface{}, error) {
+
+	serviceName, ok := vars["name"]
+	if !ok {
+		return nil, &rerrors.ErrMissingRequiredParameter{Name: "name"}
+	}
+
+	h.Catalog.Lock()
+	_, ok = h.Catalog.GetService(serviceName)
+	h.Catalog.Unlock()
+	if !ok {
+		return nil, &rerrors.ErrMissingObject{Type: "service", Name: serviceName}
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading from request body")
+	}
+
+	ttl := h.DefaultTTL
+	variables := map[string]interface{}{}
+
+	if len(body) > 0 {
+		obj, err := h.ParseBody(r, body, format)
+		if err != nil {
+			return nil, errors.Wrap(err, "error parsing body")
+		}
+
+		if str := gtg.TryGetString(obj, "ttl", ""); len(str) > 0 {
+			ttl, err = time.ParseDuration(str)
+			if err != nil || ttl <= 0 {
+				return nil, &rerrors.ErrInvalidParameter{Name: "ttl", Value: str}
+			}
+		}
+
+		variables, err = parser.ParseMap(obj, "variables")
+		if err != nil {
+			return nil, &rerrors.ErrInvalidParameter{Name: "variables", Value: gtg.TryGetString(obj, "variables", "")}
+		}
+	}
+
+	if h.MaxTTL > 0 && ttl > h.MaxTTL {
+		ttl = h.MaxTTL
+	}
+
+	sess := h.Sessions.Create(serviceName, h.GetUser(r), ttl, variables)
+
+	return sess.Map(), nil
+}
+
+func (h *ServiceSessionsHandler) Get(w http.ResponseWriter, r *http.Request, format string, vars map[string]string) (interface{}, error) {
+
+	serviceName, ok := vars["name"]
+	if !ok {
+		return nil, &rerrors.ErrMissingRequiredParameter{Name: "name"}
+	}
+
+	id, ok := vars["id"]
+	if !ok {
+		return nil, &rerrors.ErrMissingRequiredParameter{Name: "id"}
+	}
+
+	// sessions of other users are reported as missing
+	sess, ok := h.Sessions.Get(id, serviceName, h.GetUser(r))
+	if !ok {
+		return nil, &rerrors.ErrMissingObject{Type: "session", Name: id}
+	}
+
+	// the expiration is extended by each call, so it is read once the current call, if any, is done.
+	sess.Lock()
+	defer sess.Unlock()
+
+	return sess.Map(), nil
+}
+
+func (h *ServiceSessionsHandler) Delete(w http.ResponseWriter, r *http.Request, format string, vars map[string]string) (interface{}, error) {
+
+	serviceName, ok := vars["name"]
+	if !ok {
+		return nil, &rerrors.ErrMissingRequiredParameter{Name: "name"}
+	}
+
+	id, ok := vars["id"]
+	if !ok {
+		return nil, &rerrors.ErrMissingRequiredParameter{Name: "id"}
+	}
+
+	// sessions of other users are reported as missing
+	if _, ok := h.Sessions.Get(id, serviceName, h.GetUser(r)); !ok {
+		return nil, &rerrors.ErrMissingObject{Type: "session", Name: id}
+	}
+
+	h.Sessions.Delete(id)
+
+	return map[string]interface{}{"success": true, "message": "session " + id + " deleted"}, nil
+}
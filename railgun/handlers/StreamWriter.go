@@ -0,0 +1,111 @@
+// =================================================================
+//
+// Copyright (C) 2018 Spatial Current, Inc. - All Rights Reserved
+// Released as open source under the MIT License.  See LICENSE file.
+//
+// =================================================================
+
+package handlers
+
+import (
+	"encoding/csv"
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/spatialcurrent/go-simple-serializer/gss"
+	"github.com/spatialcurrent/railgun/railgun/util"
+	"net/http"
+	"reflect"
+	"sort"
+)
+
+// StreamFormats are the response formats that can be streamed one record at a time.
+var StreamFormats = []string{"csv", "tsv", "jsonl"}
+
+// StreamWriter writes records to a response as jsonl, csv, or tsv.
+// The response is flushed every FlushEvery records, so the response is sent with chunked transfer encoding.
+// The csv and tsv header is the sorted keys of the first record.
+type StreamWriter struct {
+	FlushEvery int
+	Count      int
+	w          http.ResponseWriter
+	format     string
+	csv        *csv.Writer
+	header     []string
+}
+
+func NewStreamWriter(w http.ResponseWriter, format string) *StreamWriter {
+	sw := &StreamWriter{FlushEvery: 100, w: w, format: format}
+	if format == "csv" || format == "tsv" {
+		sw.csv = csv.NewWriter(w)
+		if format == "tsv" {
+			sw.csv.Comma = '\t'
+		}
+	}
+	return sw
+}
+
+func (sw *StreamWriter) Write(obj interface{}) error {
+	if sw.Count == 0 {
+		sw.w.Header().Set("Content-Type", util.ContentType(sw.format))
+	}
+	obj = gss.StringifyMapKeys(obj)
+	if sw.csv != nil {
+		if err := sw.writeRow(obj); err != nil {
+			return err
+		}
+	} else {
+		b, err := gss.SerializeBytes(obj, "json", []string{}, gss.NoLimit)
+		if err != nil {
+			return errors.Wrap(err, "error serializing record")
+		}
+		if _, err := sw.w.Write(append(b, '\n')); err != nil {
+			return errors.Wrap(err, "error writing record")
+		}
+	}
+	sw.Count++
+	if sw.FlushEvery > 0 && sw.Count%sw.FlushEvery == 0 {
+		return sw.Flush()
+	}
+	return nil
+}
+
+func (sw *StreamWriter) writeRow(obj interface{}) error {
+	m, ok := obj.(map[string]interface{})
+	if !ok {
+		return errors.New("record of type " + fmt.Sprint(reflect.TypeOf(obj)) + " cannot be written as " + sw.format)
+	}
+	if sw.header == nil {
+		sw.header = make([]string, 0, len(m))
+		for k := range m {
+			sw.header = append(sw.header, k)
+		}
+		sort.Strings(sw.header)
+		if err := sw.csv.Write(sw.header); err != nil {
+			return errors.Wrap(err, "error writing header")
+		}
+	}
+	row := make([]string, len(sw.header))
+	for i, k := range sw.header {
+		if v, ok := m[k]; ok && v != nil {
+			row[i] = fmt.Sprint(v)
+		}
+	}
+	if err := sw.csv.Write(row); err != nil {
+		return errors.Wrap(err, "error writing record")
+	}
+	return nil
+}
+
+// Flush sends the buffered records to the client.
+func (sw *StreamWriter) Flush() error {
+	if sw.csv != nil {
+		sw.csv.Flush()
+		if err := sw.csv.Error(); err != nil {
+			return errors.Wrap(err, "error writing records")
+		}
+	}
+	if f, ok := sw.w.(http.Flusher); ok {
+		f.Flush()
+	}
+	return nil
+}
@@ -8,15 +8,18 @@
 package handlers
 
 import (
+	"encoding/json"
 	"fmt"
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/pkg/errors"
 	"github.com/spatialcurrent/go-simple-serializer/gss"
 	"github.com/spatialcurrent/go-swagger-structs/swagger"
 	"github.com/spatialcurrent/railgun/railgun/core"
-	"github.com/spatialcurrent/railgun/railgun/util"
+	"github.com/spatialcurrent/railgun/railgun/openapi"
 	"net/http"
 	"net/url"
 	"reflect"
+	"sort"
 	"strings"
 )
 
@@ -211,6 +214,127 @@ func (h *SwaggerHandler) BuildDefinitions() map[string]swagger.Definition {
 	return definitions
 }
 
+func (h *SwaggerHandler) getVariablesDefinitionName(service *core.Service) string {
+	return strings.Title(service.Name) + "Variables"
+}
+
+func (h *SwaggerHandler) getExecRequestDefinitionName(service *core.Service) string {
+	return strings.Title(service.Name) + "ExecRequest"
+}
+
+// BuildServiceDefinitions returns the definitions of the variables and exec requests of services with declared parameters.
+// The definitions are JSON Schemas, since the properties of swagger definitions cannot hold references or the constraints of parameters.
+func (h *SwaggerHandler) BuildServiceDefinitions() map[string]*openapi.Schema {
+	definitions := map[string]*openapi.Schema{}
+	for _, service := range h.Catalog.ListServices() {
+		if len(service.Process.Parameters) == 0 {
+			continue
+		}
+		variables := &openapi.Schema{
+			Type:       "object",
+			Properties: map[string]*openapi.Schema{},
+			Required:   make([]string, 0),
+		}
+		for _, p := range service.Process.Parameters {
+			variables.Properties[p.Name] = parameterSchema(p)
+			if p.Required && p.Default == nil {
+				variables.Required = append(variables.Required, p.Name)
+			}
+		}
+		sort.Strings(variables.Required)
+		definitions[h.getVariablesDefinitionName(service)] = variables
+		definitions[h.getExecRequestDefinitionName(service)] = &openapi.Schema{
+			Type:  "object",
+			Title: service.Title,
+			Properties: map[string]*openapi.Schema{
+				"variables": &openapi.Schema{Ref: "#/definitions/" + h.getVariablesDefinitionName(service), Description: "the input variables, as an object or a DFL dictionary"},
+				"session":   &openapi.Schema{Type: "string", Description: "the id of the session, if not set then the service is executed without state"},
+			},
+		}
+	}
+	return definitions
+}
+
+// describeParameters returns a description of the parameters of a process, for readers of the operation without the definitions.
+func (h *SwaggerHandler) describeParameters(parameters []*core.Parameter) string {
+	lines := make([]string, 0, len(parameters))
+	for _, p := range parameters {
+		details := []string{p.Type}
+		if p.Required && p.Default == nil {
+			details = append(details, "required")
+		}
+		if p.Default != nil {
+			details = append(details, fmt.Sprintf("default %v", p.Default))
+		}
+		if p.Minimum != nil {
+			details = append(details, fmt.Sprintf("minimum %v", *p.Minimum))
+		}
+		if p.Maximum != nil {
+			details = append(details, fmt.Sprintf("maximum %v", *p.Maximum))
+		}
+		if len(p.Values) > 0 {
+			details = append(details, "one of "+strings.Join(p.Values, ", "))
+		}
+		line := p.Name + " (" + strings.Join(details, ", ") + ")"
+		if len(p.Description) > 0 {
+			line += ": " + p.Description
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "; ")
+}
+
+// BuildServicePaths returns an exec path for each service with declared parameters.
+func (h *SwaggerHandler) BuildServicePaths(params map[string]swagger.Parameter) map[string]swagger.Path {
+	paths := map[string]swagger.Path{}
+	for _, service := range h.Catalog.ListServices() {
+		if len(service.Process.Parameters) == 0 {
+			continue
+		}
+		paths["/services/"+service.Name+"/exec.{ext}"] = swagger.Path{
+			Post: swagger.Operation{
+				Description: fmt.Sprintf("execute the %s service on the Railgun Server. %s", service.Name, service.Description),
+				Tags:        []string{"Services"},
+				Consumes: []string{
+					"application/json",
+					"text/yaml",
+					"application/ubjson",
+					"application/toml",
+				},
+				Produces: []string{
+					"application/json",
+					"text/yaml",
+					"application/ubjson",
+					"application/toml",
+				},
+				Parameters: []swagger.Parameter{
+					swagger.Parameter{
+						Name:        "request",
+						Type:        "",
+						Description: fmt.Sprintf("the exec request, with the variables to execute the service with: %s", h.describeParameters(service.Process.Parameters)),
+						In:          "body",
+						Required:    false,
+						Schema: &swagger.Schema{
+							Ref: fmt.Sprintf("#/definitions/%s", h.getExecRequestDefinitionName(service)),
+						},
+					},
+					params["stream"],
+					params["ext"],
+				},
+				Responses: map[string]swagger.Response{
+					"200": swagger.Response{
+						Description: "OK",
+					},
+					"400": swagger.Response{
+						Description: "Bad request.  The errors for each invalid variable are returned in fields.",
+					},
+				},
+			},
+		}
+	}
+	return paths
+}
+
 func (h *SwaggerHandler) BuildSwaggerDocument() (swagger.Document, error) {
 
 	location, err := url.Parse(h.Viper.GetString("http-location"))
@@ -270,6 +394,14 @@ func (h *SwaggerHandler) BuildSwaggerDocument() (swagger.Document, error) {
 			Required:    false,
 			Default:     "",
 		},
+		"stream": swagger.Parameter{
+			Name:        "stream",
+			Type:        "boolean",
+			Description: "Evaluate the process for each record of a csv, tsv, or jsonl data store and stream the results as csv, tsv, or jsonl",
+			In:          "query",
+			Required:    false,
+			Default:     false,
+		},
 		"limit": swagger.Parameter{
 			Name:        "limit",
 			Type:        "integer",
@@ -376,12 +508,16 @@ func (h *SwaggerHandler) BuildSwaggerDocument() (swagger.Document, error) {
 							Ref: fmt.Sprintf("#/definitions/%s", "Job"),
 						},
 					},
+					params["stream"],
 					params["ext"],
 				},
 				Responses: map[string]swagger.Response{
 					"200": swagger.Response{
 						Description: "OK",
 					},
+					"400": swagger.Response{
+						Description: "Bad request.  The variables do not match the parameters declared by the process.",
+					},
 					"404": swagger.Response{
 						Description: fmt.Sprintf("Not found. %s with provided name was not found.", "service"),
 					},
@@ -584,6 +720,10 @@ func (h *SwaggerHandler) BuildSwaggerDocument() (swagger.Document, error) {
 		paths[k] = v
 	}
 
+	for k, v := range h.BuildServicePaths(params) {
+		paths[k] = v
+	}
+
 	var contact *swagger.Contact
 	swaggerContactName := h.Viper.GetString("swagger-contact-name")
 	swaggerContactEmail := h.Viper.GetString("swagger-contact-email")
@@ -627,16 +767,47 @@ func (h *SwaggerHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	swaggerDocument, err := h.BuildSwaggerDocument()
 	if err != nil {
-		h.Messages <- err
+		h.SendError(r, err)
+		return
+	}
+
+	obj, err := h.addDefinitions(swaggerDocument, h.BuildServiceDefinitions())
+	if err != nil {
+		h.SendError(r, err)
 		return
 	}
 
-	_, format, _ := util.SplitNameFormatCompression(r.URL.Path)
-	b, err := gss.SerializeBytes(swaggerDocument, format, []string{}, -1)
+	format, ok := h.NegotiateFormat(w, r)
+	if !ok {
+		return
+	}
+	b, err := gss.SerializeBytes(obj, format, []string{}, -1)
 	if err != nil {
-		h.Messages <- err
+		h.SendError(r, err)
 		return
 	}
 	w.Write(b)
 
 }
+
+// addDefinitions returns the document as a map with the definitions added.
+func (h *SwaggerHandler) addDefinitions(doc swagger.Document, definitions map[string]*openapi.Schema) (map[string]interface{}, error) {
+	b, err := json.Marshal(doc)
+	if err != nil {
+		return nil, errors.Wrap(err, "error marshaling swagger document")
+	}
+	m := map[string]interface{}{}
+	err = json.Unmarshal(b, &m)
+	if err != nil {
+		return nil, errors.Wrap(err, "error unmarshaling swagger document")
+	}
+	existing, ok := m["definitions"].(map[string]interface{})
+	if !ok {
+		existing = map[string]interface{}{}
+	}
+	for name, d := range definitions {
+		existing[name] = d
+	}
+	m["definitions"] = existing
+	return m, nil
+}
@@ -0,0 +1,45 @@
+// =================================================================
+//
+// Copyright (C) 2018 Spatial Current, Inc. - All Rights Reserved
+// Released as open source under the MIT License.  See LICENSE file.
+//
+// =================================================================
+
+package health
+
+import (
+	"time"
+)
+
+const (
+	StatusOk   = "ok"
+	StatusFail = "fail"
+	StatusSkip = "skip"
+)
+
+// Check is the result of a readiness check.
+// A skipped check, e.g., for a data store with a uri that depends on variables, does not fail readiness.
+type Check struct {
+	Name     string
+	Status   string
+	Message  string
+	Duration time.Duration
+	Checked  time.Time
+}
+
+func (c *Check) Ok() bool {
+	return c.Status != StatusFail
+}
+
+func (c *Check) Map() map[string]interface{} {
+	m := map[string]interface{}{
+		"name":     c.Name,
+		"status":   c.Status,
+		"duration": c.Duration.Seconds(),
+		"checked":  c.Checked.Format(time.RFC3339),
+	}
+	if len(c.Message) > 0 {
+		m["message"] = c.Message
+	}
+	return m
+}
@@ -0,0 +1,105 @@
+// =================================================================
+//
+// Copyright (C) 2018 Spatial Current, Inc. - All Rights Reserved
+// Released as open source under the MIT License.  See LICENSE file.
+//
+// =================================================================
+
+package health
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"sync"
+	"time"
+)
+
+// Checker runs readiness checks with a timeout and caches the results for TTL,
+// so frequent probes do not put load on data stores.
+type Checker struct {
+	Timeout time.Duration
+	TTL     time.Duration
+	mutex   *sync.Mutex
+	results map[string]*Check
+}
+
+func NewChecker(timeout time.Duration, ttl time.Duration) *Checker {
+	return &Checker{
+		Timeout: timeout,
+		TTL:     ttl,
+		mutex:   &sync.Mutex{},
+		results: map[string]*Check{},
+	}
+}
+
+// Run returns the cached result of the check with the given name, or runs the check if the cached result has expired.
+// The check runs on a background context with the timeout, so a probe that is canceled does not cache a failure;
+// the caller stops waiting once its context is done, while the check completes and caches its result for the next probe.
+// The check fails if the function does not return before the timeout.
+// The function may keep running after the timeout, so it should also respect the context.
+func (c *Checker) Run(ctx context.Context, name string, f func(ctx context.Context) error) *Check {
+
+	c.mutex.Lock()
+	if result, ok := c.results[name]; ok && time.Since(result.Checked) < c.TTL {
+		c.mutex.Unlock()
+		return result
+	}
+	c.mutex.Unlock()
+
+	done := make(chan *Check, 1)
+	go func() {
+		done <- c.run(name, f)
+	}()
+	select {
+	case result := <-done:
+		return result
+	case <-ctx.Done():
+		return &Check{
+			Name:    name,
+			Status:  StatusFail,
+			Message: errors.Wrap(ctx.Err(), "request did not wait for check").Error(),
+			Checked: time.Now(),
+		}
+	}
+}
+
+// run runs the check on a background context and caches the result.
+func (c *Checker) run(name string, f func(ctx context.Context) error) *Check {
+
+	ctx := context.Background()
+	if c.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
+
+	result := &Check{Name: name, Checked: time.Now()}
+	done := make(chan error, 1)
+	go func() {
+		done <- f(ctx)
+	}()
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = errors.Wrap(ctx.Err(), "check did not complete")
+	}
+	result.Duration = time.Since(result.Checked)
+
+	switch e := err.(type) {
+	case nil:
+		result.Status = StatusOk
+	case *ErrSkip:
+		result.Status = StatusSkip
+		result.Message = e.Reason
+	default:
+		result.Status = StatusFail
+		result.Message = err.Error()
+	}
+
+	c.mutex.Lock()
+	c.results[name] = result
+	c.mutex.Unlock()
+
+	return result
+}
@@ -0,0 +1,17 @@
+// =================================================================
+//
+// Copyright (C) 2018 Spatial Current, Inc. - All Rights Reserved
+// Released as open source under the MIT License.  See LICENSE file.
+//
+// =================================================================
+
+package health
+
+// ErrSkip is returned by a check to skip the check, e.g., when a data store uri depends on variables.
+type ErrSkip struct {
+	Reason string
+}
+
+func (e *ErrSkip) Error() string {
+	return e.Reason
+}
@@ -0,0 +1,33 @@
+// =================================================================
+//
+// Copyright (C) 2018 Spatial Current, Inc. - All Rights Reserved
+// Released as open source under the MIT License.  See LICENSE file.
+//
+// =================================================================
+
+package keyring
+
+import (
+	"time"
+)
+
+// DataKey is a key that encrypts files, stored wrapped by a master key.
+// The id of the data key is written in the header of each file, so the key is selected automatically on decrypt.
+// The primary data key of a master key is used for new files.
+type DataKey struct {
+	Id        string
+	MasterKey string
+	Wrapped   []byte
+	Created   time.Time
+	Primary   bool
+}
+
+// Map returns the data key without its wrapped material.
+func (dk *DataKey) Map() map[string]interface{} {
+	return map[string]interface{}{
+		"id":         dk.Id,
+		"master_key": dk.MasterKey,
+		"created":    dk.Created.Format(time.RFC3339),
+		"primary":    dk.Primary,
+	}
+}
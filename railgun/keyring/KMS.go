@@ -0,0 +1,20 @@
+// =================================================================
+//
+// Copyright (C) 2018 Spatial Current, Inc. - All Rights Reserved
+// Released as open source under the MIT License.  See LICENSE file.
+//
+// =================================================================
+
+package keyring
+
+// KMS wraps and unwraps data keys with named master keys.
+// The interface mirrors the GenerateDataKey, Decrypt, and ReEncrypt operations of a cloud key management service,
+// so a wrapped data key is an opaque blob that identifies the master key that wrapped it.
+type KMS interface {
+	// GenerateDataKey returns a new 256-bit data key and the data key wrapped by the current version of the master key.
+	GenerateDataKey(masterKey string) ([]byte, []byte, error)
+	// Decrypt returns the data key that was wrapped by any version of a master key.
+	Decrypt(wrapped []byte) ([]byte, error)
+	// ReEncrypt returns the data key wrapped by the current version of the master key.
+	ReEncrypt(wrapped []byte, masterKey string) ([]byte, error)
+}
@@ -0,0 +1,16 @@
+// =================================================================
+//
+// Copyright (C) 2018 Spatial Current, Inc. - All Rights Reserved
+// Released as open source under the MIT License.  See LICENSE file.
+//
+// =================================================================
+
+package keyring
+
+// Key is a passphrase and salt used to derive the AES-256 key of encrypted data.
+// The salt is hex encoded.
+type Key struct {
+	Name       string
+	Passphrase string
+	Salt       string
+}
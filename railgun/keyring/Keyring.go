@@ -0,0 +1,543 @@
+// =================================================================
+//
+// Copyright (C) 2018 Spatial Current, Inc. - All Rights Reserved
+// Released as open source under the MIT License.  See LICENSE file.
+//
+// =================================================================
+
+package keyring
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"github.com/mitchellh/go-homedir"
+	"github.com/pkg/errors"
+	"github.com/spatialcurrent/go-simple-serializer/gss"
+	"github.com/spatialcurrent/go-try-get/gtg"
+	rerrors "github.com/spatialcurrent/railgun/railgun/errors"
+	"github.com/spatialcurrent/railgun/railgun/parser"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var envUnsafe = regexp.MustCompile("[^A-Z0-9]+")
+
+const (
+	keyMasterKeys = "master_keys"
+	keyDataKeys   = "data_keys"
+)
+
+// Keyring resolves the keys referenced by data stores, so secrets are never stored in the catalog.
+// Keys are read from a local keyring file, e.g., {parcels: {passphrase: "...", salt: "..."}},
+// and from environment variables named RAILGUN_KEY_<NAME>_PASSPHRASE and RAILGUN_KEY_<NAME>_SALT,
+// where the name is upper case with other characters replaced by underscores.
+// Environment variables take precedence over the keyring file.
+//
+// The keyring file also holds the master keys and the data keys wrapped by them in the master_keys and data_keys entries.
+// Data keys are unwrapped by the KMS, which defaults to a LocalKMS using the master keys in the keyring.
+type Keyring struct {
+	KMS        KMS
+	mutex      *sync.RWMutex
+	keys       map[string]*Key
+	masterKeys map[string][]*MasterKey // versions in ascending order
+	dataKeys   map[string]*DataKey
+	unwrapped  map[string][]byte
+}
+
+func NewKeyring() *Keyring {
+	k := &Keyring{
+		mutex:      &sync.RWMutex{},
+		keys:       map[string]*Key{},
+		masterKeys: map[string][]*MasterKey{},
+		dataKeys:   map[string]*DataKey{},
+		unwrapped:  map[string][]byte{},
+	}
+	k.KMS = &LocalKMS{Keyring: k}
+	return k
+}
+
+// Open returns the keyring in the file at the path, or an empty keyring if the file does not exist, so keys can be created.
+func Open(path string) (*Keyring, error) {
+	pathExpanded, err := homedir.Expand(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "error expanding path "+path)
+	}
+	if _, err := os.Stat(pathExpanded); os.IsNotExist(err) {
+		return NewKeyring(), nil
+	}
+	return Load(path)
+}
+
+// Load returns a keyring with the keys in the file at the path.
+// The format of the file is inferred from its extension and defaults to yaml.
+func Load(path string) (*Keyring, error) {
+	pathExpanded, err := homedir.Expand(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "error expanding path "+path)
+	}
+	b, err := ioutil.ReadFile(pathExpanded)
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading keyring at "+path)
+	}
+
+	format := strings.TrimPrefix(filepath.Ext(pathExpanded), ".")
+	if format != "json" && format != "toml" {
+		format = "yaml"
+	}
+
+	k := NewKeyring()
+	if len(strings.TrimSpace(string(b))) == 0 {
+		return k, nil
+	}
+
+	t, err := gss.GetType(b, format)
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading keyring at "+path)
+	}
+	obj, err := gss.DeserializeBytes(b, format, gss.NoHeader, "", false, gss.NoSkip, gss.NoLimit, t, false)
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading keyring at "+path)
+	}
+	m, ok := gss.StringifyMapKeys(obj).(map[string]interface{})
+	if !ok {
+		return nil, errors.New("keyring at " + path + " is not a map of key name to passphrase and salt")
+	}
+	for name, value := range m {
+		switch name {
+		case keyMasterKeys:
+			if err := k.loadMasterKeys(value); err != nil {
+				return nil, errors.Wrap(err, "error reading keyring at "+path)
+			}
+		case keyDataKeys:
+			if err := k.loadDataKeys(value); err != nil {
+				return nil, errors.Wrap(err, "error reading keyring at "+path)
+			}
+		default:
+			k.keys[name] = &Key{
+				Name:       name,
+				Passphrase: gtg.TryGetString(value, "passphrase", ""),
+				Salt:       gtg.TryGetString(value, "salt", ""),
+			}
+		}
+	}
+	return k, nil
+}
+
+func (k *Keyring) loadMasterKeys(obj interface{}) error {
+	m, ok := obj.(map[string]interface{})
+	if !ok {
+		return errors.New(keyMasterKeys + " is not a map of name to versions")
+	}
+	for name, value := range m {
+		versions, ok := value.([]interface{})
+		if !ok {
+			return errors.New("master key " + name + " is not a list of versions")
+		}
+		for _, v := range versions {
+			material, err := base64.StdEncoding.DecodeString(gtg.TryGetString(v, "key", ""))
+			if err != nil || len(material) != 32 {
+				return errors.New("master key " + name + " has an invalid key")
+			}
+			version, err := strconv.Atoi(fmt.Sprint(gtg.TryGet(v, "version", "")))
+			if err != nil || version < 1 {
+				return errors.New("master key " + name + " has an invalid version")
+			}
+			created, _ := time.Parse(time.RFC3339, gtg.TryGetString(v, "created", ""))
+			k.masterKeys[name] = append(k.masterKeys[name], &MasterKey{
+				Name:     name,
+				Version:  version,
+				Material: material,
+				Created:  created,
+			})
+		}
+		sort.Slice(k.masterKeys[name], func(i, j int) bool {
+			return k.masterKeys[name][i].Version < k.masterKeys[name][j].Version
+		})
+	}
+	return nil
+}
+
+func (k *Keyring) loadDataKeys(obj interface{}) error {
+	m, ok := obj.(map[string]interface{})
+	if !ok {
+		return errors.New(keyDataKeys + " is not a map of id to data key")
+	}
+	for id, value := range m {
+		wrapped, err := base64.StdEncoding.DecodeString(gtg.TryGetString(value, "wrapped", ""))
+		if err != nil || len(wrapped) == 0 {
+			return errors.New("data key " + id + " has an invalid wrapped key")
+		}
+		primary, err := parser.ParseBool(value, "primary")
+		if err != nil {
+			return errors.Wrap(err, "data key "+id+" is invalid")
+		}
+		created, _ := time.Parse(time.RFC3339, gtg.TryGetString(value, "created", ""))
+		k.dataKeys[id] = &DataKey{
+			Id:        id,
+			MasterKey: gtg.TryGetString(value, "master_key", ""),
+			Wrapped:   wrapped,
+			Created:   created,
+			Primary:   primary,
+		}
+	}
+	return nil
+}
+
+// Save writes the keyring to the file at the path, which is only readable by the current user.
+// The file is written to a temporary file first, so the keyring is never left half written.
+// The format of the file is inferred from its extension and defaults to yaml.
+func (k *Keyring) Save(path string) error {
+	pathExpanded, err := homedir.Expand(path)
+	if err != nil {
+		return errors.Wrap(err, "error expanding path "+path)
+	}
+
+	format := strings.TrimPrefix(filepath.Ext(pathExpanded), ".")
+	if format != "json" && format != "toml" {
+		format = "yaml"
+	}
+
+	k.mutex.RLock()
+	obj := map[string]interface{}{}
+	for name, key := range k.keys {
+		obj[name] = map[string]interface{}{"passphrase": key.Passphrase, "salt": key.Salt}
+	}
+	if len(k.masterKeys) > 0 {
+		masterKeys := map[string]interface{}{}
+		for name, versions := range k.masterKeys {
+			values := make([]interface{}, 0, len(versions))
+			for _, mk := range versions {
+				values = append(values, map[string]interface{}{
+					"version": mk.Version,
+					"key":     base64.StdEncoding.EncodeToString(mk.Material),
+					"created": mk.Created.Format(time.RFC3339),
+				})
+			}
+			masterKeys[name] = values
+		}
+		obj[keyMasterKeys] = masterKeys
+	}
+	if len(k.dataKeys) > 0 {
+		dataKeys := map[string]interface{}{}
+		for id, dk := range k.dataKeys {
+			dataKeys[id] = map[string]interface{}{
+				"master_key": dk.MasterKey,
+				"wrapped":    base64.StdEncoding.EncodeToString(dk.Wrapped),
+				"created":    dk.Created.Format(time.RFC3339),
+				"primary":    dk.Primary,
+			}
+		}
+		obj[keyDataKeys] = dataKeys
+	}
+	k.mutex.RUnlock()
+
+	b, err := gss.SerializeBytes(obj, format, gss.NoHeader, gss.NoLimit)
+	if err != nil {
+		return errors.Wrap(err, "error serializing keyring")
+	}
+
+	if err := os.MkdirAll(filepath.Dir(pathExpanded), 0700); err != nil {
+		return errors.Wrap(err, "error creating directory for "+path)
+	}
+	tmp := pathExpanded + ".tmp"
+	if err := ioutil.WriteFile(tmp, b, 0600); err != nil {
+		return errors.Wrap(err, "error writing keyring to "+path)
+	}
+	if err := os.Rename(tmp, pathExpanded); err != nil {
+		os.Remove(tmp)
+		return errors.Wrap(err, "error writing keyring to "+path)
+	}
+	return nil
+}
+
+// MasterKey returns the version of the named master key.  If the version is 0, then the current version is returned.
+func (k *Keyring) MasterKey(name string, version int) (*MasterKey, error) {
+	if k == nil {
+		return nil, &rerrors.ErrMissingKey{Name: name}
+	}
+	k.mutex.RLock()
+	defer k.mutex.RUnlock()
+	versions := k.masterKeys[name]
+	if len(versions) == 0 {
+		return nil, &rerrors.ErrMissingKey{Name: name}
+	}
+	if version == 0 {
+		return versions[len(versions)-1], nil
+	}
+	for _, mk := range versions {
+		if mk.Version == version {
+			return mk, nil
+		}
+	}
+	return nil, &rerrors.ErrMissingKey{Name: name + " version " + fmt.Sprint(version)}
+}
+
+// HasMasterKey returns true if the keyring has a master key with the given name.
+func (k *Keyring) HasMasterKey(name string) bool {
+	if k == nil {
+		return false
+	}
+	k.mutex.RLock()
+	defer k.mutex.RUnlock()
+	return len(k.masterKeys[name]) > 0
+}
+
+// MasterKeys returns every version of every master key sorted by name and version.
+func (k *Keyring) MasterKeys() []*MasterKey {
+	masterKeys := make([]*MasterKey, 0)
+	if k == nil {
+		return masterKeys
+	}
+	k.mutex.RLock()
+	defer k.mutex.RUnlock()
+	for _, versions := range k.masterKeys {
+		masterKeys = append(masterKeys, versions...)
+	}
+	sort.Slice(masterKeys, func(i, j int) bool {
+		if masterKeys[i].Name != masterKeys[j].Name {
+			return masterKeys[i].Name < masterKeys[j].Name
+		}
+		return masterKeys[i].Version < masterKeys[j].Version
+	})
+	return masterKeys
+}
+
+// DataKeys returns the data keys sorted by master key and creation time.
+func (k *Keyring) DataKeys() []*DataKey {
+	dataKeys := make([]*DataKey, 0)
+	if k == nil {
+		return dataKeys
+	}
+	k.mutex.RLock()
+	defer k.mutex.RUnlock()
+	for _, dk := range k.dataKeys {
+		dataKeys = append(dataKeys, dk)
+	}
+	sort.Slice(dataKeys, func(i, j int) bool {
+		if dataKeys[i].MasterKey != dataKeys[j].MasterKey {
+			return dataKeys[i].MasterKey < dataKeys[j].MasterKey
+		}
+		if !dataKeys[i].Created.Equal(dataKeys[j].Created) {
+			return dataKeys[i].Created.Before(dataKeys[j].Created)
+		}
+		return dataKeys[i].Id < dataKeys[j].Id
+	})
+	return dataKeys
+}
+
+// CreateMasterKey creates a master key with a random 256-bit key and its first data key.
+func (k *Keyring) CreateMasterKey(name string) (*MasterKey, *DataKey, error) {
+	if len(name) == 0 {
+		return nil, nil, errors.New("name of master key is blank")
+	}
+	if name == keyMasterKeys || name == keyDataKeys {
+		return nil, nil, errors.New("name of master key " + name + " is reserved")
+	}
+	if k.HasMasterKey(name) {
+		return nil, nil, errors.New("master key " + name + " already exists")
+	}
+	mk, err := k.addMasterKeyVersion(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	dk, err := k.CreateDataKey(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	return mk, dk, nil
+}
+
+func (k *Keyring) addMasterKeyVersion(name string) (*MasterKey, error) {
+	material := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, material); err != nil {
+		return nil, errors.Wrap(err, "error generating master key")
+	}
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+	mk := &MasterKey{Name: name, Version: len(k.masterKeys[name]) + 1, Material: material, Created: time.Now().UTC()}
+	k.masterKeys[name] = append(k.masterKeys[name], mk)
+	return mk, nil
+}
+
+// CreateDataKey creates a data key wrapped by the master key and makes it the primary data key of the master key,
+// so it is used for new files.  The previous data keys are kept to decrypt existing files.
+func (k *Keyring) CreateDataKey(masterKey string) (*DataKey, error) {
+	key, wrapped, err := k.KMS.GenerateDataKey(masterKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "error generating data key with master key "+masterKey)
+	}
+	suffix := make([]byte, 4)
+	if _, err := io.ReadFull(rand.Reader, suffix); err != nil {
+		return nil, errors.Wrap(err, "error generating id of data key")
+	}
+	dk := &DataKey{
+		Id:        masterKey + "-" + hex.EncodeToString(suffix),
+		MasterKey: masterKey,
+		Wrapped:   wrapped,
+		Created:   time.Now().UTC(),
+		Primary:   true,
+	}
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+	for _, other := range k.dataKeys {
+		if other.MasterKey == masterKey {
+			other.Primary = false
+		}
+	}
+	k.dataKeys[dk.Id] = dk
+	k.unwrapped[dk.Id] = key
+	return dk, nil
+}
+
+// RotateMasterKey creates a new version of the master key and wraps every data key of the master key with it.
+// Files are not re-encrypted, since their data keys do not change.
+// Returns the new version and the number of data keys that were wrapped again.
+func (k *Keyring) RotateMasterKey(name string) (*MasterKey, int, error) {
+	if !k.HasMasterKey(name) {
+		return nil, 0, &rerrors.ErrMissingKey{Name: name}
+	}
+	mk, err := k.addMasterKeyVersion(name)
+	if err != nil {
+		return nil, 0, err
+	}
+	count := 0
+	for _, dk := range k.DataKeys() {
+		if dk.MasterKey != name {
+			continue
+		}
+		wrapped, err := k.KMS.ReEncrypt(dk.Wrapped, name)
+		if err != nil {
+			return nil, count, errors.Wrap(err, "error wrapping data key "+dk.Id)
+		}
+		k.mutex.Lock()
+		dk.Wrapped = wrapped
+		k.mutex.Unlock()
+		count++
+	}
+	return mk, count, nil
+}
+
+// DataKey returns the unwrapped data key with the given id, so the keyring can resolve the keys of encrypted files.
+// Unwrapped data keys are kept in memory, so the KMS is only called once for each data key.
+func (k *Keyring) DataKey(id string) ([]byte, error) {
+	if k == nil {
+		return nil, &rerrors.ErrMissingKey{Name: id}
+	}
+	k.mutex.RLock()
+	key, ok := k.unwrapped[id]
+	dk := k.dataKeys[id]
+	k.mutex.RUnlock()
+	if ok {
+		return key, nil
+	}
+	if dk == nil {
+		return nil, &rerrors.ErrMissingKey{Name: id}
+	}
+	key, err := k.KMS.Decrypt(dk.Wrapped)
+	if err != nil {
+		return nil, errors.Wrap(err, "error unwrapping data key "+id)
+	}
+	k.mutex.Lock()
+	k.unwrapped[id] = key
+	k.mutex.Unlock()
+	return key, nil
+}
+
+// PrimaryDataKey returns the id and unwrapped key of the primary data key of the master key.
+func (k *Keyring) PrimaryDataKey(masterKey string) (string, []byte, error) {
+	if k == nil {
+		return "", nil, &rerrors.ErrMissingKey{Name: masterKey}
+	}
+	id := ""
+	k.mutex.RLock()
+	for _, dk := range k.dataKeys {
+		if dk.MasterKey == masterKey && dk.Primary {
+			id = dk.Id
+			break
+		}
+	}
+	k.mutex.RUnlock()
+	if len(id) == 0 {
+		return "", nil, &rerrors.ErrMissingKey{Name: masterKey}
+	}
+	key, err := k.DataKey(id)
+	if err != nil {
+		return "", nil, err
+	}
+	return id, key, nil
+}
+
+// Get returns the key with the given name.
+func (k *Keyring) Get(name string) (*Key, error) {
+	key := &Key{Name: name}
+	if k != nil {
+		k.mutex.RLock()
+		v, ok := k.keys[name]
+		k.mutex.RUnlock()
+		if ok {
+			key.Passphrase = v.Passphrase
+			key.Salt = v.Salt
+		}
+	}
+	prefix := "RAILGUN_KEY_" + envName(name)
+	if passphrase, ok := os.LookupEnv(prefix + "_PASSPHRASE"); ok {
+		key.Passphrase = passphrase
+	}
+	if salt, ok := os.LookupEnv(prefix + "_SALT"); ok {
+		key.Salt = salt
+	}
+	if len(key.Passphrase) == 0 {
+		return nil, &rerrors.ErrMissingKey{Name: name}
+	}
+	return key, nil
+}
+
+// Lookup returns the passphrase and salt of the key with the given name,
+// or blank strings if the name is blank, since the data is not encrypted,
+// or if the name is a master key, since the data key is selected by the header of the data.
+// Callers still pass whether a key is declared when decrypting, so data that is not encrypted is rejected.
+func (k *Keyring) Lookup(name string) (string, string, error) {
+	if len(name) == 0 || k.HasMasterKey(name) {
+		return "", "", nil
+	}
+	key, err := k.Get(name)
+	if err != nil {
+		return "", "", err
+	}
+	return key.Passphrase, key.Salt, nil
+}
+
+// Names returns the sorted names of the keys in the keyring file.
+// Keys only in the environment are not included.
+func (k *Keyring) Names() []string {
+	if k == nil {
+		return make([]string, 0)
+	}
+	k.mutex.RLock()
+	defer k.mutex.RUnlock()
+	names := make([]string, 0, len(k.keys))
+	for name := range k.keys {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func envName(name string) string {
+	return strings.Trim(envUnsafe.ReplaceAllString(strings.ToUpper(name), "_"), "_")
+}
+
+func (k *Keyring) String() string {
+	// never print the secrets
+	return fmt.Sprintf("Keyring(%s)", strings.Join(k.Names(), ", "))
+}
@@ -0,0 +1,107 @@
+// =================================================================
+//
+// Copyright (C) 2018 Spatial Current, Inc. - All Rights Reserved
+// Released as open source under the MIT License.  See LICENSE file.
+//
+// =================================================================
+
+package keyring
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"github.com/pkg/errors"
+	"io"
+	"math"
+)
+
+// LocalKMS is a KMS backed by the master keys in a keyring.
+// A wrapped data key is the length of the name of the master key (1 byte), the name, the version (4 bytes),
+// the nonce, and the data key sealed with AES-256-GCM using the name and version as additional data.
+type LocalKMS struct {
+	Keyring *Keyring
+}
+
+func (l *LocalKMS) GenerateDataKey(masterKey string) ([]byte, []byte, error) {
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, nil, errors.Wrap(err, "error generating data key")
+	}
+	wrapped, err := l.wrap(key, masterKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	return key, wrapped, nil
+}
+
+func (l *LocalKMS) Decrypt(wrapped []byte) ([]byte, error) {
+	if len(wrapped) < 1 || len(wrapped) < 1+int(wrapped[0])+4 {
+		return nil, errors.New("wrapped data key is invalid")
+	}
+	n := 1 + int(wrapped[0]) + 4
+	name := string(wrapped[1 : n-4])
+	version := int(binary.BigEndian.Uint32(wrapped[n-4 : n]))
+
+	mk, err := l.Keyring.MasterKey(name, version)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newGCM(mk.Material)
+	if err != nil {
+		return nil, err
+	}
+	if len(wrapped) < n+aead.NonceSize() {
+		return nil, errors.New("wrapped data key is invalid")
+	}
+	key, err := aead.Open(nil, wrapped[n:n+aead.NonceSize()], wrapped[n+aead.NonceSize():], wrapped[:n])
+	if err != nil {
+		return nil, errors.New("error unwrapping data key with master key " + name + ": the master key is wrong or the data key was modified")
+	}
+	return key, nil
+}
+
+func (l *LocalKMS) ReEncrypt(wrapped []byte, masterKey string) ([]byte, error) {
+	key, err := l.Decrypt(wrapped)
+	if err != nil {
+		return nil, err
+	}
+	return l.wrap(key, masterKey)
+}
+
+func (l *LocalKMS) wrap(key []byte, masterKey string) ([]byte, error) {
+	if len(masterKey) > math.MaxUint8 {
+		return nil, errors.New("name of master key is longer than 255 bytes")
+	}
+	mk, err := l.Keyring.MasterKey(masterKey, 0)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newGCM(mk.Material)
+	if err != nil {
+		return nil, err
+	}
+	header := make([]byte, 0, 1+len(masterKey)+4)
+	header = append(header, uint8(len(masterKey)))
+	header = append(header, masterKey...)
+	header = append(header, byte(mk.Version>>24), byte(mk.Version>>16), byte(mk.Version>>8), byte(mk.Version))
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, errors.Wrap(err, "error generating nonce")
+	}
+	wrapped := append(header, nonce...)
+	return aead.Seal(wrapped, nonce, key, header), nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating new AES256 cipher")
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating GCM cipher")
+	}
+	return aead, nil
+}
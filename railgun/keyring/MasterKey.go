@@ -0,0 +1,30 @@
+// =================================================================
+//
+// Copyright (C) 2018 Spatial Current, Inc. - All Rights Reserved
+// Released as open source under the MIT License.  See LICENSE file.
+//
+// =================================================================
+
+package keyring
+
+import (
+	"time"
+)
+
+// MasterKey is a version of a named master key that wraps data keys.
+// The versions of a master key are kept after rotation, so data keys wrapped by an old version can still be unwrapped.
+type MasterKey struct {
+	Name     string
+	Version  int
+	Material []byte
+	Created  time.Time
+}
+
+// Map returns the master key without its material.
+func (mk *MasterKey) Map() map[string]interface{} {
+	return map[string]interface{}{
+		"name":    mk.Name,
+		"version": mk.Version,
+		"created": mk.Created.Format(time.RFC3339),
+	}
+}
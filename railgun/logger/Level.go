@@ -0,0 +1,34 @@
+// =================================================================
+//
+// Copyright (C) 2018 Spatial Current, Inc. - All Rights Reserved
+// Released as open source under the MIT License.  See LICENSE file.
+//
+// =================================================================
+
+package logger
+
+import (
+	"github.com/pkg/errors"
+	"strings"
+)
+
+// Log levels in order of severity.  A logger at a level logs messages at that level or more severe.
+const (
+	LevelDebug = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+	LevelNone
+)
+
+var Levels = []string{"debug", "info", "warn", "error", "none"}
+
+// ParseLevel returns the log level with the given name.
+func ParseLevel(str string) (int, error) {
+	for i, name := range Levels {
+		if strings.ToLower(strings.TrimSpace(str)) == name {
+			return i, nil
+		}
+	}
+	return LevelNone, errors.New("unknown log level " + str + ", expecting one of " + strings.Join(Levels, ", "))
+}
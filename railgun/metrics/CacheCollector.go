@@ -0,0 +1,85 @@
+// =================================================================
+//
+// Copyright (C) 2018 Spatial Current, Inc. - All Rights Reserved
+// Released as open source under the MIT License.  See LICENSE file.
+//
+// =================================================================
+
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/spatialcurrent/railgun/railgun/catalog"
+)
+
+var (
+	descCacheItems = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "cache", "items"),
+		"The number of items in the cache of a layer.",
+		[]string{"layer"}, nil)
+	descCacheBytes = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "cache", "bytes"),
+		"The size in bytes of the items in the cache of a layer.",
+		[]string{"layer"}, nil)
+	descCacheEvictions = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "cache", "evictions_total"),
+		"The number of items evicted from the cache of a layer to make room for other items.",
+		[]string{"layer"}, nil)
+	descCacheExpirations = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "cache", "expirations_total"),
+		"The number of items of a layer that expired from the cache.",
+		[]string{"layer"}, nil)
+	descCacheHits = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "cache", "hits_total"),
+		"The number of reads of a layer found in the cache.",
+		[]string{"layer"}, nil)
+	descCacheMisses = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "cache", "misses_total"),
+		"The number of reads of a layer not found in the cache.",
+		[]string{"layer"}, nil)
+	descCacheMaxBytes = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "cache", "max_bytes"),
+		"The byte budget of the cache shared by layers, or zero if unbounded.",
+		nil, nil)
+)
+
+// CacheCollector collects the statistics of the caches of the layers in the catalog when scraped,
+// since layers can be added and removed while the server is running.
+type CacheCollector struct {
+	Catalog *catalog.RailgunCatalog
+}
+
+func NewCacheCollector(railgunCatalog *catalog.RailgunCatalog) *CacheCollector {
+	return &CacheCollector{Catalog: railgunCatalog}
+}
+
+func (c *CacheCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- descCacheItems
+	ch <- descCacheBytes
+	ch <- descCacheEvictions
+	ch <- descCacheExpirations
+	ch <- descCacheHits
+	ch <- descCacheMisses
+	ch <- descCacheMaxBytes
+}
+
+func (c *CacheCollector) Collect(ch chan<- prometheus.Metric) {
+	c.Catalog.Lock()
+	layers := c.Catalog.ListLayers()
+	c.Catalog.Unlock()
+	for _, layer := range layers {
+		if layer.Cache == nil {
+			continue
+		}
+		stats := layer.Cache.Stats()
+		ch <- prometheus.MustNewConstMetric(descCacheItems, prometheus.GaugeValue, float64(stats.Items), layer.Name)
+		ch <- prometheus.MustNewConstMetric(descCacheBytes, prometheus.GaugeValue, float64(stats.Bytes), layer.Name)
+		ch <- prometheus.MustNewConstMetric(descCacheEvictions, prometheus.CounterValue, float64(stats.Evictions), layer.Name)
+		ch <- prometheus.MustNewConstMetric(descCacheExpirations, prometheus.CounterValue, float64(stats.Expirations), layer.Name)
+		ch <- prometheus.MustNewConstMetric(descCacheHits, prometheus.CounterValue, float64(stats.Hits), layer.Name)
+		ch <- prometheus.MustNewConstMetric(descCacheMisses, prometheus.CounterValue, float64(stats.Misses), layer.Name)
+	}
+	if c.Catalog.Cache != nil {
+		ch <- prometheus.MustNewConstMetric(descCacheMaxBytes, prometheus.GaugeValue, float64(c.Catalog.Cache.MaxBytes()))
+	}
+}
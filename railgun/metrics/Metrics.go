@@ -0,0 +1,162 @@
+// =================================================================
+//
+// Copyright (C) 2018 Spatial Current, Inc. - All Rights Reserved
+// Released as open source under the MIT License.  See LICENSE file.
+//
+// =================================================================
+
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/spatialcurrent/go-reader-writer/grw"
+	"github.com/spatialcurrent/railgun/railgun/catalog"
+	"github.com/spatialcurrent/railgun/railgun/request"
+	"github.com/spatialcurrent/railgun/railgun/runs"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const namespace = "railgun"
+
+// Metrics are the Prometheus metrics of the server.
+// The methods of a nil *Metrics do nothing, so metrics can be disabled by leaving them nil.
+type Metrics struct {
+	Registry              *prometheus.Registry
+	Requests              *prometheus.CounterVec
+	RequestDuration       *prometheus.HistogramVec
+	TileFeatures          *prometheus.HistogramVec
+	DataStoreReadDuration *prometheus.HistogramVec
+	ProcessDuration       *prometheus.HistogramVec
+	mutex                 *sync.Mutex
+	activeRuns            map[string]struct{}
+}
+
+func NewMetrics(railgunCatalog *catalog.RailgunCatalog) *Metrics {
+
+	m := &Metrics{
+		Registry: prometheus.NewRegistry(),
+		Requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "The number of HTTP requests by route, method, and status code.",
+		}, []string{"route", "method", "status"}),
+		RequestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "The latency of HTTP requests by route, method, and status code.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"route", "method", "status"}),
+		TileFeatures: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "tile_features",
+			Help:      "The number of features in tiles by layer.",
+			Buckets:   prometheus.ExponentialBuckets(1, 4, 10),
+		}, []string{"layer"}),
+		DataStoreReadDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "datastore_read_duration_seconds",
+			Help:      "The time to read and deserialize data stores by uri scheme.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"scheme"}),
+		ProcessDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "process_evaluation_duration_seconds",
+			Help:      "The time to evaluate the DFL expression of processes by process.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"process"}),
+		mutex:      &sync.Mutex{},
+		activeRuns: map[string]struct{}{},
+	}
+
+	m.Registry.MustRegister(
+		prometheus.NewGoCollector(),
+		prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}),
+		m.Requests,
+		m.RequestDuration,
+		m.TileFeatures,
+		m.DataStoreReadDuration,
+		m.ProcessDuration,
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "runs_active",
+			Help:      "The number of services, jobs, and workflows that are running.",
+		}, m.countActiveRuns),
+		NewCacheCollector(railgunCatalog),
+	)
+
+	return m
+}
+
+// Handler returns the handler that serves the metrics in the Prometheus text format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.Registry, promhttp.HandlerOpts{})
+}
+
+// ObserveHttpRequest records a response to a HTTP request.
+func (m *Metrics) ObserveHttpRequest(route string, method string, status int, d time.Duration) {
+	if m == nil {
+		return
+	}
+	code := strconv.Itoa(status)
+	m.Requests.WithLabelValues(route, method, code).Inc()
+	m.RequestDuration.WithLabelValues(route, method, code).Observe(d.Seconds())
+}
+
+// ObserveRequest records a tile request sent to the requests channel.
+// The hits and misses of the cache are collected from the statistics of the cache by the CacheCollector.
+func (m *Metrics) ObserveRequest(r request.Request) {
+	if m == nil {
+		return
+	}
+	switch req := r.(type) {
+	case *request.TileRequest:
+		if !req.OutsideExtent {
+			m.TileFeatures.WithLabelValues(req.Layer).Observe(float64(req.Features))
+		}
+	}
+}
+
+// ObserveDataStoreRead records the time to read the data store at the uri.
+// Reads of the cache are recorded by the cache when it loads the data store, so requests waiting for a shared load are not recorded.
+func (m *Metrics) ObserveDataStoreRead(uri string, d time.Duration) {
+	if m == nil {
+		return
+	}
+	scheme, _ := grw.SplitUri(uri)
+	if len(scheme) == 0 {
+		scheme = "file"
+	}
+	m.DataStoreReadDuration.WithLabelValues(scheme).Observe(d.Seconds())
+}
+
+// ObserveProcess records the time to evaluate the process with the given name.
+func (m *Metrics) ObserveProcess(name string, d time.Duration) {
+	if m == nil {
+		return
+	}
+	m.ProcessDuration.WithLabelValues(name).Observe(d.Seconds())
+}
+
+// ObserveRun tracks whether the run is active.
+func (m *Metrics) ObserveRun(run *runs.Run) {
+	if m == nil {
+		return
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if run.Status == runs.StatusRunning {
+		m.activeRuns[run.Id] = struct{}{}
+	} else {
+		delete(m.activeRuns, run.Id)
+	}
+}
+
+func (m *Metrics) countActiveRuns() float64 {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return float64(len(m.activeRuns))
+}
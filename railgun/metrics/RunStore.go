@@ -0,0 +1,24 @@
+// =================================================================
+//
+// Copyright (C) 2018 Spatial Current, Inc. - All Rights Reserved
+// Released as open source under the MIT License.  See LICENSE file.
+//
+// =================================================================
+
+package metrics
+
+import (
+	"github.com/spatialcurrent/railgun/railgun/runs"
+)
+
+// RunStore is a runs.Store that tracks the active runs saved to the store,
+// so runs started by the API and the scheduler are both counted.
+type RunStore struct {
+	runs.Store
+	Metrics *Metrics
+}
+
+func (s *RunStore) Save(run *runs.Run) error {
+	s.Metrics.ObserveRun(run)
+	return s.Store.Save(run)
+}
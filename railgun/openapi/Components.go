@@ -0,0 +1,16 @@
+// =================================================================
+//
+// Copyright (C) 2018 Spatial Current, Inc. - All Rights Reserved
+// Released as open source under the MIT License.  See LICENSE file.
+//
+// =================================================================
+
+package openapi
+
+type Components struct {
+	Schemas         map[string]*Schema         `json:"schemas,omitempty" yaml:"schemas,omitempty"`
+	Responses       map[string]*Response       `json:"responses,omitempty" yaml:"responses,omitempty"`
+	Parameters      map[string]*Parameter      `json:"parameters,omitempty" yaml:"parameters,omitempty"`
+	RequestBodies   map[string]*RequestBody    `json:"requestBodies,omitempty" yaml:"requestBodies,omitempty"`
+	SecuritySchemes map[string]*SecurityScheme `json:"securitySchemes,omitempty" yaml:"securitySchemes,omitempty"`
+}
@@ -0,0 +1,14 @@
+// =================================================================
+//
+// Copyright (C) 2018 Spatial Current, Inc. - All Rights Reserved
+// Released as open source under the MIT License.  See LICENSE file.
+//
+// =================================================================
+
+package openapi
+
+type Contact struct {
+	Name  string `json:"name,omitempty" yaml:"name,omitempty"`
+	Url   string `json:"url,omitempty" yaml:"url,omitempty"`
+	Email string `json:"email,omitempty" yaml:"email,omitempty"`
+}
@@ -0,0 +1,22 @@
+// =================================================================
+//
+// Copyright (C) 2018 Spatial Current, Inc. - All Rights Reserved
+// Released as open source under the MIT License.  See LICENSE file.
+//
+// =================================================================
+
+package openapi
+
+// Version is the version of the OpenAPI specification implemented by this package.
+const Version = "3.1.0"
+
+// Document is an OpenAPI 3.1 document.
+type Document struct {
+	OpenApi    string                `json:"openapi" yaml:"openapi"`
+	Info       *Info                 `json:"info" yaml:"info"`
+	Servers    []*Server             `json:"servers,omitempty" yaml:"servers,omitempty"`
+	Paths      map[string]*PathItem  `json:"paths" yaml:"paths"`
+	Components *Components           `json:"components,omitempty" yaml:"components,omitempty"`
+	Security   []map[string][]string `json:"security,omitempty" yaml:"security,omitempty"`
+	Tags       []*Tag                `json:"tags,omitempty" yaml:"tags,omitempty"`
+}
@@ -0,0 +1,13 @@
+// =================================================================
+//
+// Copyright (C) 2018 Spatial Current, Inc. - All Rights Reserved
+// Released as open source under the MIT License.  See LICENSE file.
+//
+// =================================================================
+
+package openapi
+
+type License struct {
+	Name string `json:"name" yaml:"name"`
+	Url  string `json:"url,omitempty" yaml:"url,omitempty"`
+}
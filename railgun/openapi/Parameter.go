@@ -0,0 +1,17 @@
+// =================================================================
+//
+// Copyright (C) 2018 Spatial Current, Inc. - All Rights Reserved
+// Released as open source under the MIT License.  See LICENSE file.
+//
+// =================================================================
+
+package openapi
+
+type Parameter struct {
+	Ref         string  `json:"$ref,omitempty" yaml:"$ref,omitempty"`
+	Name        string  `json:"name,omitempty" yaml:"name,omitempty"`
+	In          string  `json:"in,omitempty" yaml:"in,omitempty"` // path, query, header, or cookie
+	Description string  `json:"description,omitempty" yaml:"description,omitempty"`
+	Required    bool    `json:"required,omitempty" yaml:"required,omitempty"`
+	Schema      *Schema `json:"schema,omitempty" yaml:"schema,omitempty"`
+}
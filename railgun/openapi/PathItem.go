@@ -0,0 +1,53 @@
+// =================================================================
+//
+// Copyright (C) 2018 Spatial Current, Inc. - All Rights Reserved
+// Released as open source under the MIT License.  See LICENSE file.
+//
+// =================================================================
+
+package openapi
+
+import (
+	"strings"
+)
+
+type PathItem struct {
+	Summary string     `json:"summary,omitempty" yaml:"summary,omitempty"`
+	Get     *Operation `json:"get,omitempty" yaml:"get,omitempty"`
+	Put     *Operation `json:"put,omitempty" yaml:"put,omitempty"`
+	Post    *Operation `json:"post,omitempty" yaml:"post,omitempty"`
+	Delete  *Operation `json:"delete,omitempty" yaml:"delete,omitempty"`
+}
+
+// GetOperation returns the operation for the given http method, or nil if the path has no operation for the method.
+func (p *PathItem) GetOperation(method string) *Operation {
+	switch strings.ToUpper(method) {
+	case "GET":
+		return p.Get
+	case "PUT":
+		return p.Put
+	case "POST":
+		return p.Post
+	case "DELETE":
+		return p.Delete
+	}
+	return nil
+}
+
+// SetOperation sets the operation for the given http method.
+// Returns false if the method is not supported.
+func (p *PathItem) SetOperation(method string, op *Operation) bool {
+	switch strings.ToUpper(method) {
+	case "GET":
+		p.Get = op
+	case "PUT":
+		p.Put = op
+	case "POST":
+		p.Post = op
+	case "DELETE":
+		p.Delete = op
+	default:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,14 @@
+// =================================================================
+//
+// Copyright (C) 2018 Spatial Current, Inc. - All Rights Reserved
+// Released as open source under the MIT License.  See LICENSE file.
+//
+// =================================================================
+
+package openapi
+
+type Response struct {
+	Ref         string                `json:"$ref,omitempty" yaml:"$ref,omitempty"`
+	Description string                `json:"description,omitempty" yaml:"description,omitempty"`
+	Content     map[string]*MediaType `json:"content,omitempty" yaml:"content,omitempty"`
+}
@@ -0,0 +1,36 @@
+// =================================================================
+//
+// Copyright (C) 2018 Spatial Current, Inc. - All Rights Reserved
+// Released as open source under the MIT License.  See LICENSE file.
+//
+// =================================================================
+
+package openapi
+
+// Schema is a JSON Schema, as used by OpenAPI 3.1.
+// Only the keywords used by railgun are supported.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty" yaml:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty" yaml:"type,omitempty"`
+	Format               string             `json:"format,omitempty" yaml:"format,omitempty"`
+	Title                string             `json:"title,omitempty" yaml:"title,omitempty"`
+	Description          string             `json:"description,omitempty" yaml:"description,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty" yaml:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty" yaml:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty" yaml:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty" yaml:"items,omitempty"`
+	Enum                 []interface{}      `json:"enum,omitempty" yaml:"enum,omitempty"`
+	Default              interface{}        `json:"default,omitempty" yaml:"default,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty" yaml:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty" yaml:"maximum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty" yaml:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty" yaml:"maxLength,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty" yaml:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty" yaml:"maxItems,omitempty"`
+	ContentMediaType     string             `json:"contentMediaType,omitempty" yaml:"contentMediaType,omitempty"`
+}
+
+// Ref returns a schema that references the schema with the given name in the components of the document.
+func Ref(name string) *Schema {
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
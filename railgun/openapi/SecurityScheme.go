@@ -0,0 +1,17 @@
+// =================================================================
+//
+// Copyright (C) 2018 Spatial Current, Inc. - All Rights Reserved
+// Released as open source under the MIT License.  See LICENSE file.
+//
+// =================================================================
+
+package openapi
+
+type SecurityScheme struct {
+	Type         string `json:"type" yaml:"type"` // apiKey, http, oauth2, or openIdConnect
+	Description  string `json:"description,omitempty" yaml:"description,omitempty"`
+	Name         string `json:"name,omitempty" yaml:"name,omitempty"`
+	In           string `json:"in,omitempty" yaml:"in,omitempty"`
+	Scheme       string `json:"scheme,omitempty" yaml:"scheme,omitempty"`
+	BearerFormat string `json:"bearerFormat,omitempty" yaml:"bearerFormat,omitempty"`
+}
@@ -0,0 +1,32 @@
+// =================================================================
+//
+// Copyright (C) 2018 Spatial Current, Inc. - All Rights Reserved
+// Released as open source under the MIT License.  See LICENSE file.
+//
+// =================================================================
+
+package parser
+
+import (
+	"fmt"
+	"github.com/spatialcurrent/go-try-get/gtg"
+	rerrors "github.com/spatialcurrent/railgun/railgun/errors"
+	"strconv"
+	"strings"
+)
+
+// ParseBool returns the boolean value with the given name, or false if the value is missing or blank.
+func ParseBool(obj interface{}, name string) (bool, error) {
+	v := gtg.TryGet(obj, name, nil)
+	if v == nil || strings.TrimSpace(fmt.Sprint(v)) == "" {
+		return false, nil
+	}
+	if b, ok := v.(bool); ok {
+		return b, nil
+	}
+	b, err := strconv.ParseBool(fmt.Sprint(v))
+	if err != nil {
+		return false, &rerrors.ErrInvalidParameter{Name: name, Value: v}
+	}
+	return b, nil
+}
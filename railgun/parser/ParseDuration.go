@@ -0,0 +1,32 @@
+// =================================================================
+//
+// Copyright (C) 2018 Spatial Current, Inc. - All Rights Reserved
+// Released as open source under the MIT License.  See LICENSE file.
+//
+// =================================================================
+
+package parser
+
+import (
+	"fmt"
+	"github.com/spatialcurrent/go-try-get/gtg"
+	rerrors "github.com/spatialcurrent/railgun/railgun/errors"
+	"strings"
+	"time"
+)
+
+// ParseDuration returns the duration with the given name, e.g., 90s or 1h, or zero if the value is missing or blank.
+func ParseDuration(obj interface{}, name string) (time.Duration, error) {
+	v := gtg.TryGet(obj, name, nil)
+	if v == nil || strings.TrimSpace(fmt.Sprint(v)) == "" {
+		return 0, nil
+	}
+	if d, ok := v.(time.Duration); ok {
+		return d, nil
+	}
+	d, err := time.ParseDuration(strings.TrimSpace(fmt.Sprint(v)))
+	if err != nil || d < 0 {
+		return 0, &rerrors.ErrInvalidParameter{Name: name, Value: v}
+	}
+	return d, nil
+}
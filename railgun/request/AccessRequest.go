@@ -0,0 +1,148 @@
+// =================================================================
+//
+// Copyright (C) 2018 Spatial Current, Inc. - All Rights Reserved
+// Released as open source under the MIT License.  See LICENSE file.
+//
+// =================================================================
+
+package request
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"github.com/spatialcurrent/go-simple-serializer/gss"
+	"net/http"
+	"regexp"
+	"time"
+)
+
+type contextKey int
+
+const accessRequestKey contextKey = 0
+
+// RequestIdHeader is the header used to propagate the id of a request.
+const RequestIdHeader = "X-Request-ID"
+
+var requestIdRegexp = regexp.MustCompile("^[a-zA-Z0-9._:-]{1,128}$")
+
+// AccessRequest is the record of a HTTP request for the access log.
+// Handlers add the tile and cache requests for the request, so the record includes the tile, features, and cache hit.
+type AccessRequest struct {
+	Id           string
+	Trace        string
+	Method       string
+	Path         string
+	Route        string
+	Vars         map[string]string
+	Status       int
+	Bytes        int64
+	Latency      time.Duration
+	User         string
+	Error        string
+	TileRequest  *TileRequest
+	CacheRequest *CacheRequest
+}
+
+// NewRequestId returns the id of the request in the X-Request-ID header, if valid, and otherwise a new random id.
+func NewRequestId(r *http.Request) string {
+	if id := r.Header.Get(RequestIdHeader); requestIdRegexp.MatchString(id) {
+		return id
+	}
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// WithAccessRequest returns a shallow copy of the HTTP request with the access request in its context.
+func WithAccessRequest(r *http.Request, ar *AccessRequest) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), accessRequestKey, ar))
+}
+
+// GetAccessRequest returns the access request for the HTTP request, or nil if the request is not logged.
+func GetAccessRequest(r *http.Request) *AccessRequest {
+	if ar, ok := r.Context().Value(accessRequestKey).(*AccessRequest); ok {
+		return ar
+	}
+	return nil
+}
+
+// AddTileRequest adds the tile request to the access request, if not nil.
+func (ar *AccessRequest) AddTileRequest(tr *TileRequest) {
+	if ar != nil {
+		ar.TileRequest = tr
+	}
+}
+
+// AddCacheRequest adds the cache request to the access request, if not nil.
+func (ar *AccessRequest) AddCacheRequest(cr *CacheRequest) {
+	if ar != nil {
+		ar.CacheRequest = cr
+	}
+}
+
+func (ar AccessRequest) String() string {
+	str := "request " + ar.Id + " " + ar.Method + " " + ar.Path
+	str += " matched route " + ar.Route
+	str += fmt.Sprintf(" and returned %d with %d bytes in %s", ar.Status, ar.Bytes, ar.Latency)
+	if len(ar.User) > 0 {
+		str += " for user " + ar.User
+	}
+	if ar.TileRequest != nil {
+		str += " with " + fmt.Sprint(ar.TileRequest.Features) + " features for tile " + ar.TileRequest.Tile.String()
+	}
+	if ar.CacheRequest != nil && len(ar.CacheRequest.Key) > 0 {
+		if ar.CacheRequest.Hit {
+			str += " from cache"
+		} else {
+			str += " from data store"
+		}
+	}
+	if len(ar.Error) > 0 {
+		str += ": " + ar.Error
+	}
+	return str
+}
+
+func (ar AccessRequest) Map() map[string]interface{} {
+	m := map[string]interface{}{
+		"id": ar.Id,
+		"http": map[string]interface{}{
+			"method": ar.Method,
+			"path":   ar.Path,
+			"status": ar.Status,
+			"bytes":  ar.Bytes,
+		},
+		"route": map[string]interface{}{
+			"name": ar.Route,
+			"vars": ar.Vars,
+		},
+		"latency": ar.Latency.Seconds(),
+		"user":    ar.User,
+	}
+	if len(ar.Trace) > 0 {
+		m["trace"] = map[string]interface{}{
+			"id": ar.Trace,
+		}
+	}
+	if len(ar.Error) > 0 {
+		m["error"] = ar.Error
+	}
+	if ar.TileRequest != nil {
+		m["tile"] = ar.TileRequest.Tile.Map()
+		m["results"] = map[string]interface{}{
+			"features": ar.TileRequest.Features,
+		}
+	}
+	if ar.CacheRequest != nil && len(ar.CacheRequest.Key) > 0 {
+		m["cache"] = map[string]interface{}{
+			"hit": ar.CacheRequest.Hit,
+		}
+	}
+	return m
+}
+
+func (ar AccessRequest) Serialize(format string) (string, error) {
+	return gss.SerializeString(ar.Map(), format, gss.NoHeader, gss.NoLimit)
+}
@@ -12,8 +12,9 @@ import (
 )
 
 type CacheRequest struct {
-	Key string
-	Hit bool
+	Layer string
+	Key   string
+	Hit   bool
 }
 
 func (cr CacheRequest) String() string {
@@ -24,11 +25,17 @@ func (cr CacheRequest) String() string {
 		str += " miss"
 	}
 	str += " for key " + cr.Key
+	if len(cr.Layer) > 0 {
+		str += " of layer " + cr.Layer
+	}
 	return str
 }
 
 func (cr CacheRequest) Map() map[string]interface{} {
 	return map[string]interface{}{
+		"layer": map[string]interface{}{
+			"name": cr.Layer,
+		},
 		"key": cr.Key,
 		"hit": cr.Hit,
 	}
@@ -0,0 +1,71 @@
+// =================================================================
+//
+// Copyright (C) 2018 Spatial Current, Inc. - All Rights Reserved
+// Released as open source under the MIT License.  See LICENSE file.
+//
+// =================================================================
+
+package router
+
+import (
+	"github.com/pkg/errors"
+	"github.com/spatialcurrent/railgun/railgun/logger"
+	"math/rand"
+	"net/http"
+	"strings"
+)
+
+// AccessLog decides which requests are written to the access log.
+// Successful requests are logged at info, client errors at warn, and server errors at error.
+// A request is logged if its level is at or above the level of its route, and
+// info requests are sampled at SampleRate, which is between 0 and 1.
+type AccessLog struct {
+	Level       int
+	RouteLevels map[string]int
+	SampleRate  float64
+}
+
+// NewAccessLog returns a new access log for the default level, the per-route levels as route:level, and the sample rate.
+func NewAccessLog(level string, routeLevels []string, sampleRate float64) (*AccessLog, error) {
+	l, err := logger.ParseLevel(level)
+	if err != nil {
+		return nil, err
+	}
+	if sampleRate < 0 || sampleRate > 1 {
+		return nil, errors.New("sample rate must be between 0 and 1")
+	}
+	al := &AccessLog{Level: l, RouteLevels: map[string]int{}, SampleRate: sampleRate}
+	for _, str := range routeLevels {
+		parts := strings.SplitN(str, ":", 2)
+		if len(parts) != 2 {
+			return nil, errors.New("invalid route log level " + str + ", expecting route:level")
+		}
+		l, err := logger.ParseLevel(parts[1])
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid log level for route "+parts[0])
+		}
+		al.RouteLevels[parts[0]] = l
+	}
+	return al, nil
+}
+
+// Enabled returns true if a request to the route with the status code should be logged.
+func (al *AccessLog) Enabled(route string, status int) bool {
+	level := logger.LevelInfo
+	if status >= http.StatusInternalServerError {
+		level = logger.LevelError
+	} else if status >= http.StatusBadRequest {
+		level = logger.LevelWarn
+	}
+	threshold := al.Level
+	if l, ok := al.RouteLevels[route]; ok {
+		threshold = l
+	}
+	if level < threshold {
+		return false
+	}
+	if level == logger.LevelInfo && al.SampleRate < 1 {
+		return rand.Float64() < al.SampleRate
+	}
+	return true
+}
@@ -0,0 +1,55 @@
+// =================================================================
+//
+// Copyright (C) 2018 Spatial Current, Inc. - All Rights Reserved
+// Released as open source under the MIT License.  See LICENSE file.
+//
+// =================================================================
+
+package router
+
+import (
+	"github.com/gorilla/mux"
+	"github.com/spatialcurrent/railgun/railgun/request"
+	"github.com/spatialcurrent/railgun/railgun/tracing"
+	"net/http"
+	"time"
+)
+
+// AccessLogMiddleware assigns each request an id, which is propagated from the X-Request-ID header if valid and returned in the response.
+// When the request is complete, the record of the request is sent to the requests channel, if enabled by the access log.
+func AccessLogMiddleware(al *AccessLog, requests chan request.Request, getUser func(r *http.Request) string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			ar := &request.AccessRequest{
+				Id:     request.NewRequestId(r),
+				Method: r.Method,
+				Path:   r.URL.Path,
+				Route:  RouteName(r),
+				Trace:  tracing.TraceId(r.Context()),
+			}
+			w.Header().Set(request.RequestIdHeader, ar.Id)
+			sr := NewStatusRecorder(w)
+			r = request.WithAccessRequest(r, ar)
+			// the request is recorded even if the handler panics, e.g., to abort a stream, with the status of a failed request.
+			completed := false
+			defer func() {
+				status := sr.Status
+				if !completed {
+					status = http.StatusInternalServerError
+				}
+				if !al.Enabled(ar.Route, status) {
+					return
+				}
+				ar.Vars = mux.Vars(r)
+				ar.Status = status
+				ar.Bytes = sr.Bytes
+				ar.Latency = time.Since(start)
+				ar.User = getUser(r)
+				requests <- ar
+			}()
+			next.ServeHTTP(sr, r)
+			completed = true
+		})
+	}
+}
@@ -0,0 +1,46 @@
+// =================================================================
+//
+// Copyright (C) 2018 Spatial Current, Inc. - All Rights Reserved
+// Released as open source under the MIT License.  See LICENSE file.
+//
+// =================================================================
+
+package router
+
+import (
+	"github.com/gorilla/mux"
+	"github.com/spatialcurrent/railgun/railgun/metrics"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// RouteName returns the name of the route matched by the request.
+// Routes without an extension have the same name as the route with an extension.
+func RouteName(r *http.Request) string {
+	if route := mux.CurrentRoute(r); route != nil {
+		return strings.TrimSuffix(route.GetName(), "_negotiated")
+	}
+	return ""
+}
+
+// MetricsMiddleware records the count and latency of requests by route, method, and status code.
+func MetricsMiddleware(m *metrics.Metrics) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			sr := NewStatusRecorder(w)
+			// the request is counted even if the handler panics, e.g., to abort a stream, with the status of a failed request.
+			completed := false
+			defer func() {
+				status := sr.Status
+				if !completed {
+					status = http.StatusInternalServerError
+				}
+				m.ObserveHttpRequest(RouteName(r), r.Method, status, time.Since(start))
+			}()
+			next.ServeHTTP(sr, r)
+			completed = true
+		})
+	}
+}
@@ -11,8 +11,15 @@ import (
 	"github.com/spatialcurrent/railgun/railgun/catalog"
 	"github.com/spatialcurrent/railgun/railgun/core"
 	"github.com/spatialcurrent/railgun/railgun/handlers"
+	"github.com/spatialcurrent/railgun/railgun/health"
+	"github.com/spatialcurrent/railgun/railgun/keyring"
+	"github.com/spatialcurrent/railgun/railgun/metrics"
 	"github.com/spatialcurrent/railgun/railgun/request"
+	"github.com/spatialcurrent/railgun/railgun/runs"
+	"github.com/spatialcurrent/railgun/railgun/scheduler"
+	"github.com/spatialcurrent/railgun/railgun/session"
 	"github.com/spatialcurrent/viper"
+	"net/http"
 	"reflect"
 	"strings"
 	"time"
@@ -26,9 +33,14 @@ type RailgunRouter struct {
 	PrivateKey      *rsa.PrivateKey
 	ValidMethods    []string
 	SessionDuration time.Duration
+	Runs            runs.Store
+	Scheduler       *scheduler.Scheduler
+	Sessions        *session.Store
+	Metrics         *metrics.Metrics
+	Keyring         *keyring.Keyring
 }
 
-func NewRailgunRouter(v *viper.Viper, railgunCatalog *catalog.RailgunCatalog, requests chan request.Request, messages chan interface{}, errors chan error, awsSessionCache *gocache.Cache, publicKey *rsa.PublicKey, privateKey *rsa.PrivateKey, validMethods []string) *RailgunRouter {
+func NewRailgunRouter(v *viper.Viper, railgunCatalog *catalog.RailgunCatalog, requests chan request.Request, messages chan interface{}, errors chan error, awsSessionCache *gocache.Cache, kr *keyring.Keyring, runStore runs.Store, m *metrics.Metrics, accessLog *AccessLog, publicKey *rsa.PublicKey, privateKey *rsa.PrivateKey, validMethods []string) *RailgunRouter {
 
 	r := &RailgunRouter{
 		Viper:           v,
@@ -38,21 +50,50 @@ func NewRailgunRouter(v *viper.Viper, railgunCatalog *catalog.RailgunCatalog, re
 		PrivateKey:      privateKey,
 		ValidMethods:    validMethods,
 		SessionDuration: v.GetDuration("jwt-session-duration"),
+		Runs:            runStore,
+		Sessions:        session.NewStore(10 * time.Minute),
+		Metrics:         m,
+		Keyring:         kr,
 	}
 
+	r.Scheduler = scheduler.NewScheduler(
+		railgunCatalog,
+		r.NewBaseHandler().NewRunner(),
+		runStore,
+		messages,
+		errors,
+		v.GetDuration("scheduler-sync-interval"))
+
 	//r.Use(GzipMiddleware)
 	if v.GetBool("http-middleware-gzip") {
 		r.Use(gziphandler.MustNewGzipLevelHandler(gzip.DefaultCompression))
 	}
-	r.Use(DebugMiddleware)
+	r.Use(TracingMiddleware)
+	r.Use(AccessLogMiddleware(accessLog, requests, r.NewBaseHandler().GetUser))
+	r.Use(MetricsMiddleware(m))
+	if v.GetBool("verbose") {
+		r.Use(DebugMiddleware)
+	}
+	r.Use(VaryMiddleware)
 	r.Use(CorsMiddleware(v.GetString("cors-origin"), v.GetString("cors-credentials")))
 
 	r.AddHomeHandler("home", "/")
 
 	r.AddSwaggerHandler("swagger", "/swagger.{ext}")
 
+	r.AddOpenApiHandler("openapi", "/openapi.{ext}")
+
 	r.AddHealthHandler("health", "/health.{ext}")
 
+	r.AddHealthHandler("health_live", "/health/live.{ext}")
+
+	r.AddReadinessHandler("health_ready", "/health/ready.{ext}")
+
+	// metrics are served by a separate server if metrics-address is set.
+	if len(v.GetString("metrics-address")) == 0 {
+		r.AddMetricsHandler("metrics", "/metrics")
+	}
+
 	r.AddAuthenticateHandler("authenticate", "/authenticate.{ext}")
 
 	r.AddObjectHandler("formats", "/gss/formats.{ext}", map[string]interface{}{"formats": gss.Formats})
@@ -126,14 +167,34 @@ func NewRailgunRouter(v *viper.Viper, railgunCatalog *catalog.RailgunCatalog, re
 
 	r.AddServiceExecHandler("service_exec", "/services/{name}/exec.{ext}")
 
+	r.AddServiceSessionsHandler("service_sessions", "/services/{name}/sessions.{ext}", "POST", "OPTIONS")
+
+	r.AddServiceSessionsHandler("service_session", "/services/{name}/sessions/{id}.{ext}", "GET", "DELETE", "OPTIONS")
+
 	r.AddJobExecHandler("job_exec", "/jobs/{name}/exec.{ext}")
 
 	r.AddWorkflowExecHandler("workflow_exec", "/workflows/{name}/exec.{ext}")
 
+	r.AddSchedulesHandler("schedules", "/schedules.{ext}")
+
+	r.AddRunsHandler("runs", "/runs.{ext}")
+
+	r.AddRunHandler("run", "/runs/{id}.{ext}", false)
+
+	r.AddRunHandler("run_logs", "/runs/{id}/logs.{ext}", true)
+
 	r.AddLayerTileHandler("tile", "/layers/{name}/tiles/data/{z}/{x}/{y}.{ext}")
 
 	r.AddLayerMaskHandler("mask", "/layers/{name}/tiles/mask/{z}/{x}/{y}.{ext}")
 
+	r.AddLayerCacheHandler("layer_cache", "/layers/{name}/cache.{ext}", false)
+
+	r.AddLayerCacheHandler("layer_cache_warm", "/layers/{name}/cache/warm.{ext}", true)
+
+	r.AddCacheHandler("cache", "/cache.{ext}")
+
+	r.AddCacheEntryHandler("cache_entry", "/cache/{key}.{ext}")
+
 	return r
 }
 
@@ -149,86 +210,172 @@ func (r *RailgunRouter) NewBaseHandler() *handlers.BaseHandler {
 		PrivateKey:      r.PrivateKey,
 		ValidMethods:    r.ValidMethods,
 		SessionDuration: r.SessionDuration,
+		Runs:            r.Runs,
+		Metrics:         r.Metrics,
+		Keyring:         r.Keyring,
+	}
+}
+
+// handle adds the handler at the path for the methods.
+// If the path ends with .{ext}, then the handler is also added at the path without the extension,
+// where the format of the response is negotiated with the Accept header.
+func (r *RailgunRouter) handle(name string, path string, handler http.Handler, methods ...string) {
+	r.Methods(methods...).Name(name).Path(path).Handler(handler)
+	if strings.HasSuffix(path, ".{ext}") {
+		r.Methods(methods...).Name(name + "_negotiated").Path(strings.TrimSuffix(path, ".{ext}")).Handler(handler)
 	}
 }
 
 func (r *RailgunRouter) AddObjectHandler(name string, path string, object interface{}) {
-	r.Methods("Get").Name(name).Path(path).Handler(&handlers.ObjectHandler{
+	r.handle(name, path, &handlers.ObjectHandler{
 		Object:      object,
 		BaseHandler: r.NewBaseHandler(),
-	})
+	}, "GET")
 }
 
 func (r *RailgunRouter) AddGroupHandler(name string, path string, t reflect.Type) {
 
 	fmt.Println("* adding group handler " + name + " at path " + path)
 
-	r.Methods("GET", "POST", "PUT", "OPTIONS").Name(name).Path(path).Handler(&handlers.GroupHandler{
+	r.handle(name, path, &handlers.GroupHandler{
 		Type:        t,
 		BaseHandler: r.NewBaseHandler(),
-	})
+	}, "GET", "POST", "PUT", "OPTIONS")
 }
 
 func (r *RailgunRouter) AddItemHandler(name string, path string, t reflect.Type, singular string, plural string) {
-	r.Methods("GET", "POST", "OPTIONS", "DELETE").Name(name).Path(path).Handler(&handlers.ItemHandler{
+	r.handle(name, path, &handlers.ItemHandler{
 		Singular:    singular,
 		Plural:      plural,
 		Type:        t,
 		BaseHandler: r.NewBaseHandler(),
-	})
+	}, "GET", "POST", "OPTIONS", "DELETE")
 }
 
 func (r *RailgunRouter) AddSwaggerHandler(name string, path string) {
-	r.Methods("GET").Name(name).Path(path).Handler(&handlers.SwaggerHandler{
+	r.handle(name, path, &handlers.SwaggerHandler{
+		BaseHandler: r.NewBaseHandler(),
+	}, "GET")
+}
+
+func (r *RailgunRouter) AddOpenApiHandler(name string, path string) {
+	r.handle(name, path, &handlers.OpenApiHandler{
 		BaseHandler: r.NewBaseHandler(),
-	})
+		Router:      r.Router.Router,
+	}, "GET")
 }
 
 func (r *RailgunRouter) AddHealthHandler(name string, path string) {
-	r.Methods("GET").Name(name).Path(path).Handler(&handlers.HealthHandler{
+	r.handle(name, path, &handlers.HealthHandler{
+		BaseHandler: r.NewBaseHandler(),
+	}, "GET")
+}
+
+func (r *RailgunRouter) AddReadinessHandler(name string, path string) {
+	r.handle(name, path, &handlers.ReadinessHandler{
 		BaseHandler: r.NewBaseHandler(),
-	})
+		Checker:     health.NewChecker(r.Viper.GetDuration("health-timeout"), r.Viper.GetDuration("health-cache-ttl")),
+	}, "GET")
+}
+
+func (r *RailgunRouter) AddMetricsHandler(name string, path string) {
+	r.handle(name, path, r.Metrics.Handler(), "GET")
 }
 
 func (r *RailgunRouter) AddAuthenticateHandler(name string, path string) {
-	r.Methods("POST").Name(name).Path(path).Handler(&handlers.AuthenticateHandler{
+	r.handle(name, path, &handlers.AuthenticateHandler{
 		BaseHandler: r.NewBaseHandler(),
-	})
+	}, "POST")
 }
 
 func (r *RailgunRouter) AddHomeHandler(name string, path string) {
-	r.Methods("GET").Name(name).Path(path).Handler(&handlers.HomeHandler{
+	r.handle(name, path, &handlers.HomeHandler{
 		BaseHandler: r.NewBaseHandler(),
-	})
+	}, "GET")
 }
 
 func (r *RailgunRouter) AddServiceExecHandler(name string, path string) {
-	r.Methods("POST", "OPTIONS").Name(name).Path(path).Handler(&handlers.ServiceExecHandler{
+	r.handle(name, path, &handlers.ServiceExecHandler{
 		BaseHandler: r.NewBaseHandler(),
 		Cache:       gocache.New(5*time.Minute, 10*time.Minute),
-	})
+		Sessions:    r.Sessions,
+	}, "POST", "OPTIONS")
+}
+
+// AddServiceSessionsHandler adds the handler of sessions with the given methods,
+// so sessions are only created without an id and only read or deleted with an id.
+func (r *RailgunRouter) AddServiceSessionsHandler(name string, path string, methods ...string) {
+	r.handle(name, path, &handlers.ServiceSessionsHandler{
+		BaseHandler: r.NewBaseHandler(),
+		Sessions:    r.Sessions,
+		DefaultTTL:  r.Viper.GetDuration("session-ttl"),
+		MaxTTL:      r.Viper.GetDuration("session-max-ttl"),
+	}, methods...)
 }
 
 func (r *RailgunRouter) AddJobExecHandler(name string, path string) {
-	r.Methods("POST", "OPTIONS").Name(name).Path(path).Handler(&handlers.JobExecHandler{
+	r.handle(name, path, &handlers.JobExecHandler{
 		BaseHandler: r.NewBaseHandler(),
-	})
+	}, "POST", "OPTIONS")
 }
 
 func (r *RailgunRouter) AddWorkflowExecHandler(name string, path string) {
-	r.Methods("POST", "OPTIONS").Name(name).Path(path).Handler(&handlers.WorkflowExecHandler{
+	r.handle(name, path, &handlers.WorkflowExecHandler{
 		BaseHandler: r.NewBaseHandler(),
-	})
+	}, "POST", "OPTIONS")
+}
+
+func (r *RailgunRouter) AddSchedulesHandler(name string, path string) {
+	r.handle(name, path, &handlers.SchedulesHandler{
+		BaseHandler: r.NewBaseHandler(),
+		Scheduler:   r.Scheduler,
+	}, "GET")
+}
+
+func (r *RailgunRouter) AddRunsHandler(name string, path string) {
+	r.handle(name, path, &handlers.RunsHandler{
+		BaseHandler: r.NewBaseHandler(),
+	}, "GET")
+}
+
+func (r *RailgunRouter) AddRunHandler(name string, path string, logs bool) {
+	r.handle(name, path, &handlers.RunHandler{
+		BaseHandler: r.NewBaseHandler(),
+		Logs:        logs,
+	}, "GET")
 }
 
 func (r *RailgunRouter) AddLayerTileHandler(name string, path string) {
-	r.Methods("GET").Name(name).Path(path).Handler(&handlers.LayerTileHandler{
+	r.handle(name, path, &handlers.LayerTileHandler{
 		BaseHandler: r.NewBaseHandler(),
-	})
+	}, "GET")
 }
 
 func (r *RailgunRouter) AddLayerMaskHandler(name string, path string) {
-	r.Methods("GET").Name(name).Path(path).Handler(&handlers.LayerMaskHandler{
+	r.handle(name, path, &handlers.LayerMaskHandler{
+		BaseHandler: r.NewBaseHandler(),
+	}, "GET")
+}
+
+func (r *RailgunRouter) AddCacheHandler(name string, path string) {
+	r.handle(name, path, &handlers.CacheHandler{
+		BaseHandler: r.NewBaseHandler(),
+	}, "GET")
+}
+
+func (r *RailgunRouter) AddCacheEntryHandler(name string, path string) {
+	r.handle(name, path, &handlers.CacheEntryHandler{
+		BaseHandler: r.NewBaseHandler(),
+	}, "DELETE", "OPTIONS")
+}
+
+func (r *RailgunRouter) AddLayerCacheHandler(name string, path string, warm bool) {
+	method := "DELETE"
+	if warm {
+		method = "POST"
+	}
+	r.handle(name, path, &handlers.LayerCacheHandler{
 		BaseHandler: r.NewBaseHandler(),
-	})
+		Warm:        warm,
+	}, method, "OPTIONS")
 }
@@ -0,0 +1,41 @@
+// =================================================================
+//
+// Copyright (C) 2018 Spatial Current, Inc. - All Rights Reserved
+// Released as open source under the MIT License.  See LICENSE file.
+//
+// =================================================================
+
+package router
+
+import (
+	"net/http"
+)
+
+// StatusRecorder is a http.ResponseWriter that records the status code and the number of bytes of the response.
+// If the handler never calls WriteHeader, then the status code is 200.
+type StatusRecorder struct {
+	http.ResponseWriter
+	Status int
+	Bytes  int64
+}
+
+func NewStatusRecorder(w http.ResponseWriter) *StatusRecorder {
+	return &StatusRecorder{ResponseWriter: w, Status: http.StatusOK}
+}
+
+func (sr *StatusRecorder) WriteHeader(statusCode int) {
+	sr.Status = statusCode
+	sr.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (sr *StatusRecorder) Write(b []byte) (int, error) {
+	n, err := sr.ResponseWriter.Write(b)
+	sr.Bytes += int64(n)
+	return n, err
+}
+
+func (sr *StatusRecorder) Flush() {
+	if f, ok := sr.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -0,0 +1,35 @@
+// =================================================================
+//
+// Copyright (C) 2018 Spatial Current, Inc. - All Rights Reserved
+// Released as open source under the MIT License.  See LICENSE file.
+//
+// =================================================================
+
+package router
+
+import (
+	"github.com/spatialcurrent/railgun/railgun/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"net/http"
+	"strconv"
+)
+
+// TracingMiddleware starts a span for each request, continuing the trace in the W3C traceparent header of the request, if any.
+var TracingMiddleware = func(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := RouteName(r)
+		ctx, span := tracing.StartRequest(r, r.Method+" "+route,
+			attribute.String("http.request.method", r.Method),
+			attribute.String("http.route", route),
+			attribute.String("url.path", r.URL.Path),
+		)
+		defer span.End()
+		sr := NewStatusRecorder(w)
+		next.ServeHTTP(sr, r.WithContext(ctx))
+		span.SetAttributes(attribute.Int("http.response.status_code", sr.Status))
+		if sr.Status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, strconv.Itoa(sr.Status))
+		}
+	})
+}
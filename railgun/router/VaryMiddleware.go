@@ -0,0 +1,24 @@
+// =================================================================
+//
+// Copyright (C) 2018 Spatial Current, Inc. - All Rights Reserved
+// Released as open source under the MIT License.  See LICENSE file.
+//
+// =================================================================
+
+package router
+
+import (
+	"github.com/gorilla/mux"
+	"net/http"
+)
+
+// VaryMiddleware adds "Vary: Accept" to responses from routes without an extension,
+// since the format of the response is negotiated with the Accept header.
+var VaryMiddleware = func(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := mux.Vars(r)["ext"]; !ok {
+			w.Header().Add("Vary", "Accept")
+		}
+		next.ServeHTTP(w, r)
+	})
+}
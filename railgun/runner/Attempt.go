@@ -0,0 +1,35 @@
+// =================================================================
+//
+// Copyright (C) 2018 Spatial Current, Inc. - All Rights Reserved
+// Released as open source under the MIT License.  See LICENSE file.
+//
+// =================================================================
+
+package runner
+
+import (
+	"time"
+)
+
+// Attempt is a single attempt at running a job.
+type Attempt struct {
+	Number int
+	Start  time.Time
+	End    time.Time
+	Class  string
+	Error  error
+}
+
+func (a Attempt) Map() map[string]interface{} {
+	m := map[string]interface{}{
+		"attempt":  a.Number,
+		"start":    a.Start.Format(time.RFC3339),
+		"end":      a.End.Format(time.RFC3339),
+		"duration": a.End.Sub(a.Start).String(),
+	}
+	if a.Error != nil {
+		m["class"] = a.Class
+		m["error"] = a.Error.Error()
+	}
+	return m
+}
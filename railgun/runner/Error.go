@@ -0,0 +1,77 @@
+// =================================================================
+//
+// Copyright (C) 2018 Spatial Current, Inc. - All Rights Reserved
+// Released as open source under the MIT License.  See LICENSE file.
+//
+// =================================================================
+
+package runner
+
+import (
+	"context"
+	"github.com/spatialcurrent/railgun/railgun/core"
+	"runtime"
+)
+
+// Error is an error from an attempt of a job, with the class of the error used by retry policies.
+type Error struct {
+	Class string
+	Err   error
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+func (e *Error) Cause() error {
+	return e.Err
+}
+
+// classify wraps the error with the given class.
+// If the deadline of the context has passed, then the error is classified as a timeout.
+func classify(ctx context.Context, class string, err error) error {
+	if err == nil {
+		return nil
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		class = core.ErrorClassTimeout
+	}
+	return &Error{Class: class, Err: err}
+}
+
+// ErrorClass returns the class of the error, or an empty string if the error is not classified.
+func ErrorClass(err error) string {
+	if e, ok := err.(*Error); ok {
+		return e.Class
+	}
+	return ""
+}
+
+// evaluations bounds the number of evaluations running in the background, including the evaluations abandoned by withContext.
+var evaluations = make(chan struct{}, 4*runtime.NumCPU())
+
+// withContext runs the function in the background and waits for it to complete or the context to be done.
+// If the context is done first, then the context's error is returned and the result of the function is abandoned.
+// DFL evaluation cannot be interrupted, so an abandoned function keeps running until it returns.
+// The work abandoned is bounded, since each function holds one of the evaluations until it returns,
+// and waiting for one of the evaluations also stops once the context is done.
+// It is only used for evaluating expressions, which have no side effects.
+// Reading and writing resources must use the context directly, so they stop before the job is retried.
+func withContext(ctx context.Context, f func() error) error {
+	select {
+	case evaluations <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	done := make(chan error, 1)
+	go func() {
+		defer func() { <-evaluations }()
+		done <- f()
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
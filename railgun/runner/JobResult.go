@@ -0,0 +1,39 @@
+// =================================================================
+//
+// Copyright (C) 2018 Spatial Current, Inc. - All Rights Reserved
+// Released as open source under the MIT License.  See LICENSE file.
+//
+// =================================================================
+
+package runner
+
+// JobResult is the result of running a job.
+type JobResult struct {
+	Name       string
+	InputUri   string
+	Output     interface{}
+	OutputUri  string
+	OutputSize int64
+	Variables  map[string]interface{}
+	Attempts   []*Attempt
+}
+
+// AttemptsMap returns the attempts as a slice of maps.
+func (jr JobResult) AttemptsMap() []map[string]interface{} {
+	attempts := make([]map[string]interface{}, 0, len(jr.Attempts))
+	for _, a := range jr.Attempts {
+		attempts = append(attempts, a.Map())
+	}
+	return attempts
+}
+
+// Map returns the result as a map, which is used as $jobs.<name> by downstream jobs.
+func (jr JobResult) Map() map[string]interface{} {
+	return map[string]interface{}{
+		"name":      jr.Name,
+		"input":     jr.InputUri,
+		"output":    jr.Output,
+		"uri":       jr.OutputUri,
+		"variables": jr.Variables,
+	}
+}
@@ -0,0 +1,61 @@
+// =================================================================
+//
+// Copyright (C) 2018 Spatial Current, Inc. - All Rights Reserved
+// Released as open source under the MIT License.  See LICENSE file.
+//
+// =================================================================
+
+package runner
+
+import (
+	"fmt"
+	"github.com/spatialcurrent/go-simple-serializer/gss"
+	"github.com/spatialcurrent/railgun/railgun/runs"
+)
+
+// RecordJob records the inputs, outputs, and attempts of a job in the run.
+// If the run is for the job itself, then the resolved variables are recorded, too.
+func RecordJob(run *runs.Run, jr *JobResult) {
+	if jr == nil {
+		return
+	}
+	if run.Type == "job" && jr.Variables != nil {
+		if m, ok := gss.StringifyMapKeys(jr.Variables).(map[string]interface{}); ok {
+			run.Variables = m
+		}
+	}
+	if len(jr.InputUri) > 0 {
+		run.Inputs = append(run.Inputs, jr.InputUri)
+	}
+	if len(jr.OutputUri) > 0 {
+		run.Outputs = append(run.Outputs, jr.OutputUri)
+	}
+	run.OutputSize += jr.OutputSize
+	run.Attempts += len(jr.Attempts)
+	for _, a := range jr.Attempts {
+		if a.Error != nil {
+			run.Log(fmt.Sprintf("job %s attempt %d failed after %s (%s): %s", jr.Name, a.Number, a.End.Sub(a.Start), a.Class, a.Error.Error()))
+		} else {
+			run.Log(fmt.Sprintf("job %s attempt %d succeeded after %s", jr.Name, a.Number, a.End.Sub(a.Start)))
+		}
+	}
+}
+
+// RecordWorkflow records the jobs of a workflow in the run.
+// The variables of the run are the resolved variables of each job, keyed by job name.
+func RecordWorkflow(run *runs.Run, wr *WorkflowResult) {
+	variables := map[string]interface{}{}
+	for _, jr := range wr.Jobs {
+		if upstream, ok := wr.Skipped[jr.Name]; ok {
+			run.Log(fmt.Sprintf("job %s skipped because upstream job %s failed", jr.Name, upstream))
+			continue
+		}
+		RecordJob(run, jr)
+		if jr.Variables != nil {
+			variables[jr.Name] = gss.StringifyMapKeys(jr.Variables)
+		}
+	}
+	if len(variables) > 0 {
+		run.Variables = variables
+	}
+}
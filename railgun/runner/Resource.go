@@ -0,0 +1,221 @@
+// =================================================================
+//
+// Copyright (C) 2018 Spatial Current, Inc. - All Rights Reserved
+// Released as open source under the MIT License.  See LICENSE file.
+//
+// =================================================================
+
+package runner
+
+import (
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"context"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/golang/snappy"
+	"github.com/mitchellh/go-homedir"
+	"github.com/pkg/errors"
+	"github.com/spatialcurrent/go-reader-writer/grw"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// resourceChunkSize is the size of the chunks written to local files, so writing stops soon after the context is done.
+const resourceChunkSize = 64 * 1024
+
+// contextReader returns the error of the context once it is done, so reading stops between reads.
+type contextReader struct {
+	ctx    context.Context
+	reader io.Reader
+}
+
+func (r *contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.reader.Read(p)
+}
+
+func splitBucketKey(path string) (string, string, error) {
+	parts := strings.SplitN(path, "/", 2)
+	if len(parts) != 2 {
+		return "", "", errors.New("path missing bucket")
+	}
+	return parts[0], parts[1], nil
+}
+
+func decompress(reader io.Reader, compression string) (io.Reader, error) {
+	switch compression {
+	case "gzip":
+		return gzip.NewReader(reader)
+	case "bzip2":
+		return bzip2.NewReader(reader), nil
+	case "snappy":
+		return snappy.NewReader(reader), nil
+	case "none", "":
+		return reader, nil
+	}
+	return nil, errors.New("unsupported compression " + compression)
+}
+
+// readResource reads the resource at the uri.  Reading stops once the context is done.
+func readResource(ctx context.Context, uri string, compression string, s3_client *s3.S3) ([]byte, error) {
+
+	scheme, path := grw.SplitUri(uri)
+
+	var body io.ReadCloser
+	switch scheme {
+	case "s3":
+		bucket, key, err := splitBucketKey(path)
+		if err != nil {
+			return nil, err
+		}
+		output, err := s3_client.GetObjectWithContext(ctx, &s3.GetObjectInput{Bucket: aws.String(bucket), Key: aws.String(key)})
+		if err != nil {
+			return nil, errors.Wrap(err, "error getting object at uri "+uri)
+		}
+		body = output.Body
+	case "", "file":
+		pathExpanded, err := homedir.Expand(path)
+		if err != nil {
+			return nil, errors.Wrap(err, "error expanding path "+path)
+		}
+		if compression == "zip" {
+			zipReader, err := grw.ReadZipFile(pathExpanded, false)
+			if err != nil {
+				return nil, errors.Wrap(err, "error opening zip file at path "+path)
+			}
+			defer zipReader.Close()
+			return ioutil.ReadAll(&contextReader{ctx: ctx, reader: zipReader})
+		}
+		f, err := os.Open(pathExpanded)
+		if err != nil {
+			return nil, errors.Wrap(err, "error opening file at path "+path)
+		}
+		body = f
+	default:
+		reader, _, err := grw.ReadFromResource(uri, compression, 4096, false, s3_client)
+		if err != nil {
+			return nil, errors.Wrap(err, "error opening resource at uri "+uri)
+		}
+		defer reader.Close()
+		return ioutil.ReadAll(&contextReader{ctx: ctx, reader: reader})
+	}
+	defer body.Close()
+
+	reader, err := decompress(&contextReader{ctx: ctx, reader: body}, compression)
+	if err != nil {
+		return nil, errors.Wrap(err, "error decompressing resource at uri "+uri)
+	}
+	return ioutil.ReadAll(&contextReader{ctx: ctx, reader: reader})
+}
+
+// writeResource writes the data to the resource at the uri.
+// Objects are uploaded with the context and local files are written to a temporary file that is renamed once complete,
+// so a write stopped by the context never leaves a partial resource and never runs after the call returns.
+func writeResource(ctx context.Context, uri string, compression string, data []byte, s3_client *s3.S3) error {
+
+	if len(compression) > 0 && compression != "none" {
+		writer, buffer, err := grw.WriteBytes(compression)
+		if err != nil {
+			return errors.Wrap(err, "error creating writer for compression "+compression)
+		}
+		if _, err := writer.Write(data); err != nil {
+			return errors.Wrap(err, "error compressing data")
+		}
+		if err := writer.Close(); err != nil {
+			return errors.Wrap(err, "error compressing data")
+		}
+		data = buffer.Bytes()
+	}
+
+	scheme, path := grw.SplitUri(uri)
+	switch scheme {
+	case "s3":
+		bucket, key, err := splitBucketKey(path)
+		if err != nil {
+			return err
+		}
+		_, err = s3_client.PutObjectWithContext(ctx, &s3.PutObjectInput{Bucket: aws.String(bucket), Key: aws.String(key), Body: bytes.NewReader(data)})
+		if err != nil {
+			return errors.Wrap(err, "error putting object at uri "+uri)
+		}
+		return nil
+	case "", "file":
+		pathExpanded, err := homedir.Expand(path)
+		if err != nil {
+			return errors.Wrap(err, "error expanding path "+path)
+		}
+		f, err := ioutil.TempFile(filepath.Dir(pathExpanded), "."+filepath.Base(pathExpanded)+".")
+		if err != nil {
+			return errors.Wrap(err, "error creating temporary file for path "+path)
+		}
+		tmp := f.Name()
+		err = writeChunks(ctx, f, data)
+		if err == nil {
+			err = f.Sync()
+		}
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err == nil {
+			err = ctx.Err()
+		}
+		if err != nil {
+			os.Remove(tmp)
+			return errors.Wrap(err, "error writing file at path "+path)
+		}
+		// temporary files are only readable by the owner, so the file is given the mode of the file it replaces, if any.
+		mode := os.FileMode(0644)
+		if info, err := os.Stat(pathExpanded); err == nil {
+			mode = info.Mode().Perm()
+		}
+		if err := os.Chmod(tmp, mode); err != nil {
+			os.Remove(tmp)
+			return errors.Wrap(err, "error setting mode of file at path "+path)
+		}
+		if err := os.Rename(tmp, pathExpanded); err != nil {
+			os.Remove(tmp)
+			return errors.Wrap(err, "error renaming file to path "+path)
+		}
+		return nil
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	writer, err := grw.WriteToResource(uri, "none", false, s3_client)
+	if err != nil {
+		return errors.Wrap(err, "error opening resource at uri "+uri)
+	}
+	if err := writeChunks(ctx, writer, data); err != nil {
+		writer.Close()
+		return errors.Wrap(err, "error writing to resource at uri "+uri)
+	}
+	if err := writer.Close(); err != nil {
+		return errors.Wrap(err, "error closing resource at uri "+uri)
+	}
+	return nil
+}
+
+func writeChunks(ctx context.Context, w io.Writer, data []byte) error {
+	for len(data) > 0 {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		n := resourceChunkSize
+		if n > len(data) {
+			n = len(data)
+		}
+		if _, err := w.Write(data[:n]); err != nil {
+			return err
+		}
+		data = data[n:]
+	}
+	return nil
+}
@@ -0,0 +1,354 @@
+// =================================================================
+//
+// Copyright (C) 2018 Spatial Current, Inc. - All Rights Reserved
+// Released as open source under the MIT License.  See LICENSE file.
+//
+// =================================================================
+
+package runner
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/pkg/errors"
+	"github.com/spatialcurrent/go-dfl/dfl"
+	"github.com/spatialcurrent/go-reader-writer/grw"
+	"github.com/spatialcurrent/go-simple-serializer/gss"
+	"github.com/spatialcurrent/railgun/railgun/core"
+	"github.com/spatialcurrent/railgun/railgun/keyring"
+	"github.com/spatialcurrent/railgun/railgun/metrics"
+	"github.com/spatialcurrent/railgun/railgun/tracing"
+	"github.com/spatialcurrent/railgun/railgun/util"
+	"regexp"
+	"time"
+)
+
+// Runner executes jobs and workflows from the catalog.
+type Runner struct {
+	GetS3Client func() (*s3.S3, error)
+	Metrics     *metrics.Metrics
+	Keyring     *keyring.Keyring // resolves the keys of encrypted data stores
+}
+
+func (r *Runner) s3Client(uri string) (*s3.S3, error) {
+	if scheme, _ := grw.SplitUri(uri); scheme != "s3" || r.GetS3Client == nil {
+		return nil, nil
+	}
+	s3_client, err := r.GetS3Client()
+	if err != nil {
+		return nil, errors.Wrap(err, "error connecting to AWS")
+	}
+	return s3_client, nil
+}
+
+// JobVariables returns the variables for a job, in order of precedence: service defaults, job variables, and then the given variables.
+// The results of upstream jobs are available as $jobs.
+func JobVariables(job *core.Job, variables map[string]interface{}, jobs map[string]interface{}) map[string]interface{} {
+	vars := map[string]interface{}{}
+	for k, v := range job.Service.Defaults {
+		vars[k] = v
+	}
+	for k, v := range job.Variables {
+		vars[k] = v
+	}
+	for k, v := range variables {
+		vars[k] = v
+	}
+	vars["jobs"] = jobs
+	return vars
+}
+
+// ReadInput reads the input object for the job.
+// If the job has an input expression, then the expression is evaluated with the job variables (including $jobs).
+// Otherwise, the input object is read from the data store of the job's service.
+// Reading stops once the context is done.
+func (r *Runner) ReadInput(ctx context.Context, job *core.Job, variables map[string]interface{}) (interface{}, string, error) {
+
+	if job.Input != nil {
+		var inputObject interface{}
+		err := withContext(ctx, func() error {
+			_, obj, err := job.Input.Evaluate(variables, map[string]interface{}{}, dfl.DefaultFunctionMap, dfl.DefaultQuotes)
+			inputObject = obj
+			return err
+		})
+		if err != nil {
+			return nil, "", classify(ctx, core.ErrorClassInput, errors.Wrap(err, "error evaluating input for job "+job.Name))
+		}
+		return inputObject, "", nil
+	}
+
+	_, inputUri, err := dfl.EvaluateString(job.Service.DataStore.Uri, variables, map[string]interface{}{}, dfl.DefaultFunctionMap, dfl.DefaultQuotes)
+	if err != nil {
+		return nil, "", classify(ctx, core.ErrorClassInput, errors.Wrap(err, "invalid data store uri"))
+	}
+
+	s3_client, err := r.s3Client(inputUri)
+	if err != nil {
+		return nil, inputUri, classify(ctx, core.ErrorClassInput, err)
+	}
+
+	start := time.Now()
+	inputBytes, err := readResource(ctx, inputUri, job.Service.DataStore.Compression, s3_client)
+	if err != nil {
+		return nil, inputUri, classify(ctx, core.ErrorClassInput, err)
+	}
+
+	passphrase, salt, err := r.Keyring.Lookup(job.Service.DataStore.Key)
+	if err != nil {
+		return nil, inputUri, classify(ctx, core.ErrorClassInput, errors.Wrap(err, "error resolving key for data store "+job.Service.DataStore.Name))
+	}
+	inputBytes, err = util.DecryptBytes(inputBytes, passphrase, salt, len(job.Service.DataStore.Key) > 0, r.Keyring)
+	if err != nil {
+		return nil, inputUri, classify(ctx, core.ErrorClassInput, errors.Wrap(err, "error decrypting input"))
+	}
+
+	inputFormat := job.Service.DataStore.Format
+
+	inputType, err := gss.GetType(inputBytes, inputFormat)
+	if err != nil {
+		return nil, inputUri, classify(ctx, core.ErrorClassInput, errors.Wrap(err, "error getting type for input"))
+	}
+
+	inputObject, err := gss.DeserializeBytes(inputBytes, inputFormat, gss.NoHeader, gss.NoComment, false, gss.NoSkip, gss.NoLimit, inputType, false)
+	if err != nil {
+		return nil, inputUri, classify(ctx, core.ErrorClassInput, errors.Wrap(err, "error deserializing input using format "+inputFormat))
+	}
+
+	r.Metrics.ObserveDataStoreRead(inputUri, time.Since(start))
+
+	return inputObject, inputUri, nil
+}
+
+// WriteOutput writes the output object to the output data store of the job and returns the uri and size of the written resource.
+// Writing stops once the context is done, and a local output is only replaced once completely written.
+func (r *Runner) WriteOutput(ctx context.Context, job *core.Job, variables map[string]interface{}, outputObject interface{}) (string, int64, error) {
+
+	outputBytes, err := gss.SerializeBytes(outputObject, job.Output.Format, gss.NoHeader, gss.NoLimit)
+	if err != nil {
+		return "", 0, classify(ctx, core.ErrorClassOutput, errors.Wrap(err, "error serializing output using format "+job.Output.Format))
+	}
+
+	if r.Keyring.HasMasterKey(job.Output.Key) {
+		keyId, key, err := r.Keyring.PrimaryDataKey(job.Output.Key)
+		if err != nil {
+			return "", 0, classify(ctx, core.ErrorClassOutput, errors.Wrap(err, "error resolving key for data store "+job.Output.Name))
+		}
+		outputBytes, err = util.EncryptBytesWithKey(outputBytes, keyId, key)
+		if err != nil {
+			return "", 0, classify(ctx, core.ErrorClassOutput, errors.Wrap(err, "error encrypting output"))
+		}
+	} else {
+		passphrase, salt, err := r.Keyring.Lookup(job.Output.Key)
+		if err != nil {
+			return "", 0, classify(ctx, core.ErrorClassOutput, errors.Wrap(err, "error resolving key for data store "+job.Output.Name))
+		}
+		outputBytes, err = util.EncryptBytes(outputBytes, passphrase, salt)
+		if err != nil {
+			return "", 0, classify(ctx, core.ErrorClassOutput, errors.Wrap(err, "error encrypting output"))
+		}
+	}
+
+	_, outputUri, err := dfl.EvaluateString(job.Output.Uri, variables, map[string]interface{}{}, dfl.DefaultFunctionMap, dfl.DefaultQuotes)
+	if err != nil {
+		return "", 0, classify(ctx, core.ErrorClassOutput, errors.Wrap(err, "error evaluating output uri"))
+	}
+
+	s3_client, err := r.s3Client(outputUri)
+	if err != nil {
+		return outputUri, 0, classify(ctx, core.ErrorClassOutput, err)
+	}
+
+	err = writeResource(ctx, outputUri, job.Output.Compression, outputBytes, s3_client)
+	if err != nil {
+		return outputUri, 0, classify(ctx, core.ErrorClassOutput, errors.Wrap(err, "error writing output for job "+job.Name))
+	}
+
+	return outputUri, int64(len(outputBytes)), nil
+}
+
+// attempt runs the job once, with the job's timeout if it has one.
+func (r *Runner) attempt(ctx context.Context, job *core.Job, vars map[string]interface{}, result *JobResult) error {
+
+	if job.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, job.Timeout)
+		defer cancel()
+	}
+
+	readCtx, span := tracing.Start(ctx, "runner.ReadInput")
+	inputObject, inputUri, err := r.ReadInput(readCtx, job, vars)
+	span.SetAttributes(tracing.AttributeDataStoreUri.String(inputUri))
+	tracing.End(span, err)
+	result.InputUri = inputUri
+	if err != nil {
+		return err
+	}
+
+	var outputVariables map[string]interface{}
+	var outputObject interface{}
+	_, span = tracing.Start(ctx, "process.Evaluate", tracing.AttributeProcess.String(job.Service.Process.Name))
+	err = withContext(ctx, func() error {
+		start := time.Now()
+		v, obj, err := job.Service.Process.Node.Evaluate(vars, inputObject, dfl.DefaultFunctionMap, dfl.DefaultQuotes)
+		r.Metrics.ObserveProcess(job.Service.Process.Name, time.Since(start))
+		outputVariables, outputObject = v, obj
+		return err
+	})
+	tracing.End(span, err)
+	if err != nil {
+		return classify(ctx, core.ErrorClassProcess, errors.Wrap(err, "error evaluating process with name "+job.Service.Process.Name))
+	}
+	delete(outputVariables, "jobs")
+
+	result.Output = outputObject
+	result.Variables = outputVariables
+
+	if job.Output != nil {
+		writeCtx, span := tracing.Start(ctx, "runner.WriteOutput")
+		outputUri, outputSize, err := r.WriteOutput(writeCtx, job, outputVariables, outputObject)
+		span.SetAttributes(tracing.AttributeDataStoreUri.String(outputUri), tracing.AttributeBytes.Int64(outputSize))
+		tracing.End(span, err)
+		result.OutputUri = outputUri
+		result.OutputSize = outputSize
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RunJob runs the job with the given variables and the results of upstream jobs, using the job's retry policy.
+func (r *Runner) RunJob(ctx context.Context, job *core.Job, variables map[string]interface{}, jobs map[string]interface{}) (*JobResult, error) {
+	return r.RunJobWithRetry(ctx, job, job.Retry, variables, jobs)
+}
+
+// RunJobWithRetry runs the job with the given retry policy, which can be nil.
+// Each attempt is recorded in the result.
+// Returns an error without running the job if the context is already done.
+func (r *Runner) RunJobWithRetry(ctx context.Context, job *core.Job, retry *core.Retry, variables map[string]interface{}, jobs map[string]interface{}) (*JobResult, error) {
+
+	result := &JobResult{Name: job.Name, Attempts: make([]*Attempt, 0)}
+
+	// invalid variables fail the same way on every attempt, so they are never retried.
+	vars, err := job.Service.Process.Validate(JobVariables(job, variables, jobs))
+	if err != nil {
+		return result, errors.Wrap(err, "invalid variables for job with name "+job.Name)
+	}
+
+	maxAttempts := 1
+	if retry != nil && retry.Attempts > 1 {
+		maxAttempts = retry.Attempts
+	}
+
+	for i := 1; ; i++ {
+
+		if err := ctx.Err(); err != nil {
+			return result, errors.Wrap(err, "job with name "+job.Name+" canceled")
+		}
+
+		// each attempt gets its own copy of the variables, since the evaluation of an abandoned attempt may still be running.
+		attemptVars := make(map[string]interface{}, len(vars))
+		for k, v := range vars {
+			attemptVars[k] = v
+		}
+
+		a := &Attempt{Number: i, Start: time.Now()}
+		attemptCtx, span := tracing.Start(ctx, "runner.Attempt", tracing.AttributeJob.String(job.Name), tracing.AttributeAttempt.Int(i))
+		err := r.attempt(attemptCtx, job, attemptVars, result)
+		tracing.End(span, err)
+		a.End = time.Now()
+		result.Attempts = append(result.Attempts, a)
+		if err == nil {
+			return result, nil
+		}
+		a.Class = ErrorClass(err)
+		a.Error = err
+
+		// don't retry if the parent context is done, since every attempt would fail.
+		if i >= maxAttempts || ctx.Err() != nil || !retry.Retryable(a.Class) {
+			return result, err
+		}
+
+		timer := time.NewTimer(retry.Delay(i))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return result, err
+		}
+	}
+}
+
+// RunWorkflow runs the jobs in the workflow in order.
+// The output and variables of each job are made available to later jobs as $jobs.<name>.output and $jobs.<name>.variables.
+// Jobs without their own retry policy use the workflow's retry policy.
+// If the context is done or the workflow's timeout is reached, then the remaining jobs are not run.
+// Jobs that reference a job that failed are skipped, and are reported with the name of the failed job.
+func (r *Runner) RunWorkflow(ctx context.Context, workflow *core.Workflow) *WorkflowResult {
+
+	if workflow.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, workflow.Timeout)
+		defer cancel()
+	}
+
+	ctx, span := tracing.Start(ctx, "runner.RunWorkflow", tracing.AttributeWorkflow.String(workflow.Name))
+	defer span.End()
+
+	result := NewWorkflowResult(workflow.Name)
+
+	jobs := map[string]interface{}{}
+
+	// failed is the failed job that caused each job to fail or be skipped, by job name.
+	failed := map[string]string{}
+
+	for _, job := range workflow.Jobs {
+		// jobs that depend on a job that failed or was skipped are skipped, since their input is missing.
+		if upstream := failedUpstream(job, failed); len(upstream) > 0 {
+			failed[job.Name] = upstream
+			result.Skip(&JobResult{Name: job.Name, Attempts: make([]*Attempt, 0)}, upstream)
+			continue
+		}
+		retry := job.Retry
+		if retry == nil {
+			retry = workflow.Retry
+		}
+		jobResult, err := r.RunJobWithRetry(ctx, job, retry, workflow.Variables, jobs)
+		result.Add(jobResult, err)
+		if err == nil {
+			jobs[job.Name] = jobResult.Map()
+		} else {
+			failed[job.Name] = job.Name
+		}
+	}
+
+	return result
+}
+
+// jobReference matches the references to upstream jobs in DFL expressions, e.g., $jobs.clean.output.
+var jobReference = regexp.MustCompile(`\$jobs\.([a-zA-Z0-9_\-]+)`)
+
+// failedUpstream returns the failed job that caused an upstream job referenced by the job to fail or be skipped,
+// or an empty string if no upstream job failed.
+// Upstream jobs are referenced by the input, the process, and the data store uri of the job.
+func failedUpstream(job *core.Job, failed map[string]string) string {
+	nodes := []dfl.Node{job.Input}
+	if job.Service.DataStore != nil {
+		nodes = append(nodes, job.Service.DataStore.Uri)
+	}
+	if job.Service.Process != nil {
+		nodes = append(nodes, job.Service.Process.Node)
+	}
+	for _, node := range nodes {
+		if node == nil {
+			continue
+		}
+		for _, match := range jobReference.FindAllStringSubmatch(node.Dfl(dfl.DefaultQuotes, false, 0), -1) {
+			if upstream, ok := failed[match[1]]; ok {
+				return upstream
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,96 @@
+// =================================================================
+//
+// Copyright (C) 2018 Spatial Current, Inc. - All Rights Reserved
+// Released as open source under the MIT License.  See LICENSE file.
+//
+// =================================================================
+
+package runner
+
+import (
+	"github.com/pkg/errors"
+)
+
+// WorkflowResult is the result of running a workflow.
+type WorkflowResult struct {
+	Name      string
+	Jobs      []*JobResult
+	ExitCodes map[string]int
+	Errors    map[string]error
+	Skipped   map[string]string // the failed upstream job of each skipped job
+}
+
+func NewWorkflowResult(name string) *WorkflowResult {
+	return &WorkflowResult{
+		Name:      name,
+		Jobs:      make([]*JobResult, 0),
+		ExitCodes: map[string]int{},
+		Errors:    map[string]error{},
+		Skipped:   map[string]string{},
+	}
+}
+
+// Add adds the result of a job to the workflow result.
+func (wr *WorkflowResult) Add(jobResult *JobResult, err error) {
+	wr.Jobs = append(wr.Jobs, jobResult)
+	if err != nil {
+		wr.ExitCodes[jobResult.Name] = 1
+		wr.Errors[jobResult.Name] = err
+	} else {
+		wr.ExitCodes[jobResult.Name] = 0
+	}
+}
+
+// Skip adds a job that was not run because the upstream job failed.
+func (wr *WorkflowResult) Skip(jobResult *JobResult, upstream string) {
+	wr.Jobs = append(wr.Jobs, jobResult)
+	wr.ExitCodes[jobResult.Name] = 1
+	wr.Errors[jobResult.Name] = errors.New("skipped because upstream job " + upstream + " failed")
+	wr.Skipped[jobResult.Name] = upstream
+}
+
+func (wr *WorkflowResult) Success() bool {
+	for _, exitCode := range wr.ExitCodes {
+		if exitCode > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (wr *WorkflowResult) Map() map[string]interface{} {
+	stderr := map[string]string{}
+	for _, jobResult := range wr.Jobs {
+		if err, ok := wr.Errors[jobResult.Name]; ok {
+			stderr[jobResult.Name] = err.Error()
+		} else {
+			stderr[jobResult.Name] = ""
+		}
+	}
+	attempts := map[string]interface{}{}
+	for _, jobResult := range wr.Jobs {
+		attempts[jobResult.Name] = jobResult.AttemptsMap()
+	}
+	results := map[string]interface{}{}
+	outputs := map[string]interface{}{}
+	for _, jobResult := range wr.Jobs {
+		if _, ok := wr.Errors[jobResult.Name]; ok {
+			continue
+		}
+		if len(jobResult.OutputUri) > 0 {
+			outputs[jobResult.Name] = jobResult.OutputUri
+		} else {
+			results[jobResult.Name] = jobResult.Output
+		}
+	}
+	return map[string]interface{}{
+		"success":   wr.Success(),
+		"message":   "workflow with name " + wr.Name + " completed.",
+		"exitCodes": wr.ExitCodes,
+		"stderr":    stderr,
+		"results":   results,
+		"outputs":   outputs,
+		"attempts":  attempts,
+		"skipped":   wr.Skipped,
+	}
+}
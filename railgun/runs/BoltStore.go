@@ -0,0 +1,145 @@
+// =================================================================
+//
+// Copyright (C) 2018 Spatial Current, Inc. - All Rights Reserved
+// Released as open source under the MIT License.  See LICENSE file.
+//
+// =================================================================
+
+package runs
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/mitchellh/go-homedir"
+	"github.com/pkg/errors"
+	bolt "go.etcd.io/bbolt"
+	"time"
+)
+
+var bucketRuns = []byte("runs")
+
+// BoltStore is a Store backed by a local bolt database file.
+// Runs are keyed by id, so the keys are in the order the runs were created.
+type BoltStore struct {
+	db *bolt.DB
+}
+
+func NewBoltStore(path string) (*BoltStore, error) {
+	pathExpanded, err := homedir.Expand(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "error expanding path "+path)
+	}
+	db, err := bolt.Open(pathExpanded, 0600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, errors.Wrap(err, "error opening runs database at "+path)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(bucketRuns)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, errors.Wrap(err, "error creating runs bucket")
+	}
+	return &BoltStore{db: db}, nil
+}
+
+func (s *BoltStore) Save(run *Run) error {
+	b, err := json.Marshal(run)
+	if err != nil {
+		// variables can hold values that cannot be marshaled, so fall back to their string representation.
+		c := *run
+		c.Variables = make(map[string]interface{}, len(run.Variables))
+		for k, v := range run.Variables {
+			c.Variables[k] = fmt.Sprint(v)
+		}
+		b, err = json.Marshal(&c)
+		if err != nil {
+			return errors.Wrap(err, "error marshaling run "+run.Id)
+		}
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketRuns).Put([]byte(run.Id), b)
+	})
+}
+
+func (s *BoltStore) Get(id string) (*Run, bool, error) {
+	var run *Run
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketRuns).Get([]byte(id))
+		if b == nil {
+			return nil
+		}
+		run = &Run{}
+		return json.Unmarshal(b, run)
+	})
+	if err != nil {
+		return nil, false, errors.Wrap(err, "error reading run "+id)
+	}
+	return run, run != nil, nil
+}
+
+func (s *BoltStore) List(q *Query) ([]*Run, error) {
+	runs := make([]*Run, 0)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(bucketRuns).Cursor()
+		for k, v := c.Last(); k != nil; k, v = c.Prev() {
+			if q.Limit > 0 && len(runs) >= q.Limit {
+				break
+			}
+			run := &Run{}
+			if err := json.Unmarshal(v, run); err != nil {
+				return errors.Wrap(err, "error reading run "+string(k))
+			}
+			if !q.Since.IsZero() && run.Created.Before(q.Since) {
+				break
+			}
+			if q.Match(run) {
+				runs = append(runs, run)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return make([]*Run, 0), err
+	}
+	return runs, nil
+}
+
+func (s *BoltStore) Prune(before time.Time, max int) (int, error) {
+	deleted := 0
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketRuns)
+		keys := make([][]byte, 0)
+		count := 0
+		c := b.Cursor()
+		for k, v := c.Last(); k != nil; k, v = c.Prev() {
+			count++
+			if max > 0 && count > max {
+				keys = append(keys, append([]byte{}, k...))
+				continue
+			}
+			if !before.IsZero() {
+				run := &Run{}
+				if err := json.Unmarshal(v, run); err != nil || run.Created.Before(before) {
+					keys = append(keys, append([]byte{}, k...))
+				}
+			}
+		}
+		for _, k := range keys {
+			if err := b.Delete(k); err != nil {
+				return err
+			}
+		}
+		deleted = len(keys)
+		return nil
+	})
+	if err != nil {
+		return 0, errors.Wrap(err, "error pruning runs")
+	}
+	return deleted, nil
+}
+
+func (s *BoltStore) Close() error {
+	return s.db.Close()
+}
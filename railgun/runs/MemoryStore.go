@@ -0,0 +1,100 @@
+// =================================================================
+//
+// Copyright (C) 2018 Spatial Current, Inc. - All Rights Reserved
+// Released as open source under the MIT License.  See LICENSE file.
+//
+// =================================================================
+
+package runs
+
+import (
+	"sync"
+	"time"
+)
+
+// MemoryStore is a Store that keeps runs in memory, in the order they were created.
+// Runs are lost when the server stops.
+type MemoryStore struct {
+	*sync.RWMutex
+	runs  []*Run
+	index map[string]int
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		RWMutex: &sync.RWMutex{},
+		runs:    make([]*Run, 0),
+		index:   map[string]int{},
+	}
+}
+
+// Save stores a copy of the run, so the caller can continue to update it.
+func (s *MemoryStore) Save(run *Run) error {
+	c := run.Copy()
+	s.Lock()
+	defer s.Unlock()
+	if i, ok := s.index[run.Id]; ok {
+		s.runs[i] = c
+	} else {
+		s.runs = append(s.runs, c)
+		s.index[run.Id] = len(s.runs) - 1
+	}
+	return nil
+}
+
+// Get returns a copy of the run, so the caller cannot change the stored run.
+func (s *MemoryStore) Get(id string) (*Run, bool, error) {
+	s.RLock()
+	defer s.RUnlock()
+	if i, ok := s.index[id]; ok {
+		return s.runs[i].Copy(), true, nil
+	}
+	return nil, false, nil
+}
+
+// List returns copies of the runs that match the query, from newest to oldest.
+func (s *MemoryStore) List(q *Query) ([]*Run, error) {
+	s.RLock()
+	defer s.RUnlock()
+	runs := make([]*Run, 0)
+	for i := len(s.runs) - 1; i >= 0; i-- {
+		if q.Limit > 0 && len(runs) >= q.Limit {
+			break
+		}
+		r := s.runs[i]
+		if !q.Since.IsZero() && r.Created.Before(q.Since) {
+			break
+		}
+		if q.Match(r) {
+			runs = append(runs, r.Copy())
+		}
+	}
+	return runs, nil
+}
+
+func (s *MemoryStore) Prune(before time.Time, max int) (int, error) {
+	s.Lock()
+	defer s.Unlock()
+	start := 0
+	if !before.IsZero() {
+		for start < len(s.runs) && s.runs[start].Created.Before(before) {
+			start++
+		}
+	}
+	if max > 0 && len(s.runs)-start > max {
+		start = len(s.runs) - max
+	}
+	if start == 0 {
+		return 0, nil
+	}
+	s.runs = append(make([]*Run, 0, len(s.runs)-start), s.runs[start:]...)
+	s.index = make(map[string]int, len(s.runs))
+	for i, r := range s.runs {
+		s.index[r.Id] = i
+	}
+	return start, nil
+}
+
+func (s *MemoryStore) Close() error {
+	return nil
+}
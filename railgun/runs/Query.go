@@ -0,0 +1,41 @@
+// =================================================================
+//
+// Copyright (C) 2018 Spatial Current, Inc. - All Rights Reserved
+// Released as open source under the MIT License.  See LICENSE file.
+//
+// =================================================================
+
+package runs
+
+import (
+	"time"
+)
+
+// Query filters the runs returned by a store.  Empty fields match all runs.
+type Query struct {
+	Type   string
+	Name   string
+	Status string
+	User   string    // only runs started by this user
+	Since  time.Time // only runs created at or after this time
+	Limit  int       // the maximum number of runs returned, zero for no limit
+}
+
+func (q *Query) Match(r *Run) bool {
+	if len(q.Type) > 0 && r.Type != q.Type {
+		return false
+	}
+	if len(q.Name) > 0 && r.Name != q.Name {
+		return false
+	}
+	if len(q.Status) > 0 && r.Status != q.Status {
+		return false
+	}
+	if len(q.User) > 0 && r.User != q.User {
+		return false
+	}
+	if !q.Since.IsZero() && r.Created.Before(q.Since) {
+		return false
+	}
+	return true
+}
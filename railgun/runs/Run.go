@@ -0,0 +1,148 @@
+// =================================================================
+//
+// Copyright (C) 2018 Spatial Current, Inc. - All Rights Reserved
+// Released as open source under the MIT License.  See LICENSE file.
+//
+// =================================================================
+
+package runs
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+)
+
+const (
+	StatusQueued    = "queued"
+	StatusRunning   = "running"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+	StatusSkipped   = "skipped"
+	StatusCanceled  = "canceled"
+)
+
+const (
+	TriggerSchedule = "schedule"
+	TriggerApi      = "api"
+)
+
+// Run is the record of a single execution of a service, job, or workflow.
+type Run struct {
+	Id         string                 `json:"id"`
+	Type       string                 `json:"type"` // service, job, or workflow
+	Name       string                 `json:"name"`
+	Trigger    string                 `json:"trigger"`
+	User       string                 `json:"user,omitempty"`
+	Status     string                 `json:"status"`
+	Created    time.Time              `json:"created"`
+	Scheduled  time.Time              `json:"scheduled,omitempty"`
+	Start      time.Time              `json:"start,omitempty"`
+	End        time.Time              `json:"end,omitempty"`
+	Attempts   int                    `json:"attempts,omitempty"`
+	Inputs     []string               `json:"inputs,omitempty"`
+	Variables  map[string]interface{} `json:"variables,omitempty"`
+	Outputs    []string               `json:"outputs,omitempty"`
+	OutputSize int64                  `json:"outputSize,omitempty"`
+	Error      string                 `json:"error,omitempty"`
+	Logs       []string               `json:"logs,omitempty"`
+}
+
+// NewRun returns a new queued run.
+// Run ids start with the creation time, so they sort in the order the runs were created.
+func NewRun(t string, name string, trigger string) *Run {
+	created := time.Now()
+	b := make([]byte, 8)
+	rand.Read(b)
+	return &Run{
+		Id:      fmt.Sprintf("%016x", created.UnixNano()) + hex.EncodeToString(b),
+		Type:    t,
+		Name:    name,
+		Trigger: trigger,
+		Status:  StatusQueued,
+		Created: created,
+	}
+}
+
+// Copy returns a copy of the run that does not share its inputs, variables, outputs, or logs with the run.
+func (r *Run) Copy() *Run {
+	c := *r
+	if r.Inputs != nil {
+		c.Inputs = append([]string{}, r.Inputs...)
+	}
+	if r.Variables != nil {
+		c.Variables = make(map[string]interface{}, len(r.Variables))
+		for k, v := range r.Variables {
+			c.Variables[k] = v
+		}
+	}
+	if r.Outputs != nil {
+		c.Outputs = append([]string{}, r.Outputs...)
+	}
+	if r.Logs != nil {
+		c.Logs = append([]string{}, r.Logs...)
+	}
+	return &c
+}
+
+// Done returns true if the run has completed, successfully or not.
+func (r *Run) Done() bool {
+	return r.Status == StatusSucceeded || r.Status == StatusFailed || r.Status == StatusSkipped || r.Status == StatusCanceled
+}
+
+func (r *Run) Duration() time.Duration {
+	if r.Start.IsZero() || r.End.IsZero() {
+		return 0
+	}
+	return r.End.Sub(r.Start)
+}
+
+// Log appends a timestamped line to the logs of the run.
+func (r *Run) Log(line string) {
+	r.Logs = append(r.Logs, time.Now().Format(time.RFC3339)+" "+line)
+}
+
+// Map returns the run as a map, without the logs.
+func (r *Run) Map() map[string]interface{} {
+	m := map[string]interface{}{
+		"id":      r.Id,
+		"type":    r.Type,
+		"name":    r.Name,
+		"trigger": r.Trigger,
+		"status":  r.Status,
+		"created": r.Created.Format(time.RFC3339),
+	}
+	if len(r.User) > 0 {
+		m["user"] = r.User
+	}
+	if !r.Scheduled.IsZero() {
+		m["scheduled"] = r.Scheduled.Format(time.RFC3339)
+	}
+	if !r.Start.IsZero() {
+		m["start"] = r.Start.Format(time.RFC3339)
+	}
+	if !r.End.IsZero() {
+		m["end"] = r.End.Format(time.RFC3339)
+		m["duration"] = r.Duration().String()
+	}
+	if r.Attempts > 0 {
+		m["attempts"] = r.Attempts
+	}
+	if len(r.Inputs) > 0 {
+		m["inputs"] = r.Inputs
+	}
+	if len(r.Variables) > 0 {
+		m["variables"] = r.Variables
+	}
+	if len(r.Outputs) > 0 {
+		m["outputs"] = r.Outputs
+	}
+	if r.OutputSize > 0 {
+		m["outputSize"] = r.OutputSize
+	}
+	if len(r.Error) > 0 {
+		m["error"] = r.Error
+	}
+	return m
+}
@@ -0,0 +1,27 @@
+// =================================================================
+//
+// Copyright (C) 2018 Spatial Current, Inc. - All Rights Reserved
+// Released as open source under the MIT License.  See LICENSE file.
+//
+// =================================================================
+
+package runs
+
+import (
+	"time"
+)
+
+// Store records runs.
+type Store interface {
+	// Save adds or updates a run.
+	Save(run *Run) error
+	// Get returns the run with the given id.
+	Get(id string) (*Run, bool, error)
+	// List returns the runs matching the query, most recent first.
+	List(q *Query) ([]*Run, error)
+	// Prune deletes runs created before the given time and all but the max most recent runs.
+	// If before is zero or max is zero, then that condition is ignored.
+	// Returns the number of runs deleted.
+	Prune(before time.Time, max int) (int, error)
+	Close() error
+}
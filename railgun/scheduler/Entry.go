@@ -0,0 +1,66 @@
+// =================================================================
+//
+// Copyright (C) 2018 Spatial Current, Inc. - All Rights Reserved
+// Released as open source under the MIT License.  See LICENSE file.
+//
+// =================================================================
+
+package scheduler
+
+import (
+	"context"
+	"github.com/spatialcurrent/railgun/railgun/core"
+	"github.com/spatialcurrent/railgun/railgun/runs"
+	"sync"
+	"time"
+)
+
+// Entry is a scheduled job or workflow.
+type Entry struct {
+	*sync.Mutex
+	Type     string // job or workflow
+	Name     string
+	Schedule *core.Schedule
+	Next     time.Time // the next time the schedule fires
+	Previous time.Time // the last time the schedule fired
+	running  *runs.Run
+	cancel   context.CancelFunc
+	queue    []*runs.Run
+	stop     chan struct{}
+}
+
+func NewEntry(t string, name string, schedule *core.Schedule) *Entry {
+	return &Entry{
+		Mutex:    &sync.Mutex{},
+		Type:     t,
+		Name:     name,
+		Schedule: schedule,
+		queue:    make([]*runs.Run, 0),
+		stop:     make(chan struct{}),
+	}
+}
+
+func (e *Entry) Key() string {
+	return e.Type + ":" + e.Name
+}
+
+func (e *Entry) Map() map[string]interface{} {
+	e.Lock()
+	defer e.Unlock()
+	m := map[string]interface{}{
+		"type":     e.Type,
+		"name":     e.Name,
+		"schedule": e.Schedule.Map(),
+		"queued":   len(e.queue),
+	}
+	if !e.Next.IsZero() {
+		m["next"] = e.Next.Format(time.RFC3339)
+	}
+	if !e.Previous.IsZero() {
+		m["previous"] = e.Previous.Format(time.RFC3339)
+	}
+	if e.running != nil {
+		m["running"] = e.running.Id
+	}
+	return m
+}
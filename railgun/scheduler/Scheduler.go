@@ -0,0 +1,340 @@
+// =================================================================
+//
+// Copyright (C) 2018 Spatial Current, Inc. - All Rights Reserved
+// Released as open source under the MIT License.  See LICENSE file.
+//
+// =================================================================
+
+package scheduler
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"github.com/spatialcurrent/railgun/railgun/catalog"
+	"github.com/spatialcurrent/railgun/railgun/core"
+	rerrors "github.com/spatialcurrent/railgun/railgun/errors"
+	"github.com/spatialcurrent/railgun/railgun/runner"
+	"github.com/spatialcurrent/railgun/railgun/runs"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Scheduler runs the jobs and workflows in the catalog that have a schedule.
+// The catalog is synced every SyncInterval, so schedules added, changed, or removed through the API take effect without a restart.
+type Scheduler struct {
+	*sync.Mutex
+	Catalog      *catalog.RailgunCatalog
+	Runner       *runner.Runner
+	Runs         runs.Store
+	Messages     chan interface{}
+	Errors       chan error
+	SyncInterval time.Duration
+	entries      map[string]*Entry
+	ctx          context.Context
+	cancel       context.CancelFunc
+}
+
+func NewScheduler(railgunCatalog *catalog.RailgunCatalog, r *runner.Runner, store runs.Store, messages chan interface{}, errorsChannel chan error, syncInterval time.Duration) *Scheduler {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Scheduler{
+		Mutex:        &sync.Mutex{},
+		Catalog:      railgunCatalog,
+		Runner:       r,
+		Runs:         store,
+		Messages:     messages,
+		Errors:       errorsChannel,
+		SyncInterval: syncInterval,
+		entries:      map[string]*Entry{},
+		ctx:          ctx,
+		cancel:       cancel,
+	}
+}
+
+// Start syncs the schedules from the catalog and starts the scheduler in the background.
+func (s *Scheduler) Start() {
+	s.Sync()
+	go func() {
+		ticker := time.NewTicker(s.SyncInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				s.Sync()
+			case <-s.ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops all schedules and cancels running and queued runs.
+func (s *Scheduler) Stop() {
+	s.Lock()
+	defer s.Unlock()
+	s.cancel()
+	for key, e := range s.entries {
+		s.remove(e)
+		delete(s.entries, key)
+	}
+}
+
+// Sync adds, updates, and removes entries to match the schedules in the catalog.
+func (s *Scheduler) Sync() {
+
+	schedules := map[string]*Entry{}
+	s.Catalog.Lock()
+	for _, j := range s.Catalog.ListJobs() {
+		if j.Schedule != nil {
+			e := NewEntry("job", j.Name, j.Schedule)
+			schedules[e.Key()] = e
+		}
+	}
+	for _, wf := range s.Catalog.ListWorkflows() {
+		if wf.Schedule != nil {
+			e := NewEntry("workflow", wf.Name, wf.Schedule)
+			schedules[e.Key()] = e
+		}
+	}
+	s.Catalog.Unlock()
+
+	s.Lock()
+	defer s.Unlock()
+
+	if s.ctx.Err() != nil {
+		return
+	}
+
+	for key, e := range s.entries {
+		if _, ok := schedules[key]; !ok {
+			s.remove(e)
+			delete(s.entries, key)
+		}
+	}
+
+	for key, n := range schedules {
+		if e, ok := s.entries[key]; ok {
+			e.Lock()
+			if !e.Schedule.Equal(n.Schedule) {
+				close(e.stop)
+				e.stop = make(chan struct{})
+				e.Schedule = n.Schedule
+				go s.watch(e, e.stop)
+			}
+			e.Unlock()
+		} else {
+			s.entries[key] = n
+			go s.watch(n, n.stop)
+		}
+	}
+}
+
+// remove stops the entry and cancels its running and queued runs.
+func (s *Scheduler) remove(e *Entry) {
+	e.Lock()
+	defer e.Unlock()
+	close(e.stop)
+	if e.cancel != nil {
+		e.cancel()
+	}
+	for _, run := range e.queue {
+		run.Status = runs.StatusCanceled
+		run.End = time.Now()
+		s.save(run)
+	}
+	e.queue = make([]*runs.Run, 0)
+}
+
+// List returns the entries with their last run, sorted by type and name.
+func (s *Scheduler) List() []map[string]interface{} {
+	s.Lock()
+	entries := make([]*Entry, 0, len(s.entries))
+	for _, e := range s.entries {
+		entries = append(entries, e)
+	}
+	s.Unlock()
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Key() < entries[j].Key()
+	})
+	items := make([]map[string]interface{}, 0, len(entries))
+	for _, e := range entries {
+		m := e.Map()
+		if last, err := s.Runs.List(&runs.Query{Type: e.Type, Name: e.Name, Limit: 1}); err == nil && len(last) > 0 {
+			m["lastRun"] = last[0].Map()
+		}
+		items = append(items, m)
+	}
+	return items
+}
+
+// watch fires the entry at each time of its schedule, until stop is closed.
+func (s *Scheduler) watch(e *Entry, stop chan struct{}) {
+	for {
+		e.Lock()
+		schedule := e.Schedule
+		next := schedule.Next(time.Now())
+		e.Next = next
+		e.Unlock()
+
+		// the schedule never fires again, e.g., a date that has passed.
+		if next.IsZero() {
+			return
+		}
+
+		delay := time.Until(next)
+		if schedule.Jitter > 0 {
+			delay += time.Duration(rand.Int63n(int64(schedule.Jitter)))
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+			s.fire(e, next)
+		case <-stop:
+			timer.Stop()
+			return
+		}
+	}
+}
+
+// fire creates a new run for the entry and applies the overlap policy if the previous run is still running.
+func (s *Scheduler) fire(e *Entry, scheduled time.Time) {
+	e.Lock()
+	defer e.Unlock()
+
+	e.Previous = scheduled
+
+	run := runs.NewRun(e.Type, e.Name, runs.TriggerSchedule)
+	run.Scheduled = scheduled
+
+	if e.running != nil {
+		switch e.Schedule.Overlap {
+		case core.OverlapQueue:
+			e.queue = append(e.queue, run)
+			s.save(run)
+			return
+		case core.OverlapReplace:
+			e.cancel()
+		default:
+			run.Status = runs.StatusSkipped
+			run.Error = "previous run " + e.running.Id + " is still running"
+			run.End = time.Now()
+			s.save(run)
+			return
+		}
+	}
+
+	s.start(e, run)
+}
+
+// start starts the run in the background.  The caller must hold the lock on the entry.
+func (s *Scheduler) start(e *Entry, run *runs.Run) {
+	ctx, cancel := context.WithCancel(s.ctx)
+	e.running = run
+	e.cancel = cancel
+	run.Status = runs.StatusRunning
+	run.Start = time.Now()
+	s.save(run)
+	go func() {
+		err := s.execute(ctx, run)
+		cancel()
+
+		e.Lock()
+		run.End = time.Now()
+		if err != nil {
+			if ctx.Err() != nil {
+				run.Status = runs.StatusCanceled
+			} else {
+				run.Status = runs.StatusFailed
+			}
+			run.Error = err.Error()
+			err = errors.Wrap(err, "scheduled run "+run.Id+" of "+e.Type+" "+e.Name+" "+run.Status)
+		} else {
+			run.Status = runs.StatusSucceeded
+		}
+		s.save(run)
+
+		// if replaced, the new run is already running.
+		if e.running == run {
+			e.running = nil
+			e.cancel = nil
+			if len(e.queue) > 0 && s.ctx.Err() == nil {
+				next := e.queue[0]
+				e.queue = e.queue[1:]
+				s.start(e, next)
+			}
+		}
+		e.Unlock()
+
+		// the result is sent once the entry is unlocked, so a full channel never blocks Stop or Sync.
+		if err != nil {
+			s.sendError(err)
+		} else {
+			s.sendMessage("scheduled run " + run.Id + " of " + e.Type + " " + e.Name + " succeeded")
+		}
+	}()
+}
+
+// execute runs the job or workflow with the current definition from the catalog and records the results in the run.
+func (s *Scheduler) execute(ctx context.Context, run *runs.Run) error {
+	switch run.Type {
+	case "job":
+		s.Catalog.Lock()
+		job, ok := s.Catalog.GetJob(run.Name)
+		s.Catalog.Unlock()
+		if !ok {
+			return &rerrors.ErrMissingObject{Type: "job", Name: run.Name}
+		}
+		result, err := s.Runner.RunJob(ctx, job, map[string]interface{}{}, map[string]interface{}{})
+		runner.RecordJob(run, result)
+		return err
+	case "workflow":
+		s.Catalog.Lock()
+		workflow, ok := s.Catalog.GetWorkflow(run.Name)
+		s.Catalog.Unlock()
+		if !ok {
+			return &rerrors.ErrMissingObject{Type: "workflow", Name: run.Name}
+		}
+		result := s.Runner.RunWorkflow(ctx, workflow)
+		runner.RecordWorkflow(run, result)
+		if !result.Success() {
+			msgs := make([]string, 0, len(result.Errors))
+			for _, jobResult := range result.Jobs {
+				if err, ok := result.Errors[jobResult.Name]; ok {
+					msgs = append(msgs, jobResult.Name+": "+err.Error())
+				}
+			}
+			if err := ctx.Err(); err != nil {
+				return errors.Wrap(err, strings.Join(msgs, "; "))
+			}
+			return errors.New(strings.Join(msgs, "; "))
+		}
+		return nil
+	}
+	return errors.New("unknown schedule type " + run.Type)
+}
+
+func (s *Scheduler) save(run *runs.Run) {
+	if err := s.Runs.Save(run); err != nil {
+		s.sendError(errors.Wrap(err, "error saving run "+run.Id))
+	}
+}
+
+// sendError sends the error without blocking, since errors are sent while holding locks.
+// The error is dropped if the channel is full.
+func (s *Scheduler) sendError(err error) {
+	select {
+	case s.Errors <- err:
+	default:
+	}
+}
+
+// sendMessage sends the message without blocking.  The message is dropped if the channel is full.
+func (s *Scheduler) sendMessage(msg interface{}) {
+	select {
+	case s.Messages <- msg:
+	default:
+	}
+}
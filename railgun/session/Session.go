@@ -0,0 +1,37 @@
+// =================================================================
+//
+// Copyright (C) 2018 Spatial Current, Inc. - All Rights Reserved
+// Released as open source under the MIT License.  See LICENSE file.
+//
+// =================================================================
+
+package session
+
+import (
+	"sync"
+	"time"
+)
+
+// Session is the variable state of a service for a single user.
+// Calls within a session are serialized with the session's lock.
+type Session struct {
+	*sync.Mutex
+	Id        string
+	Service   string
+	User      string
+	TTL       time.Duration
+	Created   time.Time
+	Expires   time.Time
+	Variables map[string]interface{}
+}
+
+func (s *Session) Map() map[string]interface{} {
+	return map[string]interface{}{
+		"session": s.Id,
+		"service": s.Service,
+		"user":    s.User,
+		"ttl":     s.TTL.String(),
+		"created": s.Created.Format(time.RFC3339),
+		"expires": s.Expires.Format(time.RFC3339),
+	}
+}
@@ -0,0 +1,69 @@
+// =================================================================
+//
+// Copyright (C) 2018 Spatial Current, Inc. - All Rights Reserved
+// Released as open source under the MIT License.  See LICENSE file.
+//
+// =================================================================
+
+package session
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	gocache "github.com/patrickmn/go-cache"
+	"sync"
+	"time"
+)
+
+// Store keeps sessions in memory until they expire.
+// The expiration of a session is extended by its TTL every time it is used.
+type Store struct {
+	cache *gocache.Cache
+}
+
+func NewStore(cleanupInterval time.Duration) *Store {
+	return &Store{cache: gocache.New(gocache.NoExpiration, cleanupInterval)}
+}
+
+// Create creates a new session for the user of the service.
+func (s *Store) Create(service string, user string, ttl time.Duration, variables map[string]interface{}) *Session {
+	b := make([]byte, 16)
+	rand.Read(b)
+	now := time.Now()
+	sess := &Session{
+		Mutex:     &sync.Mutex{},
+		Id:        hex.EncodeToString(b),
+		Service:   service,
+		User:      user,
+		TTL:       ttl,
+		Created:   now,
+		Expires:   now.Add(ttl),
+		Variables: variables,
+	}
+	s.cache.Set(sess.Id, sess, ttl)
+	return sess
+}
+
+// Get returns the session with the given id, only if it belongs to the given service and user.
+func (s *Store) Get(id string, service string, user string) (*Session, bool) {
+	obj, found := s.cache.Get(id)
+	if !found {
+		return nil, false
+	}
+	sess := obj.(*Session)
+	if sess.Service != service || sess.User != user {
+		return nil, false
+	}
+	return sess, true
+}
+
+// Touch extends the expiration of the session by its TTL.
+// The caller must hold the lock on the session.
+func (s *Store) Touch(sess *Session) {
+	sess.Expires = time.Now().Add(sess.TTL)
+	s.cache.Set(sess.Id, sess, sess.TTL)
+}
+
+func (s *Store) Delete(id string) {
+	s.cache.Delete(id)
+}
@@ -0,0 +1,184 @@
+// =================================================================
+//
+// Copyright (C) 2018 Spatial Current, Inc. - All Rights Reserved
+// Released as open source under the MIT License.  See LICENSE file.
+//
+// =================================================================
+
+// Package tracing configures OpenTelemetry tracing for railgun.
+package tracing
+
+import (
+	"context"
+	"github.com/mitchellh/go-homedir"
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+)
+
+const (
+	ExporterNone   = "none"
+	ExporterOtlp   = "otlp"
+	ExporterStdout = "stdout"
+)
+
+var Exporters = []string{ExporterNone, ExporterOtlp, ExporterStdout}
+
+// Attribute keys used by the spans of railgun.
+const (
+	AttributeLayer        = attribute.Key("railgun.layer")
+	AttributeTile         = attribute.Key("railgun.tile")
+	AttributeDataStoreUri = attribute.Key("railgun.datastore.uri")
+	AttributeFormat       = attribute.Key("railgun.format")
+	AttributeFeatures     = attribute.Key("railgun.features")
+	AttributeCacheHit     = attribute.Key("railgun.cache.hit")
+	AttributeProcess      = attribute.Key("railgun.process")
+	AttributeJob          = attribute.Key("railgun.job")
+	AttributeWorkflow     = attribute.Key("railgun.workflow")
+	AttributeAttempt      = attribute.Key("railgun.attempt")
+	AttributeBytes        = attribute.Key("railgun.bytes")
+)
+
+const (
+	instrumentationName = "github.com/spatialcurrent/railgun"
+	defaultServiceName  = "railgun"
+	defaultOtlpEndpoint = "localhost:4318"
+)
+
+// Config is the configuration of tracing.
+// Endpoint is the host and port of the OTLP/HTTP collector, e.g., localhost:4318.
+// Uri is the destination of the stdout exporter, which is stdout, stderr, or a file path.
+type Config struct {
+	Exporter    string
+	Endpoint    string
+	Insecure    bool
+	Uri         string
+	SampleRatio float64
+	ServiceName string
+}
+
+// Init sets the global tracer provider and the W3C trace context propagator.
+// Returns a function that flushes and stops the exporter.
+// If the exporter is none, then spans are not recorded, but trace context is still propagated.
+func Init(config Config) (func(context.Context) error, error) {
+
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var exporter sdktrace.SpanExporter
+	var closer io.Closer
+	switch config.Exporter {
+	case ExporterNone, "":
+		return func(ctx context.Context) error { return nil }, nil
+	case ExporterOtlp:
+		options := make([]otlptracehttp.Option, 0)
+		// if no endpoint is given, then the exporter uses OTEL_EXPORTER_OTLP_ENDPOINT or the default.
+		if len(config.Endpoint) > 0 {
+			options = append(options, otlptracehttp.WithEndpoint(config.Endpoint))
+		} else if len(os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")) == 0 {
+			options = append(options, otlptracehttp.WithEndpoint(defaultOtlpEndpoint))
+		}
+		if config.Insecure {
+			options = append(options, otlptracehttp.WithInsecure())
+		}
+		e, err := otlptracehttp.New(context.Background(), options...)
+		if err != nil {
+			return nil, errors.Wrap(err, "error creating OTLP exporter")
+		}
+		exporter = e
+	case ExporterStdout:
+		w, c, err := openWriter(config.Uri)
+		if err != nil {
+			return nil, err
+		}
+		e, err := stdouttrace.New(stdouttrace.WithWriter(w))
+		if err != nil {
+			return nil, errors.Wrap(err, "error creating stdout exporter")
+		}
+		exporter, closer = e, c
+	default:
+		return nil, errors.New("unknown trace exporter " + config.Exporter + ", expecting one of " + strings.Join(Exporters, ", "))
+	}
+
+	serviceName := config.ServiceName
+	if len(serviceName) == 0 {
+		serviceName = defaultServiceName
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(config.SampleRatio))),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", serviceName))),
+	)
+	otel.SetTracerProvider(provider)
+
+	return func(ctx context.Context) error {
+		err := provider.Shutdown(ctx)
+		if closer != nil {
+			closer.Close()
+		}
+		return err
+	}, nil
+}
+
+// openWriter opens the destination of the stdout exporter.
+func openWriter(uri string) (io.Writer, io.Closer, error) {
+	switch uri {
+	case "", "stdout":
+		return os.Stdout, nil, nil
+	case "stderr":
+		return os.Stderr, nil, nil
+	}
+	path, err := homedir.Expand(strings.TrimPrefix(uri, "file://"))
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "error expanding trace uri "+uri)
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "error opening trace uri "+uri)
+	}
+	return f, f, nil
+}
+
+// Start starts a span with the given name as a child of the span in the context, if any.
+func Start(ctx context.Context, name string, attributes ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentationName).Start(ctx, name, trace.WithAttributes(attributes...))
+}
+
+// End records the error, if not nil, and ends the span.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// SetAttributes sets the attributes on the span in the context, if any.
+func SetAttributes(ctx context.Context, attributes ...attribute.KeyValue) {
+	trace.SpanFromContext(ctx).SetAttributes(attributes...)
+}
+
+// TraceId returns the id of the trace in the context, or an empty string if the context has no trace.
+func TraceId(ctx context.Context) string {
+	if sc := trace.SpanContextFromContext(ctx); sc.HasTraceID() {
+		return sc.TraceID().String()
+	}
+	return ""
+}
+
+// StartRequest starts a server span for the HTTP request, continuing the trace in the W3C traceparent header of the request, if any.
+func StartRequest(r *http.Request, name string, attributes ...attribute.KeyValue) (context.Context, trace.Span) {
+	ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+	return otel.Tracer(instrumentationName).Start(ctx, name, trace.WithSpanKind(trace.SpanKindServer), trace.WithAttributes(attributes...))
+}
@@ -0,0 +1,35 @@
+// =================================================================
+//
+// Copyright (C) 2018 Spatial Current, Inc. - All Rights Reserved
+// Released as open source under the MIT License.  See LICENSE file.
+//
+// =================================================================
+
+package util
+
+// ContentTypes maps a format, as returned by SplitNameFormatCompression, to its content type.
+var ContentTypes = map[string]string{
+	"bson":     "application/ubjson",
+	"csv":      "text/csv; charset=utf-8",
+	"geojson":  "application/geo+json",
+	"geojsonl": "application/geo+json-seq",
+	"html":     "text/html",
+	"jpeg":     "image/jpeg",
+	"jpg":      "image/jpeg",
+	"json":     "application/json",
+	"jsonl":    "application/x-ndjson",
+	"msgpack":  "application/msgpack",
+	"png":      "image/png",
+	"toml":     "application/toml",
+	"tsv":      "text/tab-separated-values; charset=utf-8",
+	"yaml":     "text/yaml",
+	"yml":      "text/yaml",
+}
+
+// ContentType returns the content type for the format, or "text/plain; charset=utf-8" if the format is unknown.
+func ContentType(format string) string {
+	if contentType, ok := ContentTypes[format]; ok {
+		return contentType
+	}
+	return "text/plain; charset=utf-8"
+}
@@ -12,7 +12,6 @@ import (
 	"crypto/cipher"
 	"encoding/hex"
 	"github.com/pkg/errors"
-	"golang.org/x/crypto/argon2"
 )
 
 // CreateCipher returns a cipher.Block given a salt and passphrase
@@ -23,7 +22,7 @@ func CreateCipher(salt_string string, passphrase_string string) (cipher.Block, e
 	if err != nil {
 		return nil, errors.Wrap(err, "invalid salt "+salt_string)
 	}
-	key := argon2.Key([]byte(passphrase_string), salt, 3, 32*1024, 4, 32)
+	key := DefaultKdfParams.Key(passphrase_string, salt)
 	block, err := aes.NewCipher(key)
 	if err != nil {
 		return block, errors.Wrap(err, "error creating new AES256 cipher")
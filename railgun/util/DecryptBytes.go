@@ -8,14 +8,33 @@
 package util
 
 import (
+	"bytes"
 	"crypto/aes"
 	"crypto/cipher"
 	"github.com/pkg/errors"
+	"io/ioutil"
 )
 
-func DecryptBytes(input []byte, passphrase string, salt string) ([]byte, error) {
+// DecryptBytes returns the plain text of the input.
+// Envelopes are decrypted with the passphrase or the data key in the header resolved by the keys.
+// Other data is decrypted as legacy AES-CFB if the passphrase is not blank.
+// Otherwise, the data is returned as is, unless required is true because the data store declares a key.
+func DecryptBytes(input []byte, passphrase string, salt string, required bool, keys KeyResolver) ([]byte, error) {
+
+	if IsEnvelope(input) {
+		r, err := NewEnvelopeReader(bytes.NewReader(input), passphrase, keys)
+		if err != nil {
+			return make([]byte, 0), err
+		}
+		plain, err := ioutil.ReadAll(r)
+		if err != nil {
+			return make([]byte, 0), err
+		}
+		return plain, nil
+	}
 
 	if len(passphrase) > 0 {
+		// legacy data is encrypted with AES-CFB without authentication
 		block, err := CreateCipher(salt, passphrase)
 		if err != nil {
 			return make([]byte, 0), errors.New("error creating cipher for input passphrase")
@@ -31,5 +50,9 @@ func DecryptBytes(input []byte, passphrase string, salt string) ([]byte, error)
 		return ciphertext, nil
 	}
 
+	if required {
+		return make([]byte, 0), errors.New("data is not encrypted, but a key is declared for it")
+	}
+
 	return input, nil
 }
@@ -5,13 +5,13 @@ import (
 	"github.com/spatialcurrent/go-reader-writer/grw"
 )
 
-func DecryptReader(reader grw.ByteReadCloser, passphrase string, salt string) ([]byte, error) {
+func DecryptReader(reader grw.ByteReadCloser, passphrase string, salt string, required bool, keys KeyResolver) ([]byte, error) {
 	encrypted, err := reader.ReadAllAndClose()
 	if err != nil {
 		return make([]byte, 0), errors.Wrap(err, "error reading from resource")
 	}
 
-	plain, err := DecryptBytes(encrypted, passphrase, salt)
+	plain, err := DecryptBytes(encrypted, passphrase, salt, required, keys)
 	if err != nil {
 		return make([]byte, 0), errors.Wrap(err, "error decoding input")
 	}
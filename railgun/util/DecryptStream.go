@@ -0,0 +1,45 @@
+// =================================================================
+//
+// Copyright (C) 2018 Spatial Current, Inc. - All Rights Reserved
+// Released as open source under the MIT License.  See LICENSE file.
+//
+// =================================================================
+
+package util
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"github.com/pkg/errors"
+	"io"
+)
+
+// DecryptStream returns a reader of the plain text of data encrypted by EncryptBytes, so large data can be decrypted while it is read.
+// Envelopes are decrypted with the passphrase or the data key in the header resolved by the keys.
+// Other data is decrypted as legacy AES-CFB if the passphrase is not blank.
+// Otherwise, the data is read as is, unless required is true because the data store declares a key.
+func DecryptStream(reader io.Reader, passphrase string, salt string, required bool, keys KeyResolver) (io.Reader, error) {
+
+	br := bufio.NewReader(reader)
+	if magic, _ := br.Peek(len(EnvelopeMagic)); IsEnvelope(magic) {
+		return NewEnvelopeReader(br, passphrase, keys)
+	}
+
+	if len(passphrase) == 0 {
+		if required {
+			return nil, errors.New("data is not encrypted, but a key is declared for it")
+		}
+		return br, nil
+	}
+
+	block, err := CreateCipher(salt, passphrase)
+	if err != nil {
+		return nil, errors.New("error creating cipher for input passphrase")
+	}
+	iv := make([]byte, aes.BlockSize)
+	if _, err := io.ReadFull(br, iv); err != nil {
+		return nil, errors.Wrap(err, "error reading iv")
+	}
+	return &cipher.StreamReader{S: cipher.NewCFBDecrypter(block, iv), R: br}, nil
+}
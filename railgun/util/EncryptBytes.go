@@ -0,0 +1,50 @@
+// =================================================================
+//
+// Copyright (C) 2018 Spatial Current, Inc. - All Rights Reserved
+// Released as open source under the MIT License.  See LICENSE file.
+//
+// =================================================================
+
+package util
+
+import (
+	"bytes"
+)
+
+// EncryptBytes encrypts the input as an envelope using AES-256-GCM, which can be decrypted by DecryptBytes.
+// If the passphrase is blank, then the input is returned as is.
+func EncryptBytes(input []byte, passphrase string, salt string) ([]byte, error) {
+
+	if len(passphrase) == 0 {
+		return input, nil
+	}
+
+	buf := &bytes.Buffer{}
+	w, err := NewEnvelopeWriter(buf, passphrase, salt, DefaultKdfParams)
+	if err != nil {
+		return make([]byte, 0), err
+	}
+	if _, err := w.Write(input); err != nil {
+		return make([]byte, 0), err
+	}
+	if err := w.Close(); err != nil {
+		return make([]byte, 0), err
+	}
+	return buf.Bytes(), nil
+}
+
+// EncryptBytesWithKey encrypts the input as an envelope using the data key, which can be decrypted by DecryptBytes given the keys.
+func EncryptBytesWithKey(input []byte, keyId string, key []byte) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	w, err := NewKeyEnvelopeWriter(buf, keyId, key)
+	if err != nil {
+		return make([]byte, 0), err
+	}
+	if _, err := w.Write(input); err != nil {
+		return make([]byte, 0), err
+	}
+	if err := w.Close(); err != nil {
+		return make([]byte, 0), err
+	}
+	return buf.Bytes(), nil
+}
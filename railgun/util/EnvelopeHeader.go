@@ -0,0 +1,172 @@
+// =================================================================
+//
+// Copyright (C) 2018 Spatial Current, Inc. - All Rights Reserved
+// Released as open source under the MIT License.  See LICENSE file.
+//
+// =================================================================
+
+package util
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/hkdf"
+	"io"
+)
+
+// EnvelopeMagic is the first bytes of data encrypted with an envelope, which distinguishes it from legacy AES-CFB data.
+var EnvelopeMagic = []byte("RGEV")
+
+const (
+	EnvelopeVersionPassphrase = 1 // the key is derived from a passphrase
+	EnvelopeVersionKey        = 2 // the key is a data key from a keyring
+	EnvelopeChunkSize         = 64 * 1024
+	envelopeNonceSize         = 7
+	envelopeKeySaltSize       = 32 // the size of the salt of new envelopes encrypted with a data key
+	envelopeKeySaltMinSize    = 16
+)
+
+// envelopeMaxChunkSize is the largest chunk size read from a header, so a modified header cannot make decrypting allocate without bound.
+const envelopeMaxChunkSize = 16 * 1024 * 1024
+
+// EnvelopeHeader is the header of data encrypted with AES-256-GCM.
+// The data following the header is a sequence of chunks of ChunkSize bytes of plain text each sealed with its own nonce,
+// so large data can be encrypted and decrypted while it is streamed.
+//
+// The header is written as the magic bytes, version (1 byte), and chunk size (4 bytes), followed by
+// the Argon2 time (4 bytes), memory (4 bytes), threads (1 byte), salt length (1 byte), and salt for version 1,
+// or the key id length (1 byte), key id, salt length (1 byte), and salt for version 2,
+// followed by the nonce prefix (7 bytes).  All integers are big endian.
+//
+// Data encrypted with a data key is sealed with a subkey derived from the data key and the random salt of the file using HKDF-SHA256,
+// so the nonces of different files never share a key.
+type EnvelopeHeader struct {
+	Version   uint8
+	ChunkSize uint32
+	Kdf       KdfParams
+	Salt      []byte
+	KeyId     string
+	Nonce     []byte
+}
+
+// IsEnvelope returns true if the data starts with the envelope magic bytes.
+func IsEnvelope(b []byte) bool {
+	return bytes.HasPrefix(b, EnvelopeMagic)
+}
+
+func (h *EnvelopeHeader) Bytes() []byte {
+	buf := &bytes.Buffer{}
+	buf.Write(EnvelopeMagic)
+	buf.WriteByte(h.Version)
+	binary.Write(buf, binary.BigEndian, h.ChunkSize)
+	if h.Version == EnvelopeVersionKey {
+		buf.WriteByte(uint8(len(h.KeyId)))
+		buf.WriteString(h.KeyId)
+		buf.WriteByte(uint8(len(h.Salt)))
+		buf.Write(h.Salt)
+	} else {
+		binary.Write(buf, binary.BigEndian, h.Kdf.Time)
+		binary.Write(buf, binary.BigEndian, h.Kdf.Memory)
+		buf.WriteByte(h.Kdf.Threads)
+		buf.WriteByte(uint8(len(h.Salt)))
+		buf.Write(h.Salt)
+	}
+	buf.Write(h.Nonce)
+	return buf.Bytes()
+}
+
+// ReadEnvelopeHeader reads the header from the reader and returns the header and its bytes,
+// which are authenticated with each chunk.
+func ReadEnvelopeHeader(r io.Reader) (*EnvelopeHeader, []byte, error) {
+	raw := &bytes.Buffer{}
+	tr := io.TeeReader(r, raw)
+
+	fixed := make([]byte, len(EnvelopeMagic)+1+4)
+	if _, err := io.ReadFull(tr, fixed); err != nil {
+		return nil, nil, errors.Wrap(err, "error reading envelope header")
+	}
+	if !IsEnvelope(fixed) {
+		return nil, nil, errors.New("data is not an envelope")
+	}
+	fixed = fixed[len(EnvelopeMagic):]
+
+	h := &EnvelopeHeader{
+		Version:   fixed[0],
+		ChunkSize: binary.BigEndian.Uint32(fixed[1:5]),
+	}
+	if h.ChunkSize == 0 || h.ChunkSize > envelopeMaxChunkSize {
+		return nil, nil, errors.New("invalid envelope header")
+	}
+
+	var length int
+	switch h.Version {
+	case EnvelopeVersionPassphrase:
+		kdf := make([]byte, 4+4+1+1)
+		if _, err := io.ReadFull(tr, kdf); err != nil {
+			return nil, nil, errors.Wrap(err, "error reading envelope header")
+		}
+		h.Kdf = KdfParams{
+			Time:    binary.BigEndian.Uint32(kdf[0:4]),
+			Memory:  binary.BigEndian.Uint32(kdf[4:8]),
+			Threads: kdf[8],
+		}
+		if err := h.Kdf.Validate(); err != nil {
+			return nil, nil, errors.Wrap(err, "invalid envelope header")
+		}
+		length = int(kdf[9])
+	case EnvelopeVersionKey:
+		b := make([]byte, 1)
+		if _, err := io.ReadFull(tr, b); err != nil {
+			return nil, nil, errors.Wrap(err, "error reading envelope header")
+		}
+		length = int(b[0])
+	default:
+		return nil, nil, errors.New("unsupported envelope version " + fmt.Sprint(h.Version))
+	}
+
+	if h.Version == EnvelopeVersionKey {
+		b := make([]byte, length+1)
+		if _, err := io.ReadFull(tr, b); err != nil {
+			return nil, nil, errors.Wrap(err, "error reading envelope header")
+		}
+		h.KeyId = string(b[:length])
+		length = int(b[length])
+		if len(h.KeyId) == 0 || length < envelopeKeySaltMinSize {
+			return nil, nil, errors.New("invalid envelope header")
+		}
+	}
+
+	rest := make([]byte, length+envelopeNonceSize)
+	if _, err := io.ReadFull(tr, rest); err != nil {
+		return nil, nil, errors.Wrap(err, "error reading envelope header")
+	}
+	h.Salt = rest[:length]
+	h.Nonce = rest[length:]
+
+	return h, raw.Bytes(), nil
+}
+
+// nonce returns the nonce of a chunk, which is the nonce prefix, the index of the chunk, and whether it is the last chunk,
+// so chunks cannot be reordered or dropped.
+func (h *EnvelopeHeader) nonce(index uint32, last bool) []byte {
+	nonce := make([]byte, 0, envelopeNonceSize+5)
+	nonce = append(nonce, h.Nonce...)
+	nonce = append(nonce, byte(index>>24), byte(index>>16), byte(index>>8), byte(index))
+	if last {
+		return append(nonce, 1)
+	}
+	return append(nonce, 0)
+}
+
+// subkey returns the key that seals the chunks of data encrypted with the data key,
+// which is derived from the data key and the salt of the header with the key id as info.
+func (h *EnvelopeHeader) subkey(key []byte) ([]byte, error) {
+	subkey := make([]byte, 32)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, key, h.Salt, []byte(h.KeyId)), subkey); err != nil {
+		return nil, errors.Wrap(err, "error deriving subkey")
+	}
+	return subkey, nil
+}
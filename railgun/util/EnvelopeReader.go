@@ -0,0 +1,97 @@
+// =================================================================
+//
+// Copyright (C) 2018 Spatial Current, Inc. - All Rights Reserved
+// Released as open source under the MIT License.  See LICENSE file.
+//
+// =================================================================
+
+package util
+
+import (
+	"crypto/cipher"
+	"fmt"
+	"github.com/pkg/errors"
+	"io"
+)
+
+// EnvelopeReader decrypts an envelope written by EnvelopeWriter while it is read.
+// Read returns an error if the passphrase is wrong or the data was modified or truncated.
+type EnvelopeReader struct {
+	r      io.Reader
+	aead   cipher.AEAD
+	header *EnvelopeHeader
+	ad     []byte
+	buf    []byte
+	plain  []byte
+	index  uint32
+	done   bool
+}
+
+// NewEnvelopeReader returns a new EnvelopeReader after reading the header.
+// Data encrypted with a passphrase is decrypted with the passphrase and data encrypted with a data key is decrypted with a subkey of the key resolved by id.
+func NewEnvelopeReader(r io.Reader, passphrase string, keys KeyResolver) (*EnvelopeReader, error) {
+	h, ad, err := ReadEnvelopeHeader(r)
+	if err != nil {
+		return nil, err
+	}
+	var key []byte
+	if h.Version == EnvelopeVersionKey {
+		if keys == nil {
+			return nil, errors.New("data is encrypted with key " + h.KeyId + ", but no keyring was given")
+		}
+		dataKey, err := keys.DataKey(h.KeyId)
+		if err != nil {
+			return nil, errors.Wrap(err, "error resolving key "+h.KeyId)
+		}
+		key, err = h.subkey(dataKey)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		if len(passphrase) == 0 {
+			return nil, errors.New("data is encrypted with a passphrase, but no passphrase was given")
+		}
+		key = h.Kdf.Key(passphrase, h.Salt)
+	}
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	return &EnvelopeReader{r: r, aead: aead, header: h, ad: ad, buf: make([]byte, int(h.ChunkSize)+aead.Overhead())}, nil
+}
+
+func (er *EnvelopeReader) Read(p []byte) (int, error) {
+	for len(er.plain) == 0 {
+		if er.done {
+			return 0, io.EOF
+		}
+		if err := er.open(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, er.plain)
+	er.plain = er.plain[n:]
+	return n, nil
+}
+
+func (er *EnvelopeReader) open() error {
+	n, err := io.ReadFull(er.r, er.buf)
+	last := false
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		// only the last chunk is shorter than a full chunk
+		last = true
+	} else if err != nil {
+		return errors.Wrap(err, "error reading encrypted data")
+	}
+	if last && n < er.aead.Overhead() {
+		return errors.New("encrypted data is truncated")
+	}
+	plain, err := er.aead.Open(er.buf[:0], er.header.nonce(er.index, last), er.buf[:n], er.ad)
+	if err != nil {
+		return errors.New("error decrypting chunk " + fmt.Sprint(er.index) + ": the passphrase is wrong or the data was modified")
+	}
+	er.plain = plain
+	er.index++
+	er.done = last
+	return nil
+}
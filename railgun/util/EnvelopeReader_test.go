@@ -0,0 +1,201 @@
+// =================================================================
+//
+// Copyright (C) 2018 Spatial Current, Inc. - All Rights Reserved
+// Released as open source under the MIT License.  See LICENSE file.
+//
+// =================================================================
+
+package util
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io"
+	"io/ioutil"
+	"testing"
+)
+
+const envelopeTestOverhead = 16 // the size of the GCM tag of each chunk
+
+var envelopeTestKdf = KdfParams{Time: 1, Memory: 1024, Threads: 1}
+
+type envelopeTestKeys map[string][]byte
+
+func (k envelopeTestKeys) DataKey(id string) ([]byte, error) {
+	return k[id], nil
+}
+
+func envelopeTestData(t *testing.T, size int) []byte {
+	data := make([]byte, size)
+	if _, err := io.ReadFull(rand.Reader, data); err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func envelopeTestEncrypt(t *testing.T, data []byte) []byte {
+	buf := &bytes.Buffer{}
+	w, err := NewEnvelopeWriter(buf, "secret", "", envelopeTestKdf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func envelopeTestDecrypt(envelope []byte, passphrase string, keys KeyResolver) ([]byte, error) {
+	r, err := NewEnvelopeReader(bytes.NewReader(envelope), passphrase, keys)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadAll(r)
+}
+
+// envelopeTestSplit returns the header and the sealed chunks of the envelope.
+func envelopeTestSplit(t *testing.T, envelope []byte) ([]byte, [][]byte) {
+	_, header, err := ReadEnvelopeHeader(bytes.NewReader(envelope))
+	if err != nil {
+		t.Fatal(err)
+	}
+	chunks := make([][]byte, 0)
+	for rest := envelope[len(header):]; len(rest) > 0; {
+		n := EnvelopeChunkSize + envelopeTestOverhead
+		if n > len(rest) {
+			n = len(rest)
+		}
+		chunks = append(chunks, rest[:n])
+		rest = rest[n:]
+	}
+	return header, chunks
+}
+
+func TestEnvelopeRoundTrip(t *testing.T) {
+	sizes := []int{0, 1, EnvelopeChunkSize - 1, EnvelopeChunkSize, EnvelopeChunkSize + 1, 3*EnvelopeChunkSize + EnvelopeChunkSize/2}
+	for _, size := range sizes {
+		data := envelopeTestData(t, size)
+		plain, err := envelopeTestDecrypt(envelopeTestEncrypt(t, data), "secret", nil)
+		if err != nil {
+			t.Fatalf("error decrypting %d bytes: %s", size, err)
+		}
+		if !bytes.Equal(plain, data) {
+			t.Fatalf("plain text of %d bytes does not match", size)
+		}
+	}
+}
+
+func TestEnvelopeRoundTripWithKey(t *testing.T) {
+	key := envelopeTestData(t, 32)
+	keys := envelopeTestKeys{"parcels-1": key}
+	data := envelopeTestData(t, 2*EnvelopeChunkSize+100)
+
+	envelopes := make([][]byte, 0, 2)
+	for i := 0; i < 2; i++ {
+		envelope, err := EncryptBytesWithKey(data, "parcels-1", key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		plain, err := envelopeTestDecrypt(envelope, "", keys)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(plain, data) {
+			t.Fatal("plain text does not match")
+		}
+		envelopes = append(envelopes, envelope)
+	}
+
+	// each file is sealed with its own subkey, so the same data never produces the same cipher text.
+	_, a := envelopeTestSplit(t, envelopes[0])
+	_, b := envelopeTestSplit(t, envelopes[1])
+	if bytes.Equal(a[0], b[0]) {
+		t.Fatal("envelopes encrypted with the same data key share cipher text")
+	}
+}
+
+func TestEnvelopeWrongPassphrase(t *testing.T) {
+	if _, err := envelopeTestDecrypt(envelopeTestEncrypt(t, []byte("hello")), "wrong", nil); err == nil {
+		t.Fatal("expected an error decrypting with the wrong passphrase")
+	}
+}
+
+func TestEnvelopeTruncation(t *testing.T) {
+	data := envelopeTestData(t, 3*EnvelopeChunkSize+EnvelopeChunkSize/2)
+	envelope := envelopeTestEncrypt(t, data)
+	header, chunks := envelopeTestSplit(t, envelope)
+
+	truncated := map[string][]byte{
+		"header only":        header,
+		"partial header":     envelope[:len(header)-1],
+		"partial last chunk": envelope[:len(envelope)-1],
+		"partial tag":        envelope[:len(header)+envelopeTestOverhead-1],
+		"missing last chunk": bytes.Join(append([][]byte{header}, chunks[:len(chunks)-1]...), nil),
+	}
+	for name, b := range truncated {
+		if _, err := envelopeTestDecrypt(b, "secret", nil); err == nil {
+			t.Fatalf("expected an error decrypting envelope with %s", name)
+		}
+	}
+}
+
+func TestEnvelopeReorder(t *testing.T) {
+	data := envelopeTestData(t, 3*EnvelopeChunkSize+EnvelopeChunkSize/2)
+	header, chunks := envelopeTestSplit(t, envelopeTestEncrypt(t, data))
+
+	swapped := [][]byte{header, chunks[1], chunks[0], chunks[2], chunks[3]}
+	if _, err := envelopeTestDecrypt(bytes.Join(swapped, nil), "secret", nil); err == nil {
+		t.Fatal("expected an error decrypting envelope with reordered chunks")
+	}
+
+	// a full chunk moved to the end cannot take the place of the last chunk.
+	moved := [][]byte{header, chunks[0], chunks[1], chunks[3], chunks[2]}
+	if _, err := envelopeTestDecrypt(bytes.Join(moved, nil), "secret", nil); err == nil {
+		t.Fatal("expected an error decrypting envelope with the last chunk moved")
+	}
+}
+
+func TestEnvelopeTamper(t *testing.T) {
+	data := envelopeTestData(t, 2*EnvelopeChunkSize+100)
+	envelope := envelopeTestEncrypt(t, data)
+	_, header, err := ReadEnvelopeHeader(bytes.NewReader(envelope))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the header is authenticated with every chunk and every byte of the chunks is authenticated.
+	for _, i := range []int{len(EnvelopeMagic), len(header) - 1, len(header), len(header) + EnvelopeChunkSize, len(envelope) - 1} {
+		tampered := append([]byte{}, envelope...)
+		tampered[i] ^= 1
+		if _, err := envelopeTestDecrypt(tampered, "secret", nil); err == nil {
+			t.Fatalf("expected an error decrypting envelope with byte %d modified", i)
+		}
+	}
+}
+
+func TestEnvelopeHeaderLimits(t *testing.T) {
+	headers := map[string]*EnvelopeHeader{
+		"chunk size": {Version: EnvelopeVersionPassphrase, ChunkSize: envelopeMaxChunkSize + 1, Kdf: envelopeTestKdf, Salt: make([]byte, 16), Nonce: make([]byte, envelopeNonceSize)},
+		"kdf time":   {Version: EnvelopeVersionPassphrase, ChunkSize: EnvelopeChunkSize, Kdf: KdfParams{Time: KdfMaxTime + 1, Memory: 1024, Threads: 1}, Salt: make([]byte, 16), Nonce: make([]byte, envelopeNonceSize)},
+		"kdf memory": {Version: EnvelopeVersionPassphrase, ChunkSize: EnvelopeChunkSize, Kdf: KdfParams{Time: 1, Memory: KdfMaxMemory + 1, Threads: 1}, Salt: make([]byte, 16), Nonce: make([]byte, envelopeNonceSize)},
+		"salt":       {Version: EnvelopeVersionKey, ChunkSize: EnvelopeChunkSize, KeyId: "parcels-1", Salt: make([]byte, envelopeKeySaltMinSize-1), Nonce: make([]byte, envelopeNonceSize)},
+	}
+	for name, h := range headers {
+		if _, _, err := ReadEnvelopeHeader(bytes.NewReader(h.Bytes())); err == nil {
+			t.Fatalf("expected an error reading envelope header with invalid %s", name)
+		}
+	}
+}
+
+func TestDecryptBytesRequired(t *testing.T) {
+	if _, err := DecryptBytes([]byte("hello"), "", "", true, nil); err == nil {
+		t.Fatal("expected an error decrypting data that is not encrypted when a key is declared")
+	}
+	plain, err := DecryptBytes([]byte("hello"), "", "", false, nil)
+	if err != nil || string(plain) != "hello" {
+		t.Fatal("expected data that is not encrypted to be returned as is when no key is declared")
+	}
+}
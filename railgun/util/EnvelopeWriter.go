@@ -0,0 +1,160 @@
+// =================================================================
+//
+// Copyright (C) 2018 Spatial Current, Inc. - All Rights Reserved
+// Released as open source under the MIT License.  See LICENSE file.
+//
+// =================================================================
+
+package util
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"github.com/pkg/errors"
+	"io"
+	"math"
+)
+
+// EnvelopeWriter encrypts the data written to it with AES-256-GCM and writes it to the underlying writer as an envelope.
+// Close must be called to write the last chunk.  Close does not close the underlying writer.
+type EnvelopeWriter struct {
+	w      io.Writer
+	aead   cipher.AEAD
+	header *EnvelopeHeader
+	ad     []byte
+	buf    []byte
+	index  uint32
+	closed bool
+}
+
+// NewEnvelopeWriter returns a new EnvelopeWriter and writes the header to the writer.
+// The salt is hex encoded.  If the salt is blank, then a random salt is generated.
+func NewEnvelopeWriter(w io.Writer, passphrase string, salt string, kdf KdfParams) (*EnvelopeWriter, error) {
+	if err := kdf.Validate(); err != nil {
+		return nil, err
+	}
+	h := &EnvelopeHeader{
+		Version:   EnvelopeVersionPassphrase,
+		Kdf:       kdf,
+		ChunkSize: EnvelopeChunkSize,
+		Nonce:     make([]byte, envelopeNonceSize),
+	}
+	if len(salt) > 0 {
+		s, err := hex.DecodeString(salt)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid salt "+salt)
+		}
+		h.Salt = s
+	} else {
+		h.Salt = make([]byte, 16)
+		if _, err := io.ReadFull(rand.Reader, h.Salt); err != nil {
+			return nil, errors.Wrap(err, "error generating salt")
+		}
+	}
+	if len(h.Salt) > math.MaxUint8 {
+		return nil, errors.New("salt is longer than 255 bytes")
+	}
+	return newEnvelopeWriter(w, h, h.Kdf.Key(passphrase, h.Salt))
+}
+
+// NewKeyEnvelopeWriter returns a new EnvelopeWriter that encrypts with a subkey of the data key and records the id of the data key in the header,
+// so the key can be selected when the data is decrypted.  The subkey is derived from a random salt, which is also recorded in the header.
+func NewKeyEnvelopeWriter(w io.Writer, keyId string, key []byte) (*EnvelopeWriter, error) {
+	if len(keyId) == 0 || len(keyId) > math.MaxUint8 {
+		return nil, errors.New("key id must be between 1 and 255 bytes")
+	}
+	h := &EnvelopeHeader{
+		Version:   EnvelopeVersionKey,
+		ChunkSize: EnvelopeChunkSize,
+		KeyId:     keyId,
+		Salt:      make([]byte, envelopeKeySaltSize),
+		Nonce:     make([]byte, envelopeNonceSize),
+	}
+	if _, err := io.ReadFull(rand.Reader, h.Salt); err != nil {
+		return nil, errors.Wrap(err, "error generating salt")
+	}
+	subkey, err := h.subkey(key)
+	if err != nil {
+		return nil, err
+	}
+	return newEnvelopeWriter(w, h, subkey)
+}
+
+func newEnvelopeWriter(w io.Writer, h *EnvelopeHeader, key []byte) (*EnvelopeWriter, error) {
+	if _, err := io.ReadFull(rand.Reader, h.Nonce); err != nil {
+		return nil, errors.Wrap(err, "error generating nonce")
+	}
+
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	ad := h.Bytes()
+	if _, err := w.Write(ad); err != nil {
+		return nil, errors.Wrap(err, "error writing envelope header")
+	}
+
+	return &EnvelopeWriter{w: w, aead: aead, header: h, ad: ad, buf: make([]byte, 0, EnvelopeChunkSize)}, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating new AES256 cipher")
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating GCM cipher")
+	}
+	return aead, nil
+}
+
+func (ew *EnvelopeWriter) Write(p []byte) (int, error) {
+	if ew.closed {
+		return 0, errors.New("envelope writer is closed")
+	}
+	n := 0
+	for len(p) > 0 {
+		m := copy(ew.buf[len(ew.buf):cap(ew.buf)], p)
+		ew.buf = ew.buf[:len(ew.buf)+m]
+		p = p[m:]
+		n += m
+		// the last chunk is always shorter than the chunk size, so a full chunk is only sealed once more data is written.
+		if len(ew.buf) == cap(ew.buf) && len(p) > 0 {
+			if err := ew.seal(false); err != nil {
+				return n, err
+			}
+		}
+	}
+	return n, nil
+}
+
+func (ew *EnvelopeWriter) seal(last bool) error {
+	if ew.index == math.MaxUint32 {
+		return errors.New("data is too large for envelope")
+	}
+	chunk := ew.aead.Seal(nil, ew.header.nonce(ew.index, last), ew.buf, ew.ad)
+	if _, err := ew.w.Write(chunk); err != nil {
+		return errors.Wrap(err, "error writing encrypted data")
+	}
+	ew.index++
+	ew.buf = ew.buf[:0]
+	return nil
+}
+
+// Close writes the last chunk.
+func (ew *EnvelopeWriter) Close() error {
+	if ew.closed {
+		return nil
+	}
+	ew.closed = true
+	if len(ew.buf) == cap(ew.buf) {
+		if err := ew.seal(false); err != nil {
+			return err
+		}
+	}
+	return ew.seal(true)
+}
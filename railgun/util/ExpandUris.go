@@ -0,0 +1,120 @@
+// =================================================================
+//
+// Copyright (C) 2018 Spatial Current, Inc. - All Rights Reserved
+// Released as open source under the MIT License.  See LICENSE file.
+//
+// =================================================================
+
+package util
+
+import (
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/mitchellh/go-homedir"
+	"github.com/pkg/errors"
+)
+
+// ExpandUris expands the uris into the resources they match.
+//   - data/*.jsonl.gz => every local file matching the glob
+//   - s3://bucket/prefix/ => every object under the prefix
+//   - s3://bucket/prefix/*.jsonl.gz => every object under the prefix matching the glob
+//
+// Other uris, e.g., stdin or a single file, are returned as is.
+func ExpandUris(uris []string, s3_client *s3.S3) ([]string, error) {
+	resources := make([]string, 0, len(uris))
+	for _, uri := range uris {
+		if uri == "stdin" || strings.HasPrefix(uri, "athena://") {
+			resources = append(resources, uri)
+			continue
+		}
+		if strings.HasPrefix(uri, "s3://") {
+			keys, err := expandS3Uri(uri, s3_client)
+			if err != nil {
+				return resources, errors.Wrap(err, "error expanding uri "+uri)
+			}
+			resources = append(resources, keys...)
+			continue
+		}
+		if !strings.ContainsAny(uri, "*?[") {
+			resources = append(resources, uri)
+			continue
+		}
+		pattern := strings.TrimPrefix(uri, "file://")
+		patternExpanded, err := homedir.Expand(pattern)
+		if err != nil {
+			return resources, errors.Wrap(err, "error expanding path "+pattern)
+		}
+		matches, err := filepath.Glob(patternExpanded)
+		if err != nil {
+			return resources, errors.Wrap(err, "invalid glob "+pattern)
+		}
+		if len(matches) == 0 {
+			return resources, errors.New("no files match " + pattern)
+		}
+		resources = append(resources, matches...)
+	}
+	return resources, nil
+}
+
+func expandS3Uri(uri string, s3_client *s3.S3) ([]string, error) {
+	parts := strings.SplitN(strings.TrimPrefix(uri, "s3://"), "/", 2)
+	if len(parts) != 2 {
+		return []string{uri}, nil
+	}
+	bucket, key := parts[0], parts[1]
+
+	pattern := ""
+	if i := strings.IndexAny(key, "*?["); i != -1 {
+		pattern = key
+		key = key[:strings.LastIndex(key[:i], "/")+1]
+	} else if len(key) > 0 && !strings.HasSuffix(key, "/") {
+		return []string{uri}, nil
+	}
+
+	if s3_client == nil {
+		return nil, errors.New("listing objects in s3 requires an s3 client")
+	}
+
+	keys := make([]string, 0)
+	var matchErr error
+	err := s3_client.ListObjectsV2Pages(
+		&s3.ListObjectsV2Input{Bucket: aws.String(bucket), Prefix: aws.String(key)},
+		func(page *s3.ListObjectsV2Output, lastPage bool) bool {
+			for _, object := range page.Contents {
+				k := aws.StringValue(object.Key)
+				if strings.HasSuffix(k, "/") {
+					continue
+				}
+				if len(pattern) > 0 {
+					match, err := path.Match(pattern, k)
+					if err != nil {
+						matchErr = errors.Wrap(err, "invalid glob "+pattern)
+						return false
+					}
+					if !match {
+						continue
+					}
+				}
+				keys = append(keys, "s3://"+bucket+"/"+k)
+			}
+			return true
+		})
+	if err != nil {
+		return nil, errors.Wrap(err, "error listing objects in bucket "+bucket)
+	}
+	if matchErr != nil {
+		return nil, matchErr
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("no objects match " + uri)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
@@ -0,0 +1,49 @@
+// =================================================================
+//
+// Copyright (C) 2018 Spatial Current, Inc. - All Rights Reserved
+// Released as open source under the MIT License.  See LICENSE file.
+//
+// =================================================================
+
+package util
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/argon2"
+)
+
+// KdfParams are the parameters of the Argon2id key derivation function that derives an AES-256 key from a passphrase.
+type KdfParams struct {
+	Time    uint32 // the number of passes over the memory
+	Memory  uint32 // the memory in KiB
+	Threads uint8  // the degree of parallelism
+}
+
+// DefaultKdfParams are the parameters used by CreateCipher and for new envelopes.
+var DefaultKdfParams = KdfParams{Time: 3, Memory: 32 * 1024, Threads: 4}
+
+// The limits of the parameters, so the parameters in a modified header cannot make decrypting compute without bound.
+const (
+	KdfMaxTime   = 10
+	KdfMaxMemory = 1024 * 1024 // 1 GiB in KiB
+)
+
+// Validate returns an error if the parameters are zero or above the limits.
+func (p KdfParams) Validate() error {
+	if p.Time == 0 || p.Time > KdfMaxTime {
+		return errors.New("kdf time must be between 1 and " + fmt.Sprint(KdfMaxTime))
+	}
+	if p.Memory == 0 || p.Memory > KdfMaxMemory {
+		return errors.New("kdf memory must be between 1 and " + fmt.Sprint(KdfMaxMemory) + " KiB")
+	}
+	if p.Threads == 0 {
+		return errors.New("kdf threads must be greater than 0")
+	}
+	return nil
+}
+
+// Key returns the 32 byte key derived from the passphrase and salt.
+func (p KdfParams) Key(passphrase string, salt []byte) []byte {
+	return argon2.Key([]byte(passphrase), salt, p.Time, p.Memory, p.Threads, 32)
+}
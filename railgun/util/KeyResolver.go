@@ -0,0 +1,13 @@
+// =================================================================
+//
+// Copyright (C) 2018 Spatial Current, Inc. - All Rights Reserved
+// Released as open source under the MIT License.  See LICENSE file.
+//
+// =================================================================
+
+package util
+
+// KeyResolver returns the data key with the given id, which is recorded in the header of data encrypted with a data key.
+type KeyResolver interface {
+	DataKey(id string) ([]byte, error)
+}
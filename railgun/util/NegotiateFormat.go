@@ -0,0 +1,110 @@
+// =================================================================
+//
+// Copyright (C) 2018 Spatial Current, Inc. - All Rights Reserved
+// Released as open source under the MIT License.  See LICENSE file.
+//
+// =================================================================
+
+package util
+
+import (
+	rerrors "github.com/spatialcurrent/railgun/railgun/errors"
+	"mime"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// MediaTypeFormats maps a media type, without parameters, to its format.
+var MediaTypeFormats = map[string]string{
+	"application/bson":          "bson",
+	"application/geo+json":      "geojson",
+	"application/json":          "json",
+	"application/jsonl":         "jsonl",
+	"application/msgpack":       "msgpack",
+	"application/toml":          "toml",
+	"application/ubjson":        "bson",
+	"application/x-msgpack":     "msgpack",
+	"application/x-ndjson":      "jsonl",
+	"application/x-yaml":        "yaml",
+	"application/yaml":          "yaml",
+	"image/jpeg":                "jpg",
+	"image/png":                 "png",
+	"text/csv":                  "csv",
+	"text/html":                 "html",
+	"text/json":                 "json",
+	"text/tab-separated-values": "tsv",
+	"text/x-yaml":               "yaml",
+	"text/yaml":                 "yaml",
+}
+
+// NegotiableFormats are the formats that can be chosen with the Accept header, in order of preference for wildcards.
+var NegotiableFormats = []string{"json", "yaml", "toml", "bson", "csv", "tsv", "jsonl", "geojson", "msgpack", "html", "png", "jpg"}
+
+type acceptRange struct {
+	mediaType string
+	quality   float64
+}
+
+// NegotiateFormat returns the format for the Accept header of a request.
+// Media types are tried in order of quality, and the default format is returned for an empty header or */*.
+// Returns an ErrNotAcceptable if no media type in the header is supported.
+func NegotiateFormat(accept string, defaultFormat string) (string, error) {
+	if len(strings.TrimSpace(accept)) == 0 {
+		return defaultFormat, nil
+	}
+
+	ranges := make([]acceptRange, 0)
+	for _, str := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(str))
+		if err != nil {
+			continue
+		}
+		quality := 1.0
+		if q, ok := params["q"]; ok {
+			if f, err := strconv.ParseFloat(q, 64); err == nil {
+				quality = f
+			}
+		}
+		if quality > 0 {
+			ranges = append(ranges, acceptRange{mediaType: mediaType, quality: quality})
+		}
+	}
+	sort.SliceStable(ranges, func(i, j int) bool {
+		return ranges[i].quality > ranges[j].quality
+	})
+
+	for _, r := range ranges {
+		if r.mediaType == "*/*" {
+			return defaultFormat, nil
+		}
+		if strings.HasSuffix(r.mediaType, "/*") {
+			prefix := strings.TrimSuffix(r.mediaType, "*")
+			if strings.HasPrefix(ContentType(defaultFormat), prefix) {
+				return defaultFormat, nil
+			}
+			for _, format := range NegotiableFormats {
+				if strings.HasPrefix(ContentType(format), prefix) {
+					return format, nil
+				}
+			}
+			continue
+		}
+		if format, ok := MediaTypeFormats[r.mediaType]; ok {
+			return format, nil
+		}
+	}
+
+	return "", &rerrors.ErrNotAcceptable{Accept: accept}
+}
+
+// ContentTypeFormat returns the format for the Content-Type header of a request.
+// Returns false if the header is empty or the media type is not known.
+func ContentTypeFormat(contentType string) (string, bool) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return "", false
+	}
+	format, ok := MediaTypeFormats[mediaType]
+	return format, ok
+}
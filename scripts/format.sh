@@ -28,15 +28,27 @@ go fmt
 echo "Formatting $DIR/../railgun/handlers"
 cd $DIR/../railgun/handlers
 go fmt
+echo "Formatting $DIR/../railgun/health"
+cd $DIR/../railgun/health
+go fmt
 echo "Formatting $DIR/../railgun/img"
 cd $DIR/../railgun/img
 go fmt
+echo "Formatting $DIR/../railgun/keyring"
+cd $DIR/../railgun/keyring
+go fmt
 echo "Formatting $DIR/../railgun/logger"
 cd $DIR/../railgun/logger
 go fmt
+echo "Formatting $DIR/../railgun/metrics"
+cd $DIR/../railgun/metrics
+go fmt
 echo "Formatting $DIR/../railgun/named"
 cd $DIR/../railgun/named
 go fmt
+echo "Formatting $DIR/../railgun/openapi"
+cd $DIR/../railgun/openapi
+go fmt
 echo "Formatting $DIR/../railgun/parser"
 cd $DIR/../railgun/parser
 go fmt
@@ -49,6 +61,21 @@ go fmt
 echo "Formatting $DIR/../railgun/router"
 cd $DIR/../railgun/router
 go fmt
+echo "Formatting $DIR/../railgun/runner"
+cd $DIR/../railgun/runner
+go fmt
+echo "Formatting $DIR/../railgun/runs"
+cd $DIR/../railgun/runs
+go fmt
+echo "Formatting $DIR/../railgun/scheduler"
+cd $DIR/../railgun/scheduler
+go fmt
+echo "Formatting $DIR/../railgun/session"
+cd $DIR/../railgun/session
+go fmt
+echo "Formatting $DIR/../railgun/tracing"
+cd $DIR/../railgun/tracing
+go fmt
 echo "Formatting $DIR/../cmd/railgun"
 cd $DIR/../cmd/railgun/
 go fmt